@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	approvalAddr      string
+	approvalTokenFile string
+	// approvals asks for the approvals of the runs, or is nil.
+	approvals *approver
+)
+
+// approver asks for the approval of the waves following a wave with
+// pauseAfter, on stdin when it is a terminal and through the HTTP endpoint of
+// --approval-addr. The first answer wins.
+type approver struct {
+	stdin bool
+	token string
+	// lines receives the lines read from stdin, see readStdin.
+	lines    chan string
+	readOnce sync.Once
+
+	// mu serializes the approvals of the clusters of a run.
+	mu sync.Mutex
+	// pending is the wave waiting for an approval, and decisions receives
+	// the answer of the HTTP endpoint to it. decisions holds one answer, so
+	// that an answer sent before approve waits for it is not lost; it is
+	// only sent to and drained under pendingMu, so that an answer never
+	// outlives its wave.
+	pendingMu sync.Mutex
+	pending   *scaler.Target
+	decisions chan bool
+}
+
+// newApprover returns the approver of the run, or nil if approvals can be
+// given neither on stdin nor over HTTP.
+func newApprover() (*approver, error) {
+	a := &approver{stdin: isTerminal(os.Stdin), decisions: make(chan bool, 1)}
+	if approvalTokenFile != "" {
+		data, err := os.ReadFile(approvalTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading approval token file: %v", err)
+		}
+		a.token = strings.TrimSpace(string(data))
+	}
+	if approvalAddr != "" && a.token == "" && !isLoopback(approvalAddr) {
+		return nil, withExitCode(exitConfig, fmt.Errorf("--approval-token-file is required to serve approvals on %s, or use a loopback --approval-addr such as 127.0.0.1:8081", approvalAddr))
+	}
+	if approvalAddr == "" && !a.stdin {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// serve serves the HTTP endpoint of --approval-addr for a run, if any of its
+// targets pauses after its wave. The returned function shuts it down once
+// the run is done.
+func (a *approver) serve(targets []scaler.Target) (func(), error) {
+	pauses := slices.ContainsFunc(targets, func(t scaler.Target) bool { return t.Item.PauseAfter })
+	if approvalAddr == "" || !pauses {
+		return func() {}, nil
+	}
+	listener, err := net.Listen("tcp", approvalAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error starting approval server: %v", err)
+	}
+	server := &http.Server{
+		Handler:           a.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warn("Approval server failed", "error", err)
+		}
+	}()
+	logger.Info("Serving wave approvals", "url", approvalAddr+"/approval")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
+
+// readStdin starts reading the lines of stdin into a.lines. It only starts
+// with the first approval, so that it does not take the answer of the
+// confirmation.
+func (a *approver) readStdin() {
+	a.lines = make(chan string)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				a.lines <- line
+			}
+			if err != nil {
+				close(a.lines)
+				return
+			}
+		}
+	}()
+}
+
+// approve blocks until the next wave is approved or rejected.
+func (a *approver) approve(ctx context.Context, next scaler.Target) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pendingMu.Lock()
+	a.pending = &next
+	a.pendingMu.Unlock()
+	defer func() {
+		a.pendingMu.Lock()
+		a.pending = nil
+		select {
+		case <-a.decisions:
+		default:
+		}
+		a.pendingMu.Unlock()
+	}()
+
+	wave := fmt.Sprintf("wave %d", next.Wave)
+	if next.Cluster != "" {
+		wave += " of " + next.Cluster
+	}
+	if a.stdin {
+		a.readOnce.Do(a.readStdin)
+		fmt.Fprintf(textOut, "Start %s? Type y to approve, n to reject:\n", wave)
+	}
+	if approvalAddr != "" {
+		logger.Info(fmt.Sprintf("Waiting for the approval of %s", wave), "approve", "POST "+approvalAddr+"/approve", "reject", "POST "+approvalAddr+"/reject")
+	}
+
+	for {
+		select {
+		case line, ok := <-a.lines:
+			if !ok {
+				return fmt.Errorf("stdin closed while waiting for the approval")
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return nil
+			case "n", "no":
+				return fmt.Errorf("rejected")
+			}
+			fmt.Fprintf(textOut, "Start %s? Type y to approve, n to reject:\n", wave)
+		case approved := <-a.decisions:
+			if !approved {
+				return fmt.Errorf("rejected")
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// approvalStatus is the body of the responses of the approval endpoint.
+type approvalStatus struct {
+	Pending bool   `json:"pending"`
+	Cluster string `json:"cluster,omitempty"`
+	Wave    *int   `json:"wave,omitempty"`
+}
+
+func (a *approver) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /approval", func(w http.ResponseWriter, r *http.Request) {
+		writeHTTPJSON(w, http.StatusOK, a.status())
+	})
+	mux.HandleFunc("POST /approve", func(w http.ResponseWriter, r *http.Request) {
+		a.decide(w, true)
+	})
+	mux.HandleFunc("POST /reject", func(w http.ResponseWriter, r *http.Request) {
+		a.decide(w, false)
+	})
+	if a.token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeHTTPError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// decide answers the pending approval, or fails with a conflict when no wave
+// is waiting or it already has an answer.
+func (a *approver) decide(w http.ResponseWriter, approved bool) {
+	a.pendingMu.Lock()
+	status := a.statusLocked()
+	if status.Pending {
+		select {
+		case a.decisions <- approved:
+			a.pendingMu.Unlock()
+			status.Pending = false
+			writeHTTPJSON(w, http.StatusOK, status)
+			return
+		default:
+			a.pendingMu.Unlock()
+			writeHTTPError(w, http.StatusConflict, errors.New("the waiting wave already has an answer"))
+			return
+		}
+	}
+	a.pendingMu.Unlock()
+	writeHTTPError(w, http.StatusConflict, errors.New("no wave is waiting for an approval"))
+}
+
+// status returns the wave waiting for an approval, if any.
+func (a *approver) status() approvalStatus {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	return a.statusLocked()
+}
+
+// statusLocked is status with pendingMu held.
+func (a *approver) statusLocked() approvalStatus {
+	if a.pending == nil {
+		return approvalStatus{}
+	}
+	wave := a.pending.Wave
+	return approvalStatus{Pending: true, Cluster: a.pending.Cluster, Wave: &wave}
+}
+
+func writeHTTPJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, err error) {
+	writeHTTPJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// cluster is a kubeconfig context scaled by the run, with the items of the
+// config that live in it. Its name is empty unless the config names
+// contexts.
+type cluster struct {
+	name      string
+	flags     *genericclioptions.ConfigFlags
+	config    scaler.Config
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	mapper    meta.RESTMapper
+	scaler    *scaler.Scaler
+	targets   []scaler.Target
+	// current is set for the current context, to which --all and --nodes
+	// apply.
+	current bool
+}
+
+// newClusters splits the config by context and connects to every cluster.
+// Without contexts in the config, the run targets the current context only,
+// as set by the connection flags.
+func newClusters(config *scaler.Config) ([]*cluster, error) {
+	if !config.MultiCluster() {
+		c := &cluster{flags: configFlags, config: *config}
+		return []*cluster{c}, c.connect(true)
+	}
+
+	current, err := contextName(configFlags)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving the current context: %v", err)
+	}
+	groups := config.SplitByContext(current)
+	if _, ok := groups[current]; !ok && (allInNamespace || len(nodeNames) > 0) {
+		groups[current] = scaler.Config{PreHook: config.PreHook, PostHook: config.PostHook, MaxUnavailable: config.MaxUnavailable}
+	}
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var clusters []*cluster
+	for _, name := range names {
+		flags := configFlags
+		if name != current {
+			flags = contextFlags(name)
+		}
+		c := &cluster{name: name, flags: flags, config: groups[name]}
+		if err := c.connect(name == current); err != nil {
+			return nil, fmt.Errorf("context %s: %v", name, err)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+// contextFlags returns the connection flags for another context of the
+// kubeconfig. The kubeconfig, namespace and impersonation flags are kept,
+// while the flags overriding the cluster or user of the current context are
+// not.
+func contextFlags(name string) *genericclioptions.ConfigFlags {
+	flags := newConfigFlags()
+	flags.Context = &name
+	flags.KubeConfig = configFlags.KubeConfig
+	flags.CacheDir = configFlags.CacheDir
+	flags.Namespace = configFlags.Namespace
+	flags.Impersonate = configFlags.Impersonate
+	flags.ImpersonateUID = configFlags.ImpersonateUID
+	flags.ImpersonateGroup = configFlags.ImpersonateGroup
+	return flags
+}
+
+// connect creates the clients of the cluster and applies its default
+// namespace to the config. --all only applies to the current context.
+func (c *cluster) connect(current bool) error {
+	c.current = current
+	kubeConfig, err := c.flags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+
+	namespace, explicit, err := c.flags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("error resolving namespace: %v", err)
+	}
+	c.config.ApplyDefaultNamespace(namespace, explicit)
+	if allInNamespace && current {
+		c.config.Namespaces = append(c.config.Namespaces, scaler.NamespaceItem{Name: namespace})
+	}
+
+	if c.clientset, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+	if c.dynamic, err = dynamic.NewForConfig(kubeConfig); err != nil {
+		return fmt.Errorf("error creating dynamic client: %v", err)
+	}
+	if c.mapper, err = c.flags.ToRESTMapper(); err != nil {
+		return fmt.Errorf("error creating rest mapper: %v", err)
+	}
+	return nil
+}
+
+// describeClusters names the contexts and API servers the run targets.
+func describeClusters(clusters []*cluster) string {
+	var descriptions []string
+	for _, c := range clusters {
+		descriptions = append(descriptions, describeContext(c.flags))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// resolveClusters resolves the targets of every cluster and applies --group,
+// --only and --exclude to all of them together.
+func resolveClusters(ctx context.Context, clusters []*cluster) ([]scaler.Target, error) {
+	var all []scaler.Target
+	for _, c := range clusters {
+		targets, err := c.scaler.Resolve(ctx, c.config)
+		if err != nil {
+			return nil, c.wrap(err)
+		}
+		all = append(all, targets...)
+	}
+	all, err := scaler.FilterGroups(all, onlyGroups)
+	if err != nil {
+		return nil, err
+	}
+	if all, err = scaler.Filter(all, onlyRefs, excludeRefs); err != nil {
+		return nil, err
+	}
+	for _, c := range clusters {
+		c.targets = nil
+		for _, t := range all {
+			if t.Cluster == c.name {
+				c.targets = append(c.targets, t)
+			}
+		}
+	}
+	return all, nil
+}
+
+// planClusters plans the targets of every cluster.
+func planClusters(ctx context.Context, clusters []*cluster, mode scaler.Mode) []scaler.PlanEntry {
+	var plan []scaler.PlanEntry
+	for _, c := range clusters {
+		entries := c.scaler.Plan(ctx, mode, c.targets)
+		if serverDryRun {
+			entries = c.scaler.ServerDryRun(ctx, mode, entries)
+		}
+		plan = append(plan, entries...)
+	}
+	return plan
+}
+
+// runClusters runs every cluster in parallel and merges their reports. All
+// clusters pass the preflight before any of them is changed, and fail or
+// roll back together.
+func runClusters(ctx context.Context, clusters []*cluster, mode scaler.Mode) (scaler.Report, error) {
+	if len(clusters) == 1 {
+		return clusters[0].scaler.Run(ctx, mode, clusters[0].targets)
+	}
+
+	report := scaler.Report{Mode: mode}
+	var problems, conflicts, violations []string
+	for _, c := range clusters {
+		err := c.scaler.Preflight(ctx, mode, c.targets)
+		var preflightErr *scaler.PreflightError
+		var hpaErr *scaler.HPAConflictError
+		var pdbErr *scaler.PDBViolationError
+		switch {
+		case errors.As(err, &preflightErr):
+			for _, p := range preflightErr.Problems {
+				problems = append(problems, c.name+": "+p)
+			}
+		case errors.As(err, &hpaErr):
+			for _, conflict := range hpaErr.Conflicts {
+				conflicts = append(conflicts, c.name+": "+conflict)
+			}
+		case errors.As(err, &pdbErr):
+			for _, v := range pdbErr.Violations {
+				violations = append(violations, c.name+": "+v)
+			}
+		case err != nil:
+			return report, c.wrap(err)
+		}
+	}
+	if len(problems) > 0 {
+		return report, &scaler.PreflightError{Problems: problems}
+	}
+	if len(conflicts) > 0 {
+		return report, &scaler.HPAConflictError{Conflicts: conflicts}
+	}
+	if len(violations) > 0 {
+		return report, &scaler.PDBViolationError{Violations: violations}
+	}
+
+	// The clusters run as one group, so that a failure in one of them stops
+	// or rolls back all of them, and the preflight is not run again.
+	group := scaler.NewGroup(len(clusters))
+	reports := make([]scaler.Report, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = group.Run(ctx, c.scaler, mode, c.targets)
+			errs[i] = c.wrap(errs[i])
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range reports {
+		report.Results = append(report.Results, r.Results...)
+		report.Rollback = append(report.Rollback, r.Rollback...)
+		report.Interrupted = report.Interrupted || r.Interrupted
+		report.Cordoned = append(report.Cordoned, r.Cordoned...)
+	}
+	return report, errors.Join(errs...)
+}
+
+// wrap names the cluster in errors of multi-cluster runs.
+func (c *cluster) wrap(err error) error {
+	if err == nil || c.name == "" {
+		return err
+	}
+	return fmt.Errorf("context %s: %w", c.name, err)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	stateNamespace string
+	stateRun       string
+)
+
+// newStateStore returns the store of --state-namespace in the current
+// context, or nil without it.
+func newStateStore() (*scaler.StateStore, error) {
+	if stateNamespace == "" {
+		if stateRun != "" {
+			return nil, fmt.Errorf("--state-run requires --state-namespace")
+		}
+		return nil, nil
+	}
+	kubeConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error building kubeconfig: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating clientset: %v", err)
+	}
+	return scaler.NewStateStore(clientset, stateNamespace), nil
+}
+
+// loadStateRecord reads the scale down named by --state-run, to restore it.
+func loadStateRecord(ctx context.Context, store *scaler.StateStore) (*scaler.StateRecord, error) {
+	if stateRun == "" {
+		return nil, nil
+	}
+	record, err := store.Load(ctx, stateRun)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the state of run %s: %v", stateRun, err)
+	}
+	logger.Info("Restoring a scale down", "run", record.RunID, "status", string(record.Status), "by", record.Holder, "started", record.StartedAt.Local().Format(time.RFC3339))
+	return record, nil
+}
+
+// startStateRecord saves the state of a scale down starting now in the
+// cluster, with the config of every cluster of the run.
+func (r *runner) startStateRecord(ctx context.Context, state *scaler.State) error {
+	r.record = &scaler.StateRecord{
+		RunID:     currentRunID,
+		Status:    scaler.RunStatusRunning,
+		Holder:    lockHolder(),
+		StartedAt: time.Now(),
+		Config:    effectiveConfig(r.clusters),
+		State:     state,
+	}
+	if err := r.stateStore.Save(ctx, r.record); err != nil {
+		return fmt.Errorf("error saving the run state in the cluster: %v", err)
+	}
+	logger.Info("Saving the run state in the cluster", "configmap", r.stateStore.Name(r.record.RunID))
+	return nil
+}
+
+// finishStateRecord records the outcome of the run of the state record. The
+// state is saved even if the run was interrupted.
+func (r *runner) finishStateRecord(ctx context.Context, mode scaler.Mode, runErr error) error {
+	switch {
+	case mode == scaler.ModeRestore && runErr == nil:
+		r.record.Status = scaler.RunStatusRestored
+	case mode == scaler.ModeRestore:
+		// A failed restore leaves the scale down to be restored again.
+		return nil
+	case ctx.Err() != nil:
+		r.record.Status = scaler.RunStatusInterrupted
+	case runErr != nil:
+		r.record.Status = scaler.RunStatusFailed
+	default:
+		r.record.Status = scaler.RunStatusScaledDown
+	}
+	r.record.Message = errString(runErr)
+	r.record.FinishedAt = time.Now()
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := r.stateStore.Save(saveCtx, r.record); err != nil {
+		return fmt.Errorf("error saving the run state in the cluster: %v", err)
+	}
+	if mode == scaler.ModeScaleDown {
+		logger.Info(fmt.Sprintf("Run state saved in the cluster, restore it with --state-namespace %s --state-run %s", stateNamespace, r.record.RunID))
+	}
+	return nil
+}
+
+// effectiveConfig joins the configs of the clusters of the run, with their
+// default namespaces and the resources found with --nodes, naming the
+// context of every item in multi-cluster runs.
+func effectiveConfig(clusters []*cluster) scaler.Config {
+	if len(clusters) == 1 && clusters[0].name == "" {
+		return clusters[0].config
+	}
+	withContext := func(items []scaler.ResourceItem, name string) []scaler.ResourceItem {
+		result := make([]scaler.ResourceItem, len(items))
+		for i, item := range items {
+			if item.Context == "" {
+				item.Context = name
+			}
+			result[i] = item
+		}
+		return result
+	}
+	var config scaler.Config
+	for _, c := range clusters {
+		config.PreHook, config.PostHook = c.config.PreHook, c.config.PostHook
+		config.MaxUnavailable = c.config.MaxUnavailable
+		config.Deployments = append(config.Deployments, withContext(c.config.Deployments, c.name)...)
+		config.StatefulSets = append(config.StatefulSets, withContext(c.config.StatefulSets, c.name)...)
+		config.CronJobs = append(config.CronJobs, withContext(c.config.CronJobs, c.name)...)
+		config.Jobs = append(config.Jobs, withContext(c.config.Jobs, c.name)...)
+		config.ReplicationControllers = append(config.ReplicationControllers, withContext(c.config.ReplicationControllers, c.name)...)
+		config.DeploymentConfigs = append(config.DeploymentConfigs, withContext(c.config.DeploymentConfigs, c.name)...)
+		config.Rollouts = append(config.Rollouts, withContext(c.config.Rollouts, c.name)...)
+		config.KnativeServices = append(config.KnativeServices, withContext(c.config.KnativeServices, c.name)...)
+		config.Custom = append(config.Custom, withContext(c.config.Custom, c.name)...)
+		config.Resources = append(config.Resources, withContext(c.config.Resources, c.name)...)
+		for _, ns := range c.config.Namespaces {
+			if ns.Context == "" {
+				ns.Context = c.name
+			}
+			config.Namespaces = append(config.Namespaces, ns)
+		}
+		for _, node := range c.config.Nodes {
+			if node.Context == "" {
+				node.Context = c.name
+			}
+			config.Nodes = append(config.Nodes, node)
+		}
+	}
+	return config
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// ANSI colors of the console output. Every color has the same length, so
+// that colored cells stay aligned in tables.
+const (
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorDefault = "\x1b[39m"
+	colorReset   = "\x1b[0m"
+)
+
+var (
+	noColor bool
+	// colorOutput is set by setupColor when the console output is colored.
+	colorOutput bool
+)
+
+// setupColor colors the output written to w when it is a terminal, unless
+// --no-color or the NO_COLOR environment variable is set.
+func setupColor(w io.Writer) {
+	f, ok := w.(*os.File)
+	colorOutput = ok && isTerminal(f) && !noColor && os.Getenv("NO_COLOR") == ""
+}
+
+// colorize wraps s in color when the output is colored.
+func colorize(color, s string) string {
+	if !colorOutput || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// stateColor is the color of the state of a resource in the progress
+// table.
+func stateColor(state string) string {
+	switch state {
+	case string(scaler.StatusScaled), string(scaler.StatusUnchanged):
+		return colorGreen
+	case rowRunning, string(scaler.StatusSkipped):
+		return colorYellow
+	case rowFailed:
+		return colorRed
+	}
+	return colorDefault
+}
+
+// eventColor is the color of the messages of an event type.
+func eventColor(eventType string) string {
+	switch scaler.EventType(eventType) {
+	case scaler.EventCompleted:
+		return colorGreen
+	case scaler.EventWarning:
+		return colorYellow
+	case scaler.EventFailed:
+		return colorRed
+	}
+	return ""
+}
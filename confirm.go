@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var assumeYes bool
+
+// clusterDescription names the context and API server of the connection
+// flags.
+func clusterDescription() string {
+	return describeContext(configFlags)
+}
+
+// describeContext names the context and API server of a set of connection
+// flags.
+func describeContext(flags *genericclioptions.ConfigFlags) string {
+	name, err := contextName(flags)
+	if err != nil {
+		return "unknown"
+	}
+	if name == "" {
+		// Without a kubeconfig, client-go falls back to the service account
+		// of the pod, e.g. in a Job rendered by generate job.
+		name = "in-cluster"
+	}
+	server := "unknown server"
+	if restConfig, err := flags.ToRESTConfig(); err == nil {
+		server = restConfig.Host
+	}
+	if *flags.Impersonate != "" {
+		server += ", as " + *flags.Impersonate
+	}
+	return fmt.Sprintf("%s (%s)", name, server)
+}
+
+// contextName returns the context selected with --context, or the current
+// context of the kubeconfig.
+func contextName(flags *genericclioptions.ConfigFlags) (string, error) {
+	if *flags.Context != "" {
+		return *flags.Context, nil
+	}
+	raw, err := flags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return "", err
+	}
+	return raw.CurrentContext, nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm prints the plan and asks the user to confirm it before anything is
+// changed, unless --yes is set.
+func confirm(ctx context.Context, clusters []*cluster, mode scaler.Mode, targets []scaler.Target) error {
+	if assumeYes || len(targets) == 0 {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("refusing to %s without confirmation, stdin is not a terminal: use --yes to skip the prompt", mode)
+	}
+
+	printPlan("Plan:", mode, planClusters(ctx, clusters, mode))
+	if len(clusters) == 1 {
+		fmt.Fprintf(textOut, "\nContext: %s\n", describeContext(clusters[0].flags))
+	} else {
+		fmt.Fprintln(textOut, "\nContexts:")
+		for _, c := range clusters {
+			fmt.Fprintf(textOut, "- %s: %d resources\n", describeContext(c.flags), len(c.targets))
+		}
+	}
+	fmt.Fprintf(textOut, "Do you want to %s %d resources? [y/N]: ", mode, len(targets))
+
+	// The read is abandoned if the run is interrupted while waiting.
+	type reply struct {
+		answer string
+		err    error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		replies <- reply{answer, err}
+	}()
+	var r reply
+	select {
+	case r = <-replies:
+	case <-ctx.Done():
+		fmt.Fprintln(textOut)
+		return fmt.Errorf("aborted, nothing was changed")
+	}
+	if r.err != nil && r.answer == "" {
+		return fmt.Errorf("error reading confirmation: %v", r.err)
+	}
+	switch strings.ToLower(strings.TrimSpace(r.answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("aborted, nothing was changed")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Exit codes of the command, so that wrapper scripts can tell failures
+// apart.
+const (
+	exitOK = 0
+	// exitError is any failure without a more specific code, e.g. a lock
+	// held by another run or a declined confirmation.
+	exitError = 1
+	// exitPartial is a run where some resources failed, or a status where
+	// some resources do not match their target.
+	exitPartial = 2
+	// exitConfig is an invalid config or flag, or a preflight that found
+	// problems with the resources.
+	exitConfig = 3
+	// exitConnection is a failure to reach or authenticate to a cluster.
+	exitConnection = 4
+	// exitTimeout is a run where every failed resource exceeded --timeout.
+	exitTimeout = 5
+	// exitInterrupted is a run stopped by SIGINT or SIGTERM.
+	exitInterrupted = 130
+)
+
+// exitCodeError carries the exit code of an error.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+// withExitCode sets the exit code of err, unless err is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{code: code, err: err}
+}
+
+// exitCode returns the exit code for the error of the command: the code set
+// with withExitCode, or the one of the API and network errors it wraps.
+func exitCode(err error) int {
+	var codeErr *exitCodeError
+	var configErr *scaler.ConfigError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &codeErr):
+		return codeErr.code
+	case errors.As(err, &configErr):
+		return exitConfig
+	case apierrors.IsUnauthorized(err), apierrors.IsForbidden(err), errors.As(err, &netErr):
+		return exitConnection
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitError
+}
+
+// failureExitCode returns the exit code of a run where some resources
+// failed: exitTimeout when they all exceeded --timeout, exitPartial
+// otherwise.
+func failureExitCode(report scaler.Report) int {
+	failed := 0
+	for _, res := range report.Results {
+		if res.Status != scaler.StatusFailed {
+			continue
+		}
+		if !errors.Is(res.Err, scaler.ErrTimeout) {
+			return exitPartial
+		}
+		failed++
+	}
+	if failed == 0 {
+		return exitPartial
+	}
+	return exitTimeout
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// jobConfigPath is where the Job of generate job mounts its config.
+const jobConfigPath = "/config/config.yaml"
+
+var (
+	jobImage     string
+	jobName      string
+	jobNamespace string
+	jobRestore   bool
+	generateCmd  = &cobra.Command{
+		Use:   "generate",
+		Short: "Render manifests for running the plugin from inside the cluster",
+	}
+	generateJobCmd = &cobra.Command{
+		Use:          "job [-- flags of the run]",
+		Short:        "Render a Job scaling down the resources of --file from inside the cluster, with its config and RBAC",
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerateJob(args)
+		},
+	}
+)
+
+func init() {
+	generateJobCmd.Flags().StringVar(&jobImage, "image", "parallel-scale-down:latest", "Image with the kubectl-scale_down binary as entrypoint")
+	generateJobCmd.Flags().StringVar(&jobName, "name", "parallel-scale-down", "Name of the Job, its ConfigMap and RBAC objects")
+	generateJobCmd.Flags().StringVar(&jobNamespace, "job-namespace", "scale-down", "Namespace of the Job, its ConfigMap and ServiceAccount, also holding the cluster lock")
+	generateJobCmd.Flags().BoolVar(&jobRestore, "restore", false, "Render a Job restoring the resources instead of scaling them down")
+	generateCmd.AddCommand(generateJobCmd)
+	rootCmd.AddCommand(generateCmd)
+}
+
+// runGenerateJob writes the manifests of a Job running the plugin in the
+// cluster to stdout: the config of --file in a ConfigMap, a ServiceAccount
+// with the permissions of the operator, and the Job itself. args are added
+// to the arguments of the run.
+func runGenerateJob(args []string) error {
+	if len(inputFilePaths) == 0 {
+		return withExitCode(exitConfig, fmt.Errorf(`required flag "file" not set`))
+	}
+	config, err := loadConfigs(inputFilePaths)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if config.MultiCluster() {
+		return withExitCode(exitConfig, fmt.Errorf("the config names contexts, while a Job only runs in its own cluster: render a Job per cluster"))
+	}
+	// The Job runs in --job-namespace, so the items without a namespace are
+	// given the one they would get from a workstation now.
+	namespace, explicit, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("error resolving namespace: %v", err)
+	}
+	config.ApplyDefaultNamespace(namespace, explicit)
+
+	var data bytes.Buffer
+	enc := yaml.NewEncoder(&data)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return err
+	}
+
+	mode := scaler.ModeScaleDown
+	if jobRestore {
+		mode = scaler.ModeRestore
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by parallel-scale-down generate job to %s the resources of the config.\n", mode)
+	for i, obj := range jobManifests(data.String(), args) {
+		manifest, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(manifest)
+	}
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
+
+// jobManifests returns the objects of generate job. The RBAC objects do not
+// depend on the mode, so that the Jobs of a scale down and its restore can be
+// applied together.
+func jobManifests(config string, args []string) []interface{} {
+	meta := func(name, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": "parallel-scale-down", "app.kubernetes.io/instance": jobName},
+		}
+	}
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: jobName, Namespace: jobNamespace}
+
+	name := jobName
+	runArgs := []string{"--file=" + jobConfigPath, "--yes", "--lock-namespace=" + jobNamespace}
+	if jobRestore {
+		name += "-restore"
+		runArgs = append([]string{"restore"}, runArgs...)
+	}
+	runArgs = append(runArgs, args...)
+
+	return []interface{}{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta(jobName, jobNamespace),
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: meta(jobName, ""),
+			Rules:      jobRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: meta(jobName, ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: jobName},
+			Subjects:   []rbacv1.Subject{subject},
+		},
+		// The cluster lock is a Lease of --lock-namespace.
+		&rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: meta(jobName, jobNamespace),
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "create", "update", "delete"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: meta(jobName, jobNamespace),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: jobName},
+			Subjects:   []rbacv1.Subject{subject},
+		},
+		&corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: meta(jobName+"-config", jobNamespace),
+			Data:       map[string]string{"config.yaml": config},
+		},
+		&batchv1.Job{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: meta(name, jobNamespace),
+			Spec: batchv1.JobSpec{
+				// A failed run is reported, not retried with the same
+				// config: it may need a --resume or a rollback.
+				BackoffLimit: ptr.To[int32](0),
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/name": "parallel-scale-down", "app.kubernetes.io/instance": jobName}},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: jobName,
+						RestartPolicy:      corev1.RestartPolicyNever,
+						Containers: []corev1.Container{{
+							Name:  "scale-down",
+							Image: jobImage,
+							Args:  runArgs,
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "config", MountPath: "/config", ReadOnly: true},
+							},
+						}},
+						Volumes: []corev1.Volume{{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: jobName + "-config"}},
+							},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// jobRules are the permissions of the Job, the same as the ones of the
+// operator in deploy/rbac.yaml without its ScaleDownPlans.
+var jobRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets"}, Verbs: []string{"get", "list", "watch", "patch"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments/scale", "statefulsets/scale"}, Verbs: []string{"get", "patch"}},
+	{APIGroups: []string{""}, Resources: []string{"replicationcontrollers"}, Verbs: []string{"get", "list", "watch", "patch"}},
+	{APIGroups: []string{""}, Resources: []string{"replicationcontrollers/scale"}, Verbs: []string{"get", "patch"}},
+	{APIGroups: []string{"apps.openshift.io"}, Resources: []string{"deploymentconfigs"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{"apps.openshift.io"}, Resources: []string{"deploymentconfigs/scale"}, Verbs: []string{"get", "patch"}},
+	{APIGroups: []string{"argoproj.io"}, Resources: []string{"rollouts"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{"argoproj.io"}, Resources: []string{"rollouts/scale"}, Verbs: []string{"get", "patch"}},
+	{APIGroups: []string{"serving.knative.dev"}, Resources: []string{"services"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{"batch"}, Resources: []string{"cronjobs"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"get", "list", "patch", "delete"}},
+	{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"list", "create", "delete"}},
+	{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+	{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}},
+	{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get", "list", "patch"}},
+	{APIGroups: []string{"discovery.k8s.io"}, Resources: []string{"endpointslices"}, Verbs: []string{"list"}},
+	{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"list"}},
+	{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"volumeattachments"}, Verbs: []string{"list"}},
+	{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}},
+	{APIGroups: []string{"authorization.k8s.io"}, Resources: []string{"selfsubjectaccessreviews"}, Verbs: []string{"create"}},
+}
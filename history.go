@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	historyPath  string
+	historyLimit int
+	// undoRun is the run restored by the undo command.
+	undoRun string
+
+	historyCmd = &cobra.Command{
+		Use:          "history",
+		Short:        "List the past runs recorded in --history-file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistory()
+		},
+	}
+	showCmd = &cobra.Command{
+		Use:          "show <runID>",
+		Short:        "Print the inputs, results and timings of a past run recorded in --history-file",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runShow(args[0])
+		},
+	}
+	undoCmd = &cobra.Command{
+		Use:          "undo <runID>",
+		Short:        "Restore the resources of a past scale down recorded in --history-file to their original replica counts",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			undoRun = args[0]
+			return run(cmd, scaler.ModeRestore)
+		},
+	}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&historyPath, "history-file", defaultHistoryPath(), "File recording every run with its inputs, results and timings, one JSON object per line, for the history, show and undo commands (empty disables it)")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Number of most recent runs to list (0 lists every run)")
+	rootCmd.AddCommand(historyCmd, showCmd, undoCmd)
+}
+
+// defaultHistoryPath keeps the history next to the kubeconfig.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "parallel-scale-down", "history.jsonl")
+}
+
+// historyEntry is a run recorded in --history-file: its report, and the
+// config and files it ran with.
+type historyEntry struct {
+	ID string `json:"id"`
+	reportFile
+	Files  []string      `json:"files,omitempty"`
+	Config scaler.Config `json:"config"`
+}
+
+// recordHistory appends a run to --history-file. A failure only raises a
+// warning, so that it does not fail the run.
+func recordHistory(mode scaler.Mode, clusters []*cluster, state *scaler.State, report scaler.Report, runErr error, start, end time.Time) {
+	if historyPath == "" {
+		return
+	}
+	entry := historyEntry{
+		ID:         currentRunID,
+		reportFile: newReportFile(mode, describeClusters(clusters), state, report, runErr, start, end),
+		Files:      inputFilePaths,
+		Config:     effectiveConfig(clusters),
+	}
+	if err := appendHistory(entry); err != nil {
+		logger.Warn("Could not record the run in the history", "path", historyPath, "error", err)
+		return
+	}
+	logger.Debug("Run recorded in the history", "path", historyPath)
+}
+
+func appendHistory(entry historyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readHistory returns the runs of --history-file, oldest first. Lines that
+// cannot be read, e.g. the last line of a run killed while writing it, are
+// skipped with a warning.
+func readHistory() ([]historyEntry, error) {
+	if historyPath == "" {
+		return nil, fmt.Errorf("--history-file is empty, no history is recorded")
+	}
+	f, err := os.Open(historyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history file: %v", err)
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logger.Warn("Skipping an unreadable history entry", "path", historyPath, "line", line, "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history file: %v", err)
+	}
+	return entries, nil
+}
+
+// findRun returns the run with the given ID, or the most recent run for
+// latest.
+func findRun(id string) (*historyEntry, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ID == id || (id == scaler.LatestRun && i == len(entries)-1) {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("run %s not found in %s", id, historyPath)
+}
+
+// historyStatus summarizes the outcome of a run.
+func historyStatus(entry historyEntry) string {
+	switch {
+	case entry.Interrupted:
+		return "interrupted"
+	case entry.Success:
+		return "success"
+	}
+	return "failed"
+}
+
+func runHistory() error {
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+	if historyLimit > 0 && len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+	if outputFormat != outputText {
+		list := []historyEntry{}
+		for i := len(entries) - 1; i >= 0; i-- {
+			list = append(list, entries[i])
+		}
+		writeJSON(list)
+		return nil
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(textOut, "No runs recorded in %s.\n", historyPath)
+		return nil
+	}
+	w := tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tSTARTED\tDURATION\tMODE\tRESOURCES\tSTATUS\tCONTEXT")
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.StartTime.Local().Format(time.DateTime), e.EndTime.Sub(e.StartTime).Round(time.Second), e.Mode, strconv.Itoa(len(e.Results)), historyStatus(e), e.Context)
+	}
+	return w.Flush()
+}
+
+func runShow(id string) error {
+	entry, err := findRun(id)
+	if err != nil {
+		return err
+	}
+	if outputFormat != outputText {
+		writeJSON(entry)
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if data, err = yaml.JSONToYAML(data); err != nil {
+		return err
+	}
+	_, err = textOut.Write(data)
+	return err
+}
+
+// loadUndoRecord reads the scale down restored by the undo command, with
+// its config and original replica counts.
+func loadUndoRecord() (*scaler.StateRecord, error) {
+	if undoRun == "" {
+		return nil, nil
+	}
+	entry, err := findRun(undoRun)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Mode != string(scaler.ModeScaleDown) {
+		return nil, fmt.Errorf("run %s is a %s, only scale downs can be undone", entry.ID, entry.Mode)
+	}
+	logger.Info("Undoing a scale down", "run", entry.ID, "status", historyStatus(*entry), "started", entry.StartTime.Local().Format(time.RFC3339))
+	return &scaler.StateRecord{
+		RunID:     entry.ID,
+		StartedAt: entry.StartTime,
+		Config:    entry.Config,
+		State: &scaler.State{
+			Deployments:            entry.Deployments,
+			StatefulSets:           entry.StatefulSets,
+			ReplicationControllers: entry.ReplicationControllers,
+			DeploymentConfigs:      entry.DeploymentConfigs,
+			Custom:                 entry.Custom,
+		},
+	}, nil
+}
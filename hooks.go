@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// kubectlExec runs the commands of exec hooks with kubectl exec, forwarding
+// the connection flags of a cluster.
+func kubectlExec(flags *genericclioptions.ConfigFlags) scaler.PodExecFunc {
+	return func(ctx context.Context, namespace, pod, container string, command []string) ([]byte, error) {
+		args := kubectlConnectionArgs(flags)
+		args = append(args, "exec", "--namespace", namespace, pod)
+		if container != "" {
+			args = append(args, "--container", container)
+		}
+		args = append(args, "--")
+		args = append(args, command...)
+		return exec.CommandContext(ctx, "kubectl", args...).CombinedOutput()
+	}
+}
+
+// kubectlConnectionArgs returns the connection flags that were set, so that
+// kubectl talks to the same cluster with the same identity as the plugin.
+func kubectlConnectionArgs(configFlags *genericclioptions.ConfigFlags) []string {
+	var args []string
+	for _, flag := range []struct {
+		name  string
+		value *string
+	}{
+		{"kubeconfig", configFlags.KubeConfig},
+		{"context", configFlags.Context},
+		{"cluster", configFlags.ClusterName},
+		{"user", configFlags.AuthInfoName},
+		{"server", configFlags.APIServer},
+		{"token", configFlags.BearerToken},
+		{"certificate-authority", configFlags.CAFile},
+		{"client-certificate", configFlags.CertFile},
+		{"client-key", configFlags.KeyFile},
+		{"as", configFlags.Impersonate},
+		{"as-uid", configFlags.ImpersonateUID},
+	} {
+		if flag.value != nil && *flag.value != "" {
+			args = append(args, "--"+flag.name+"="+*flag.value)
+		}
+	}
+	if configFlags.ImpersonateGroup != nil {
+		for _, group := range *configFlags.ImpersonateGroup {
+			args = append(args, "--as-group="+group)
+		}
+	}
+	if configFlags.Insecure != nil && *configFlags.Insecure {
+		args = append(args, "--insecure-skip-tls-verify")
+	}
+	return args
+}
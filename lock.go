@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	forceLock     bool
+	noLock        bool
+	lockNamespace string
+)
+
+// acquireLock takes the cluster lock for the run. It returns a function
+// releasing it, or nil with --no-lock and --dry-run.
+func acquireLock(ctx context.Context, c *cluster, mode scaler.Mode) (func(), error) {
+	if noLock || dryRun {
+		return nil, nil
+	}
+	logger := logger
+	if c.name != "" {
+		logger = logger.With("cluster", c.name)
+	}
+	lock, err := scaler.AcquireLock(ctx, c.clientset, scaler.LockOptions{
+		Namespace: lockNamespace,
+		Holder:    lockHolder(),
+		Mode:      mode,
+		Force:     forceLock,
+		OnLost: func(holder string) {
+			logger.Warn("The cluster lock was taken over by another run", "holder", holder)
+		},
+	})
+	var held *scaler.LockHeldError
+	if errors.As(err, &held) {
+		return nil, c.wrap(fmt.Errorf("%v, use --force to take the lock over", held))
+	}
+	if err != nil {
+		return nil, c.wrap(fmt.Errorf("error acquiring the cluster lock: %v", err))
+	}
+	if lock.StolenFrom != "" {
+		logger.Warn("Took the cluster lock over", "holder", lock.StolenFrom)
+	}
+	logger.Debug("Acquired the cluster lock", "lease", lockNamespace+"/"+scaler.LockName)
+
+	return func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := lock.Release(releaseCtx); err != nil {
+			logger.Warn("Failed to release the cluster lock", "error", err)
+		}
+	}, nil
+}
+
+// lockHolder identifies this run to the engineers it keeps out, and in the
+// Kubernetes Events recorded on scaled resources.
+func lockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	holder := fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+	if configFlags.Impersonate != nil && *configFlags.Impersonate != "" {
+		holder += ", as " + *configFlags.Impersonate
+	}
+	return holder
+}
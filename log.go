@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var (
+	verbosity int
+	quiet     bool
+	logFormat string
+
+	// logger receives progress and status messages. It is replaced by
+	// setupLogging once the flags are parsed.
+	logger = slog.New(slog.DiscardHandler)
+)
+
+// setupLogging builds the logger for --log-format, -v and --quiet, writing
+// to w. Logs of the Kubernetes client libraries are routed to it as well.
+func setupLogging(w io.Writer) error {
+	if quiet && verbosity > 0 {
+		return fmt.Errorf("--quiet and -v cannot be combined")
+	}
+	level := slog.LevelInfo
+	switch {
+	case quiet:
+		level = slog.LevelWarn
+	case verbosity > 0:
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case logFormatText:
+		logger = slog.New(&consoleHandler{out: w, level: level, mu: &sync.Mutex{}})
+	case logFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(w, opts))
+	default:
+		return fmt.Errorf("unsupported log format %q, must be one of: text, json", logFormat)
+	}
+
+	// From -v=2 on, the verbosity is passed on to client-go, e.g. to log
+	// every API request at -v=6.
+	var klogFlags flag.FlagSet
+	klog.InitFlags(&klogFlags)
+	if verbosity > 1 {
+		_ = klogFlags.Set("v", strconv.Itoa(verbosity))
+	}
+	klog.SetSlogLogger(logger)
+	return nil
+}
+
+// logEvent logs a progress event with the resource as fields.
+func logEvent(ev scaler.Event) {
+	level := slog.LevelInfo
+	switch ev.Type {
+	case scaler.EventStarted:
+		level = slog.LevelDebug
+	case scaler.EventWarning:
+		level = slog.LevelWarn
+	case scaler.EventFailed:
+		level = slog.LevelError
+	}
+	attrs := []any{slog.String("event", string(ev.Type))}
+	if ev.Target.Cluster != "" {
+		attrs = append(attrs, slog.String("cluster", ev.Target.Cluster))
+	}
+	if ev.Target.Item.Name != "" {
+		attrs = append(attrs,
+			slog.String("kind", ev.Target.Label()),
+			slog.String("namespace", ev.Target.Item.Namespace),
+			slog.String("name", ev.Target.Item.Name),
+		)
+	}
+	if ev.Type == scaler.EventWave || ev.Target.Wave != 0 {
+		attrs = append(attrs, slog.Int("wave", ev.Target.Wave))
+	}
+	logger.Log(context.Background(), level, ev.Message, attrs...)
+}
+
+// consoleHandler formats records for a terminal: the resource as a
+// "[namespace/name]" prefix ("[cluster:namespace/name]" in multi-cluster
+// runs), other warnings and errors marked as such, and the remaining fields
+// as key=value pairs.
+type consoleHandler struct {
+	out   io.Writer
+	level slog.Level
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+// prefixWidth is the width the resource prefixes of the console are padded
+// to, so that the messages of every resource of a run are aligned.
+var prefixWidth atomic.Int64
+
+// alignPrefixes pads the resource prefixes of the console to the longest
+// prefix of the targets.
+func alignPrefixes(targets []scaler.Target) {
+	width := 0
+	for _, t := range targets {
+		ref := t.Item.Namespace + "/" + t.Item.Name
+		if t.Cluster != "" {
+			ref = t.Cluster + ":" + ref
+		}
+		width = max(width, len(ref)+2)
+	}
+	prefixWidth.Store(int64(width))
+}
+
+// consoleHiddenKeys are fields that are already part of the prefix, or too
+// noisy to show interactively.
+var consoleHiddenKeys = map[string]bool{"event": true, "cluster": true, "kind": true, "namespace": true, "name": true, "wave": true}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := map[string]string{}
+	var extra []string
+	add := func(a slog.Attr) bool {
+		value := a.Value.Resolve().String()
+		fields[a.Key] = value
+		if !consoleHiddenKeys[a.Key] {
+			if strings.ContainsAny(value, " \t\"=") {
+				value = strconv.Quote(value)
+			}
+			extra = append(extra, a.Key+"="+value)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		// The ID of the run is part of its first line only.
+		if a.Key != "run" {
+			add(a)
+		}
+	}
+	r.Attrs(add)
+
+	color := eventColor(fields["event"])
+	switch {
+	case r.Level >= slog.LevelError:
+		color = colorRed
+	case r.Level >= slog.LevelWarn:
+		color = colorYellow
+	}
+
+	var b strings.Builder
+	if fields["cluster"] != "" && fields["name"] == "" {
+		fmt.Fprintf(&b, "[%s] ", fields["cluster"])
+	}
+	switch {
+	case fields["name"] != "":
+		ref := fields["namespace"] + "/" + fields["name"]
+		if fields["cluster"] != "" {
+			ref = fields["cluster"] + ":" + ref
+		}
+		fmt.Fprintf(&b, "%-*s ", int(prefixWidth.Load()), "["+ref+"]")
+	case r.Level >= slog.LevelError:
+		b.WriteString(colorize(color, "Error: "))
+	case r.Level >= slog.LevelWarn:
+		b.WriteString(colorize(color, "Warning: "))
+	}
+	b.WriteString(colorize(color, r.Message))
+	for _, e := range extra {
+		b.WriteString(" " + e)
+	}
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+// WithGroup is not supported; grouped fields are shown without their group.
+func (h *consoleHandler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -2,331 +2,1063 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
-	"sync"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/retry"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+
+	"parallel-scale-down/pkg/metrics"
+	"parallel-scale-down/pkg/notify"
+	"parallel-scale-down/pkg/scaler"
+	"parallel-scale-down/pkg/tracing"
 )
 
 var (
-	inputFilePath string
-	rootCmd       = &cobra.Command{
-		Use:          "parallel-scale-down",
-		Short:        "Scale down deployments and statefulsets in parallel",
+	configFlags      = newConfigFlags()
+	inputFilePaths   []string
+	allInNamespace   bool
+	onlyRefs         []string
+	excludeRefs      []string
+	onlyGroups       []string
+	stateFilePath    string
+	dryRun           bool
+	serverDryRun     bool
+	maxConcurrency   int
+	maxPerNamespace  int
+	maxUnavailable   string
+	pauseHPA         bool
+	respectPDB       bool
+	ignorePDB        bool
+	suspendGitOps    bool
+	suspendFlux      bool
+	argoNamespace    string
+	rollback         bool
+	onError          string
+	restoreOrder     string
+	waitFor          string
+	recordEvents     bool
+	forceDeleteAfter time.Duration
+	evict            bool
+	watchResets      time.Duration
+	pollInterval     time.Duration
+	maxPollErrors    int
+	rollbackOnInt    bool
+	timeout          time.Duration
+	qps              float32
+	burst            int
+	retryAttempts    int
+	retryBackoff     time.Duration
+	retries          int
+	retriesDelay     time.Duration
+	admissionRetries int
+	admissionDelay   time.Duration
+	outputFormat     string
+	checkpointPath   string
+	resume           bool
+	skipPreflight    bool
+	ignoreMissing    bool
+	metricsAddr      string
+	pushgatewayURL   string
+	pushgatewayJob   string
+	rootCmd          = &cobra.Command{
+		Use:           "scale-down",
+		Short:         "Scale down deployments and statefulsets in parallel",
+		Annotations:   map[string]string{cobra.CommandDisplayNameAnnotation: "kubectl scale-down"},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, scaler.ModeScaleDown)
+		},
+	}
+	restoreCmd = &cobra.Command{
+		Use:          "restore",
+		Short:        "Restore deployments and statefulsets to their previous replica counts in parallel",
 		SilenceUsage: true,
-		RunE:         run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, scaler.ModeRestore)
+		},
 	}
 )
 
 func init() {
-	rootCmd.Flags().StringVar(&inputFilePath, "file", "", "Path to the input yaml file containing list of deployments and statefulsets")
-	_ = rootCmd.MarkFlagRequired("file")
+	rootCmd.PersistentFlags().StringArrayVar(&inputFilePaths, "file", nil, "Path or URL (http(s)://, s3://, gs://) of the input yaml file containing list of deployments and statefulsets, or - to read it from stdin (can be repeated to merge several files, or name a directory of files)")
+	rootCmd.PersistentFlags().StringVar(&configFormat, "format", configFormatAuto, "Format of the --file sources: yaml, json, or auto to read files with a .json extension as JSON and others as YAML")
+	rootCmd.PersistentFlags().BoolVar(&allInNamespace, "all", false, "Scale every deployment and statefulset of the namespace given with --namespace, in addition to --file")
+	rootCmd.Flags().StringSliceVar(&nodeNames, "nodes", nil, "Also scale down the deployments and statefulsets with pods on these nodes, for a node maintenance (comma separated or repeated)")
+	rootCmd.PersistentFlags().StringSliceVar(&onlyRefs, "only", nil, "Only scale these resources of the config, as name or namespace/name (comma separated or repeated)")
+	rootCmd.PersistentFlags().StringSliceVar(&excludeRefs, "exclude", nil, "Do not scale these resources of the config, as name or namespace/name (comma separated or repeated)")
+	rootCmd.PersistentFlags().StringSliceVar(&onlyGroups, "group", nil, "Only scale the resources of the config tagged with one of these groups (comma separated or repeated)")
+	rootCmd.PersistentFlags().StringVar(&stateFilePath, "state-file", "", "Path to a state file where original replica counts are saved on scale down and read from on restore")
+	rootCmd.PersistentFlags().StringVar(&stateNamespace, "state-namespace", "", "Keep the original replica counts and status of every scale down in a ConfigMap of this namespace, so that any machine can restore it")
+	restoreCmd.Flags().StringVar(&stateRun, "state-run", "", "Restore the scale down with this run ID, or latest, from the ConfigMaps of --state-namespace")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the plan with current and target replicas without changing anything")
+	rootCmd.PersistentFlags().BoolVar(&serverDryRun, "server-dry-run", false, "Like --dry-run, and also submit every replica change to the API server with dryRun=All, so that validation and admission webhooks check it without anything being persisted")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before changing anything")
+	rootCmd.Flags().StringVar(&maxUnavailable, "max-unavailable", "", "Maximum number of resources scaled down at the same time across the whole run, as a count or a percentage of the resources such as 30% (overrides maxUnavailable of the config)")
+	rootCmd.PersistentFlags().IntVar(&maxConcurrency, "max-concurrency", 0, "Maximum number of resources scaled at the same time (0 means no limit)")
+	rootCmd.PersistentFlags().IntVar(&maxPerNamespace, "max-per-namespace", 0, "Maximum number of resources of a namespace scaled at the same time, without holding up the other namespaces (0 means no limit)")
+	rootCmd.PersistentFlags().DurationVar(&pollInterval, "poll-interval", scaler.DefaultPollInterval, "How often the resources that cannot be watched, such as custom resources, are polled while waiting for their target replicas. Polls are jittered and back off while nothing changes")
+	rootCmd.PersistentFlags().IntVar(&maxPollErrors, "max-poll-errors", 0, "Fail the wait of a resource once more than this many of its polls failed in a row with throttling, server errors or timeouts (0 retries them until --timeout)")
+	rootCmd.PersistentFlags().DurationVar(&forceDeleteAfter, "force-delete-stuck-after", 0, "Force delete pods of the scaled resources that are still terminating after this long, with a grace period of 0 (0 means never)")
+	rootCmd.PersistentFlags().StringVar(&waitFor, "wait-for", string(scaler.WaitForReplicas), "When a resource has reached its target: replicas, ready (or readyReplicas) to also wait for its pods to be ready and available, endpoints (or endpointsDrained) to also wait for the Services selecting the pods of the resources scaled down to zero to have no ready endpoints, podsDeleted to also wait for the removed pods to be deleted, or volumesDetached to also wait for the volumes of the removed StatefulSet pods to be detached")
+	rootCmd.PersistentFlags().StringVar(&restoreOrder, "restore-order", string(scaler.RestoreOrderReverse), "Order of the waves on restore: reverse to restore the waves scaled down last first, waiting for each wave to be ready before the next, or forward to keep the order of the scale down")
+	rootCmd.PersistentFlags().StringVar(&onError, "on-error", string(scaler.ErrorPolicyContinue), "What to do when a resource fails: continue, fail-fast or rollback (scale down only)")
+	rootCmd.Flags().BoolVar(&rollback, "rollback-on-failure", false, "Restore all already scaled resources to their original replica counts if any resource fails to scale down")
+	_ = rootCmd.Flags().MarkDeprecated("rollback-on-failure", "use --on-error=rollback instead")
+	rootCmd.Flags().BoolVar(&rollbackOnInt, "rollback-on-interrupt", false, "Restore all already scaled resources to their original replica counts when the run is interrupted by SIGINT or SIGTERM")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Maximum time to wait for each resource to reach its target replicas (0 means no limit)")
+	rootCmd.Flags().BoolVar(&evict, "evict", false, "Evict the pods removed by the scale down through the Eviction API, honoring PodDisruptionBudgets, before setting the target replicas (for resources without a strategy)")
+	rootCmd.Flags().DurationVar(&watchResets, "watch-resets", 0, "Keep checking the replicas of the scaled down resources for this long, e.g. 2m, and warn when another controller raises them back, naming its field manager")
+	rootCmd.Flags().StringToStringVar(&markLabels, "mark-label", nil, "Label set on every scaled down resource during the maintenance and removed on restore, e.g. maintenance.example.com/active=true (comma separated or repeated)")
+	rootCmd.Flags().StringToStringVar(&markAnnotations, "mark-annotation", nil, "Annotation set on every scaled down resource during the maintenance and removed on restore (comma separated or repeated)")
+	rootCmd.Flags().StringVar(&ticket, "ticket", "", "Ticket ID of the maintenance, set in the parallel-scale-down/ticket annotation of every scaled down resource and removed on restore")
+	rootCmd.Flags().StringVar(&reason, "reason", "", "Reason of the maintenance, set in the parallel-scale-down/reason annotation of every scaled down resource and removed on restore")
+	rootCmd.Flags().BoolVar(&pauseHPA, "pause-hpa", false, "Remove HorizontalPodAutoscalers targeting the scaled resources for the maintenance and recreate them on restore")
+	rootCmd.Flags().BoolVar(&respectPDB, "respect-pdb", false, "Refuse to scale down if the target replicas of a resource would violate a PodDisruptionBudget covering its pods")
+	rootCmd.Flags().BoolVar(&ignorePDB, "ignore-pdb", false, "Do not look for PodDisruptionBudgets violated by the target replicas (by default they raise a warning)")
+	rootCmd.Flags().BoolVar(&suspendGitOps, "suspend-gitops", false, "Suspend the reconciliation of Argo CD Applications and Flux Kustomizations and HelmReleases managing the scaled resources, resumed on restore")
+	rootCmd.Flags().BoolVar(&suspendFlux, "suspend-flux", false, "Suspend only the Flux Kustomizations and HelmReleases managing the scaled resources, resumed on restore")
+	rootCmd.PersistentFlags().StringVar(&argoNamespace, "argocd-namespace", scaler.DefaultArgoCDNamespace, "Namespace of the Argo CD Applications")
+	rootCmd.PersistentFlags().Float32Var(&qps, "qps", 50, "Maximum queries per second sent to the API server")
+	rootCmd.PersistentFlags().IntVar(&burst, "burst", 100, "Maximum burst of queries sent to the API server above --qps")
+	rootCmd.PersistentFlags().IntVar(&retryAttempts, "retry-attempts", scaler.DefaultRetry.Steps, "Number of attempts of API requests failing with a conflict, throttling or a transient server error")
+	rootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", scaler.DefaultRetry.Duration, "Wait before the first retry of a failed API request, doubled after every attempt")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0, "Number of times the resources that failed are retried once the others are done, before the run fails")
+	rootCmd.PersistentFlags().DurationVar(&retriesDelay, "retries-delay", 30*time.Second, "Wait before the first retry of the failed resources, doubled after every retry")
+	rootCmd.PersistentFlags().IntVar(&admissionRetries, "admission-retries", 0, "Number of times a resource whose change was denied by an admission webhook or policy is retried, within --timeout")
+	rootCmd.PersistentFlags().DurationVar(&admissionDelay, "admission-retry-delay", time.Minute, "Wait before every retry of a resource denied at admission")
+	rootCmd.PersistentFlags().StringVar(&approvalAddr, "approval-addr", "", "Address to serve POST /approve and /reject on, to approve the waves following a wave with pauseAfter, e.g. :8081")
+	rootCmd.PersistentFlags().StringVar(&approvalTokenFile, "approval-token-file", "", "File holding the bearer token required by the requests of --approval-addr. Required unless --approval-addr is a loopback address, e.g. 127.0.0.1:8081")
+	rootCmd.PersistentFlags().BoolVar(&forceLock, "force", false, "Take the cluster lock over even if another run holds it")
+	rootCmd.PersistentFlags().BoolVar(&noLock, "no-lock", false, "Do not take the cluster lock guarding against concurrent runs")
+	rootCmd.PersistentFlags().StringVar(&lockNamespace, "lock-namespace", scaler.DefaultLockNamespace, "Namespace of the Lease used as cluster lock")
+	rootCmd.PersistentFlags().StringVar(&startAt, "at", "", "Wait until this time (RFC 3339, e.g. 2024-06-01T02:00:00Z) before starting")
+	rootCmd.PersistentFlags().StringVar(&cronSchedule, "schedule", "", "Keep running and start at every tick of this cron expression, e.g. \"0 2 * * SAT\"")
+	rootCmd.Flags().DurationVar(&window, "window", 0, "Restore the resources automatically this long after the start of the scale down, e.g. 4h")
+	rootCmd.PersistentFlags().BoolVar(&recordEvents, "record-events", true, "Record a Kubernetes Event on every scaled resource, naming the user who ran the maintenance")
+	rootCmd.PersistentFlags().BoolVar(&skipPreflight, "skip-preflight", false, "Do not check that every resource exists and can be scaled before changing anything")
+	rootCmd.PersistentFlags().BoolVar(&ignoreMissing, "ignore-missing", false, "Skip the resources that do not exist instead of failing, as if every item of the config was optional")
+	rootCmd.PersistentFlags().StringVar(&checkpointPath, "checkpoint-file", "", "Path to a checkpoint file recording the resources completed by a failed run")
+	rootCmd.PersistentFlags().BoolVar(&resume, "resume", false, "Skip the resources recorded in --checkpoint-file by a previous failed run")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "Address to expose Prometheus metrics on during the run, e.g. :9090")
+	rootCmd.PersistentFlags().StringVar(&pushgatewayURL, "pushgateway-url", "", "Prometheus Pushgateway to push the metrics of the run to once it finishes, e.g. http://pushgateway:9091")
+	rootCmd.PersistentFlags().StringVar(&pushgatewayJob, "pushgateway-job", "parallel-scale-down", "Job name the metrics are pushed under with --pushgateway-url")
+	rootCmd.PersistentFlags().StringArrayVar(&webhookURLs, "webhook-url", nil, "HTTP endpoint receiving JSON notifications about the run (can be repeated)")
+	rootCmd.PersistentFlags().StringArrayVar(&slackWebhookURLs, "slack-webhook-url", nil, "Slack incoming webhook receiving notifications about the run (can be repeated)")
+	rootCmd.PersistentFlags().StringVar(&reportPath, "report", "", "Path of a JSON (or YAML with a .yaml extension) report of the run with start and end time, replica counts, durations and errors")
+	rootCmd.PersistentFlags().IntVar(&slowest, "slowest", 5, "Number of slowest resources listed with their durations after the run (0 to disable)")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", progressModeAuto, "How progress is shown: table redraws a live table of every resource, status logs the resources in progress every --status-interval instead of their progress events, lines logs every event, auto uses table on a terminal and status otherwise")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json or ndjson")
+	rootCmd.PersistentFlags().IntVarP(&verbosity, "v", "v", 0, "Log verbosity: 1 adds debug messages, 2 and above also raise the verbosity of the Kubernetes client")
+	rootCmd.PersistentFlags().Lookup("v").NoOptDefVal = "1"
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Do not color the console output, e.g. for CI logs (also disabled by the NO_COLOR environment variable)")
+	rootCmd.PersistentFlags().DurationVar(&statusInterval, "status-interval", 30*time.Second, "Interval of the status blocks of --progress=status")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log warnings and errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "Log format: text or json")
+	configFlags.AddFlags(rootCmd.PersistentFlags())
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return withExitCode(exitConfig, err)
+	})
+	rootCmd.AddCommand(restoreCmd)
 }
 
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// newConfigFlags returns the standard kubectl connection flags, including
+// impersonation with --as. The request --timeout of kubectl is left out since
+// --timeout limits the time spent on each resource. The client rate limits
+// come from --qps and --burst, since the client-go defaults throttle large
+// parallel runs.
+func newConfigFlags() *genericclioptions.ConfigFlags {
+	flags := genericclioptions.NewConfigFlags(true)
+	flags.Timeout = nil
+	flags.WrapConfigFn = func(config *rest.Config) *rest.Config {
+		config.QPS = qps
+		config.Burst = burst
+		// Audit logs record the user agent of every request, next to the
+		// impersonated user of --as.
+		config.UserAgent = scaler.FieldManager + " " + rest.DefaultKubernetesUserAgent()
+		// API calls made while tracing a run are recorded as its spans.
+		config.Wrap(tracing.Transport)
+		return config
 	}
+	return flags
 }
 
-func run(cmd *cobra.Command, args []string) error {
-	config, err := readConfigFile(inputFilePath)
-	if err != nil {
-		return fmt.Errorf("error reading config file: %v", err)
+// retryBackoffFlags returns the backoff set with --retry-attempts and
+// --retry-backoff.
+func retryBackoffFlags() (wait.Backoff, error) {
+	if retryAttempts < 1 {
+		return wait.Backoff{}, fmt.Errorf("--retry-attempts must be at least 1")
 	}
+	backoff := scaler.DefaultRetry
+	backoff.Steps = retryAttempts
+	backoff.Duration = retryBackoff
+	return backoff, nil
+}
 
-	kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
-		&clientcmd.ConfigOverrides{},
-	).ClientConfig()
+func main() {
+	ctx, stop := signalContext()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		return fmt.Errorf("error building kubeconfig: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
+}
 
-	clientset, err := kubernetes.NewForConfig(kubeConfig)
+// signalContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM, so that the run stops waiting and prints what was already changed.
+// A second signal exits immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Warn("Received signal, stopping the run. Send it again to exit immediately.", "signal", sig.String())
+		cancel()
+		<-signals
+		os.Exit(130)
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+func run(cmd *cobra.Command, mode scaler.Mode) error {
+	if serverDryRun {
+		dryRun = true
+	}
+	if err := setOutputFormat(outputFormat); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if err := validateSummaryFormat(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	setupColor(textOut)
+	if err := setupProgress(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if err := setupLogging(textOut); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if resume && checkpointPath == "" {
+		return withExitCode(exitConfig, fmt.Errorf("--resume requires --checkpoint-file"))
+	}
+	errorPolicy, err := parseErrorPolicy(mode)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	waitCondition, err := parseWaitFor()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if order := scaler.RestoreOrder(restoreOrder); order != scaler.RestoreOrderReverse && order != scaler.RestoreOrderForward {
+		return withExitCode(exitConfig, fmt.Errorf("unsupported --restore-order %q, must be one of: reverse, forward", restoreOrder))
+	}
+	pdbPolicy, err := parsePDBPolicy()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	backoff, err := retryBackoffFlags()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if pollInterval <= 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--poll-interval must be positive"))
+	}
+	if maxPollErrors < 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--max-poll-errors must not be negative"))
+	}
+	if retries < 0 || retriesDelay < 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--retries and --retries-delay must not be negative"))
+	}
+	if admissionRetries < 0 || admissionDelay < 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--admission-retries and --admission-retry-delay must not be negative"))
+	}
+	if maxUnavailable != "" {
+		if _, err := scaler.ParseMaxUnavailable(maxUnavailable, 1); err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("invalid --max-unavailable: %v", err))
+		}
+	}
+	marks, err := maintenanceMarks()
 	if err != nil {
-		return fmt.Errorf("error creating clientset: %v", err)
+		return withExitCode(exitConfig, err)
+	}
+	if err := validateRunID(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if slices.Contains(inputFilePaths, "-") && !assumeYes && !dryRun {
+		return withExitCode(exitConfig, fmt.Errorf("--file - reads the config from stdin, which then cannot answer the confirmation: use --yes"))
 	}
 
-	return runScaleDown(cmd.Context(), clientset, config)
-}
+	stateStore, err := newStateStore()
+	if err != nil {
+		return err
+	}
+	var record *scaler.StateRecord
+	if stateStore != nil {
+		if undoRun != "" {
+			return withExitCode(exitConfig, fmt.Errorf("undo cannot be combined with --state-namespace, use restore --state-run instead"))
+		}
+		if record, err = loadStateRecord(cmd.Context(), stateStore); err != nil {
+			return err
+		}
+	}
+	if undoRun != "" {
+		if record, err = loadUndoRecord(); err != nil {
+			return err
+		}
+	}
 
-type Config struct {
-	Deployments  []ResourceItem `yaml:"deployments"`
-	StatefulSets []ResourceItem `yaml:"statefulsets"`
-}
+	var config *scaler.Config
+	switch {
+	case len(inputFilePaths) == 0 && record != nil:
+		// The replicas of the config are the scale down targets.
+		restoreConfig := record.Config.WithoutReplicas()
+		config = &restoreConfig
+	case len(inputFilePaths) == 0 && !allInNamespace && len(nodeNames) == 0:
+		return withExitCode(exitConfig, fmt.Errorf(`required flag "file" not set`))
+	default:
+		if config, err = loadConfigs(inputFilePaths); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+	}
 
-type ResourceItem struct {
-	Name      string            `yaml:"name"`
-	Namespace string            `yaml:"namespace"`
-	Replicas  *int32            `yaml:"replicas"`
-	Labels    map[string]string `yaml:"labels"`
-}
+	clusters, err := newClusters(config)
+	if err != nil {
+		return withExitCode(exitConnection, err)
+	}
+	if err := addNodeWorkloads(cmd.Context(), clusters); err != nil {
+		return err
+	}
 
-func readConfigFile(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	recorder, err := startMetrics()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
+	stopTracing, err := startTracing(cmd.Context())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &cfg, nil
-}
+	defer stopTracing()
 
-func resolveResources(ctx context.Context, clientset *kubernetes.Clientset, items []ResourceItem, kind string) ([]ResourceItem, error) {
-	var result []ResourceItem
-	for _, item := range items {
-		if item.Name != "" {
-			result = append(result, item)
-			continue
-		}
-		if len(item.Labels) > 0 {
-			selector := labels.SelectorFromSet(item.Labels).String()
-			listOpts := metav1.ListOptions{LabelSelector: selector}
-
-			if kind == "deployment" {
-				list, err := clientset.AppsV1().Deployments(item.Namespace).List(ctx, listOpts)
-				if err != nil {
-					return nil, fmt.Errorf("failed to list deployments with labels %v: %w", item.Labels, err)
-				}
-				for _, d := range list.Items {
-					newItem := item
-					newItem.Name = d.Name
-					result = append(result, newItem)
-				}
-			} else if kind == "statefulset" {
-				list, err := clientset.AppsV1().StatefulSets(item.Namespace).List(ctx, listOpts)
-				if err != nil {
-					return nil, fmt.Errorf("failed to list statefulsets with labels %v: %w", item.Labels, err)
-				}
-				for _, s := range list.Items {
-					newItem := item
-					newItem.Name = s.Name
-					result = append(result, newItem)
-				}
-			}
-		}
+	notifier := newNotifier()
+	if notifier != nil {
+		defer notifier.Close()
 	}
-	return result, nil
-}
 
-func runScaleDown(ctx context.Context, clientset *kubernetes.Clientset, config *Config) error {
-	deployments, err := resolveResources(ctx, clientset, config.Deployments, "deployment")
+	approvals, err = newApprover()
 	if err != nil {
 		return err
 	}
-	if len(deployments) > 0 {
-		fmt.Println("\nDeployments to be scaled down:")
+
+	r := &runner{
+		clusters:   clusters,
+		notifier:   notifier,
+		recorder:   recorder,
+		stateStore: stateStore,
+		record:     record,
+		options: scaler.Options{
+			MaxConcurrency:        maxConcurrency,
+			MaxPerNamespace:       maxPerNamespace,
+			PauseHPA:              pauseHPA,
+			PDBPolicy:             pdbPolicy,
+			SuspendGitOps:         suspendGitOps,
+			SuspendFlux:           suspendFlux,
+			ArgoCDNamespace:       argoNamespace,
+			Timeout:               timeout,
+			WaitFor:               waitCondition,
+			ForceDeleteStuckAfter: forceDeleteAfter,
+			Evict:                 evict,
+			WatchResets:           watchResets,
+			PollInterval:          pollInterval,
+			MaxPollErrors:         maxPollErrors,
+			Marks:                 marks,
+			Retry:                 backoff,
+			Retries:               retries,
+			RetryDelay:            retriesDelay,
+			AdmissionRetries:      admissionRetries,
+			AdmissionRetryDelay:   admissionDelay,
+			OnError:               errorPolicy,
+			RestoreOrder:          scaler.RestoreOrder(restoreOrder),
+			RollbackOnInterrupt:   rollbackOnInt,
+			SkipPreflight:         skipPreflight,
+			IgnoreMissing:         ignoreMissing,
+			RecordEvents:          recordEvents,
+			Actor:                 lockHolder(),
+		},
 	}
-	for _, d := range deployments {
-		fmt.Printf("- %s/%s\n", d.Namespace, d.Name)
+	if approvals != nil {
+		r.options.Approve = approvals.approve
 	}
+	return runScheduled(cmd.Context(), r, mode)
+}
+
+// runner executes the runs of a command against its clusters. A command
+// executes a single run, unless it is scheduled with --window or --schedule.
+type runner struct {
+	clusters []*cluster
+	notifier *notify.Notifier
+	recorder *metrics.Recorder
+	// options holds the options shared by every cluster and run.
+	options scaler.Options
+	// stateStore keeps the state of the runs in the cluster with
+	// --state-namespace, and record is the state of the current scale down.
+	stateStore *scaler.StateStore
+	record     *scaler.StateRecord
+}
 
-	statefulsets, err := resolveResources(ctx, clientset, config.StatefulSets, "statefulset")
+// execute runs a scale down or restore with the given original replica
+// counts. The restore at the end of a window ignores the replicas of the
+// config, which are the scale down targets.
+func (r *runner) execute(ctx context.Context, mode scaler.Mode, state *scaler.State, windowEnd bool) error {
+	checkpoint, err := loadCheckpoint(mode)
 	if err != nil {
 		return err
 	}
-	if len(statefulsets) > 0 {
-		fmt.Println("\nStatefulSets to be scaled down:")
+	if windowEnd {
+		checkpoint = nil
 	}
-	for _, s := range statefulsets {
-		fmt.Printf("- %s/%s\n", s.Namespace, s.Name)
+
+	onEvent := func(ev scaler.Event) {
+		printEvent(ev)
+		if r.notifier != nil {
+			notifyEvent(r.notifier, mode, ev)
+		}
+		if r.recorder != nil {
+			r.recorder.Event(ev)
+		}
+	}
+	onResult := func(res scaler.Result) {
+		if liveTable != nil {
+			liveTable.result(res)
+		}
+		if r.recorder != nil {
+			r.recorder.Result(mode, res)
+		}
 	}
 
-	var wg sync.WaitGroup
-	totalOps := len(deployments) + len(statefulsets)
-	errChan := make(chan error, totalOps)
-	if totalOps > 0 {
-		fmt.Printf("\n------------------------------------------------\n\n")
+	var budget *scaler.Budget
+	if mode == scaler.ModeScaleDown && maxUnavailableOf(r.clusters) != "" {
+		budget = &scaler.Budget{}
 	}
+	marks := marksAt(r.options.Marks, time.Now(), currentRunID)
 
-	fmt.Printf("Starting parallel scale down...\n\n")
+	// Every line logged during the run carries its ID.
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = logger.With("run", currentRunID)
 
-	for _, d := range deployments {
-		wg.Add(1)
-		go func(r ResourceItem) {
-			defer wg.Done()
-			if err := scaleDownAndWatch(ctx, clientset, r, "deployment"); err != nil {
-				errChan <- fmt.Errorf("Deployment %s/%s: %v", r.Namespace, r.Name, err)
-			}
-		}(d)
+	clusters := make([]*cluster, len(r.clusters))
+	for i, c := range r.clusters {
+		run := *c
+		if windowEnd {
+			run.config = c.config.WithoutReplicas()
+		}
+		opts := r.options
+		opts.Dynamic = c.dynamic
+		opts.Mapper = c.mapper
+		opts.State = state
+		opts.Cluster = c.name
+		opts.PreHook = c.config.PreHook
+		opts.PostHook = c.config.PostHook
+		opts.Nodes = c.config.Nodes
+		opts.PodExec = kubectlExec(c.flags)
+		opts.Checkpoint = checkpoint
+		opts.Unavailable = budget
+		opts.Marks = marks
+		opts.RunID = currentRunID
+		opts.OnEvent = onEvent
+		opts.OnResult = onResult
+		run.scaler = scaler.New(c.clientset, opts)
+		clusters[i] = &run
 	}
 
-	for _, s := range statefulsets {
-		wg.Add(1)
-		go func(r ResourceItem) {
-			defer wg.Done()
-			if err := scaleDownAndWatch(ctx, clientset, r, "statefulset"); err != nil {
-				errChan <- fmt.Errorf("StatefulSet %s/%s: %v", r.Namespace, r.Name, err)
-			}
-		}(s)
+	for _, c := range clusters {
+		release, err := acquireLock(ctx, c, mode)
+		if err != nil {
+			return err
+		}
+		if release != nil {
+			defer release()
+		}
+	}
+
+	keepState := r.stateStore != nil && !dryRun
+	if keepState && mode == scaler.ModeScaleDown {
+		if err := r.startStateRecord(ctx, state); err != nil {
+			return err
+		}
 	}
 
-	wg.Wait()
-	close(errChan)
+	started := time.Now()
+	runErr := runScale(ctx, clusters, r.notifier, r.recorder, state, mode, budget)
+	r.pushMetrics(ctx, mode, runErr, time.Since(started))
 
-	var errors []error
-	for err := range errChan {
-		errors = append(errors, err)
+	if keepState && r.record != nil {
+		if err := r.finishStateRecord(ctx, mode, runErr); err != nil {
+			if runErr != nil {
+				logger.Error("Could not save the run state in the cluster", "error", err)
+			} else {
+				return err
+			}
+		}
 	}
 
-	if len(errors) > 0 {
-		fmt.Println("\n---------------------------------------------------")
-		fmt.Println("The following resources failed to scale down:")
-		for _, err := range errors {
-			fmt.Printf("- %v\n", err)
+	if checkpoint != nil {
+		if err := saveCheckpoint(checkpoint, runErr); err != nil {
+			return err
 		}
-		fmt.Println("---------------------------------------------------")
-		return fmt.Errorf("finished with %d errors", len(errors))
 	}
 
-	fmt.Println("\n---------------------------------------------------")
-	fmt.Println("All deployments and statefulsets are scaled down to target.")
-	fmt.Println("Ready to start the maintenance.")
-	fmt.Println("---------------------------------------------------")
-	return nil
-}
+	if mode == scaler.ModeScaleDown && stateFilePath != "" && !dryRun {
+		if err := state.Save(stateFilePath); err != nil {
+			return fmt.Errorf("error writing state file: %v", err)
+		}
+		logger.Info("Original replica counts saved", "path", stateFilePath)
+	}
 
-func scaleDownAndWatch(ctx context.Context, clientset *kubernetes.Clientset, r ResourceItem, kind string) error {
-	fmt.Printf("[%s/%s] Starting scale down...\n", r.Namespace, r.Name)
+	return runErr
+}
 
-	switch kind {
-	case "deployment":
-		return handleDeployment(ctx, clientset, r)
-	case "statefulset":
-		return handleStatefulSet(ctx, clientset, r)
+// parseErrorPolicy validates --on-error, folding in the deprecated
+// --rollback-on-failure flag.
+func parseErrorPolicy(mode scaler.Mode) (scaler.ErrorPolicy, error) {
+	policy := scaler.ErrorPolicy(onError)
+	if rollback {
+		if policy != scaler.ErrorPolicyContinue && policy != scaler.ErrorPolicyRollback {
+			return "", fmt.Errorf("--rollback-on-failure cannot be combined with --on-error=%s", policy)
+		}
+		policy = scaler.ErrorPolicyRollback
+	}
+	switch policy {
+	case scaler.ErrorPolicyContinue, scaler.ErrorPolicyFailFast:
+	case scaler.ErrorPolicyRollback:
+		if mode != scaler.ModeScaleDown {
+			return "", fmt.Errorf("--on-error=rollback is only supported when scaling down")
+		}
 	default:
-		return fmt.Errorf("unsupported kind: %s", kind)
+		return "", fmt.Errorf("unsupported --on-error policy %q, must be one of: continue, fail-fast, rollback", onError)
 	}
+	return policy, nil
 }
 
-func getTargetReplicas(r ResourceItem) int32 {
-	if r.Replicas == nil {
-		return 0
+// parseWaitFor validates --wait-for.
+func parseWaitFor() (scaler.WaitFor, error) {
+	switch condition := scaler.WaitFor(waitFor); condition {
+	case scaler.WaitForReplicas, scaler.WaitForReady, scaler.WaitForReadyReplicas, scaler.WaitForEndpoints, scaler.WaitForEndpointsDrained, scaler.WaitForPodsDeleted, scaler.WaitForVolumesDetached:
+		return condition, nil
+	case scaler.WaitForCustom:
+		return "", fmt.Errorf("--wait-for=custom requires a condition, set waitFor and condition on the items of the config instead")
 	}
-	return *r.Replicas
+	return "", fmt.Errorf("unsupported --wait-for condition %q, must be one of: replicas, ready, readyReplicas, endpoints, endpointsDrained, podsDeleted, volumesDetached", waitFor)
 }
 
-func handleDeployment(ctx context.Context, clientset *kubernetes.Clientset, r ResourceItem) error {
-	deploymentsClient := clientset.AppsV1().Deployments(r.Namespace)
-	targetReplicas := getTargetReplicas(r)
-
-	var watch = true
-
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		d, err := deploymentsClient.Get(ctx, r.Name, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
+// parsePDBPolicy returns the policy selected by --respect-pdb and
+// --ignore-pdb.
+func parsePDBPolicy() (scaler.PDBPolicy, error) {
+	switch {
+	case respectPDB && ignorePDB:
+		return "", fmt.Errorf("--respect-pdb and --ignore-pdb cannot be combined")
+	case respectPDB:
+		return scaler.PDBPolicyRespect, nil
+	case ignorePDB:
+		return scaler.PDBPolicyIgnore, nil
+	}
+	return scaler.PDBPolicyWarn, nil
+}
 
-		if *d.Spec.Replicas == targetReplicas {
-			fmt.Printf("[%s/%s] Already at %d replicas.\n", r.Namespace, r.Name, targetReplicas)
-			watch = false
-			return nil
-		}
+// maxUnavailableOf returns --max-unavailable, or the maxUnavailable of the
+// config.
+func maxUnavailableOf(clusters []*cluster) string {
+	if maxUnavailable != "" {
+		return maxUnavailable
+	}
+	return clusters[0].config.MaxUnavailable
+}
 
-		d.Spec.Replicas = &targetReplicas
-		_, err = deploymentsClient.Update(ctx, d, metav1.UpdateOptions{})
+// setBudget limits the scale downs of the run to maxUnavailable of its
+// targets at the same time.
+func setBudget(budget *scaler.Budget, value string, targets []scaler.Target) error {
+	limit, total, err := budget.SetMaxUnavailable(value, targets)
+	if err != nil {
 		return err
-	})
+	}
+	if limit < total {
+		logger.Info(fmt.Sprintf("Scaling down at most %d of %d resources at the same time", limit, total))
+	}
+	return nil
+}
 
+// loadCheckpoint returns the checkpoint for this run, or nil without
+// --checkpoint-file. Without --resume, a previous checkpoint is discarded.
+func loadCheckpoint(mode scaler.Mode) (*scaler.Checkpoint, error) {
+	if checkpointPath == "" || dryRun {
+		return nil, nil
+	}
+	if !resume {
+		return &scaler.Checkpoint{}, nil
+	}
+	checkpoint, err := scaler.LoadCheckpoint(checkpointPath)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error reading checkpoint file: %v", err)
 	}
+	if checkpoint.Mode != "" && checkpoint.Mode != mode {
+		return nil, fmt.Errorf("checkpoint file %s was written by a %s run and cannot be resumed by a %s run", checkpointPath, checkpoint.Mode, mode)
+	}
+	return checkpoint, nil
+}
 
-	if !watch {
+// saveCheckpoint keeps the checkpoint of a failed run and removes it once
+// every resource has completed.
+func saveCheckpoint(checkpoint *scaler.Checkpoint, runErr error) error {
+	if runErr == nil {
+		if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing checkpoint file: %v", err)
+		}
 		return nil
 	}
+	if err := checkpoint.Save(checkpointPath); err != nil {
+		return fmt.Errorf("error writing checkpoint file: %v", err)
+	}
+	logger.Info("Checkpoint saved, re-run with --resume to retry the remaining resources", "path", checkpointPath)
+	return nil
+}
 
-	fmt.Printf("[%s/%s] Scaled down command sent. Watching for %d replicas...\n", r.Namespace, r.Name, targetReplicas)
-	return waitForDeploymentScaleDown(ctx, clientset, r, targetReplicas)
+// startMetrics serves Prometheus metrics on --metrics-addr. It returns nil
+// when neither --metrics-addr nor --pushgateway-url is set.
+func startMetrics() (*metrics.Recorder, error) {
+	if metricsAddr == "" && pushgatewayURL == "" || dryRun {
+		return nil, nil
+	}
+	recorder := metrics.NewRecorder()
+	if metricsAddr == "" {
+		return recorder, nil
+	}
+	if err := recorder.Serve(metricsAddr, func(err error) {
+		logger.Warn("Metrics server failed", "error", err)
+	}); err != nil {
+		return nil, fmt.Errorf("error starting metrics server: %v", err)
+	}
+	logger.Info("Serving metrics", "url", metricsAddr+"/metrics")
+	return recorder, nil
 }
 
-func handleStatefulSet(ctx context.Context, clientset *kubernetes.Clientset, r ResourceItem) error {
-	stsClient := clientset.AppsV1().StatefulSets(r.Namespace)
-	targetReplicas := getTargetReplicas(r)
+// pushMetrics records the outcome of a run and pushes the metrics to
+// --pushgateway-url. A failed push only logs a warning, the run itself is
+// done by then.
+func (r *runner) pushMetrics(ctx context.Context, mode scaler.Mode, runErr error, elapsed time.Duration) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Finish(mode, runErr, elapsed)
+	if pushgatewayURL == "" {
+		return
+	}
+	// Push even if the run was interrupted, which is when it matters most.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := r.recorder.Push(ctx, pushgatewayURL, pushgatewayJob); err != nil {
+		logger.Warn("Could not push metrics to the Pushgateway", "error", err)
+		return
+	}
+	logger.Info("Metrics pushed to the Pushgateway", "job", pushgatewayJob)
+}
 
-	var watch = true
+func printEvent(ev scaler.Event) {
+	if outputFormat == outputNDJSON {
+		writeJSON(toJSONEvent(ev))
+		return
+	}
+	// The live table shows the progress of its resources. Warnings and
+	// failures are logged above it as well.
+	if liveTable != nil && liveTable.event(ev) && ev.Type != scaler.EventWarning && ev.Type != scaler.EventFailed {
+		return
+	}
+	logEvent(ev)
+}
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		s, err := stsClient.Get(ctx, r.Name, metav1.GetOptions{})
+func runScale(ctx context.Context, clusters []*cluster, notifier *notify.Notifier, recorder *metrics.Recorder, state *scaler.State, mode scaler.Mode, budget *scaler.Budget) error {
+	targets, err := resolveClusters(ctx, clusters)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		printTargets(targets, mode)
+	}
+	alignPrefixes(targets)
+
+	if dryRun {
+		plan := planClusters(ctx, clusters, mode)
+		if outputFormat != outputText {
+			writePlan(mode, plan)
+			return rejectedChanges(plan)
+		}
+		if serverDryRun {
+			printPlan("Plan (server-side dry run, no changes were persisted):", mode, plan)
+		} else {
+			printPlan("Plan (dry run, no changes will be made):", mode, plan)
+		}
+		return rejectedChanges(plan)
+	}
+
+	if approvals != nil {
+		stopApprovals, err := approvals.serve(targets)
 		if err != nil {
 			return err
 		}
+		defer stopApprovals()
+	}
 
-		if *s.Spec.Replicas == targetReplicas {
-			fmt.Printf("[%s/%s] Already at %d replicas.\n", r.Namespace, r.Name, targetReplicas)
-			watch = false
-			return nil
+	if err := confirm(ctx, clusters, mode, targets); err != nil {
+		return err
+	}
+
+	if budget != nil {
+		if err := setBudget(budget, maxUnavailableOf(clusters), targets); err != nil {
+			return withExitCode(exitConfig, err)
 		}
+	}
 
-		s.Spec.Replicas = &targetReplicas
-		_, err = stsClient.Update(ctx, s, metav1.UpdateOptions{})
-		return err
-	})
+	if len(targets) > 0 && !quiet {
+		fmt.Fprintf(textOut, "\n------------------------------------------------\n\n")
+	}
 
-	if err != nil {
-		return err
+	logger.Info(fmt.Sprintf("Starting parallel %s, run %s...", mode, currentRunID), "resources", len(targets))
+
+	if notifier != nil {
+		notifyStart(notifier, mode, targets)
+	}
+	if recorder != nil {
+		recorder.Start(targets)
+	}
+	start := time.Now()
+	if liveTable != nil {
+		liveTable.start(targets)
+	}
+	runCtx := ctx
+	var span *tracing.Span
+	if tracer != nil {
+		runCtx, span = tracer.Start(ctx, "parallel-scale-down "+string(mode), tracing.String("mode", string(mode)), tracing.String("run", currentRunID), tracing.Int("resources", len(targets)))
+		logger.Info("Tracing the run", "trace", span.TraceID())
+	}
+	report, err := runClusters(runCtx, clusters, mode)
+	span.End(err)
+	end := time.Now()
+	if liveTable != nil {
+		liveTable.stop()
+	}
+	if notifier != nil {
+		notifySummary(notifier, mode, report, err)
+	}
+	if reportPath != "" {
+		if reportErr := writeReportFile(mode, describeClusters(clusters), state, report, err, start, end); reportErr != nil {
+			logger.Error("Failed to write the report", "path", reportPath, "error", reportErr)
+		} else {
+			logger.Info("Report written", "path", reportPath)
+		}
+	}
+	recordHistory(mode, clusters, state, report, err, start, end)
+	if mode == scaler.ModeScaleDown && restoreConfigDir != "" && len(report.Results) > 0 {
+		if path, restoreErr := writeRestoreConfig(clusters, state, report, end); restoreErr != nil {
+			logger.Error("Failed to write the restore config", "dir", restoreConfigDir, "error", restoreErr)
+		} else {
+			logger.Info("Restore config written", "path", path)
+		}
+	}
+	if outputFormat != outputText {
+		writeReport(mode, report, err, end.Sub(start))
+	}
+	if summaryFormat == summaryGitHub {
+		writeGitHubSummary(mode, report, err, end.Sub(start))
+	}
+	if !quiet {
+		printTimings(mode, report, end.Sub(start))
 	}
 
-	if !watch {
-		return nil
+	var preflightErr *scaler.PreflightError
+	if errors.As(err, &preflightErr) {
+		fmt.Fprintln(textOut, "\nPreflight failed, nothing was changed:")
+		for _, p := range preflightErr.Problems {
+			fmt.Fprintf(textOut, "- %s\n", p)
+		}
+		return withExitCode(exitConfig, fmt.Errorf("preflight found %d problems", len(preflightErr.Problems)))
+	}
+
+	if report.Interrupted {
+		printInterrupted(mode, report)
+		return withExitCode(exitInterrupted, err)
+	}
+
+	var hpaErr *scaler.HPAConflictError
+	if errors.As(err, &hpaErr) {
+		fmt.Fprintln(textOut, "\nThe following resources are managed by a HorizontalPodAutoscaler that would revert the scale down:")
+		for _, c := range hpaErr.Conflicts {
+			fmt.Fprintf(textOut, "- %s\n", c)
+		}
+		return withExitCode(exitConfig, fmt.Errorf("found %d resources managed by HorizontalPodAutoscalers, use --pause-hpa to remove them for the maintenance", len(hpaErr.Conflicts)))
 	}
 
-	fmt.Printf("[%s/%s] Scaled down command sent. Watching for %d replicas...\n", r.Namespace, r.Name, targetReplicas)
+	var pdbErr *scaler.PDBViolationError
+	if errors.As(err, &pdbErr) {
+		fmt.Fprintln(textOut, "\nThe target replicas of the following resources would violate a PodDisruptionBudget:")
+		for _, v := range pdbErr.Violations {
+			fmt.Fprintf(textOut, "- %s\n", v)
+		}
+		return withExitCode(exitConfig, fmt.Errorf("found %d PodDisruptionBudget violations, run without --respect-pdb to scale down with a warning", len(pdbErr.Violations)))
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		fmt.Fprintln(textOut, "\n---------------------------------------------------")
+		var denied []scaler.Result
+		var others []scaler.Result
+		for _, res := range failed {
+			var denial *scaler.AdmissionError
+			if errors.As(res.Err, &denial) {
+				denied = append(denied, res)
+			} else {
+				others = append(others, res)
+			}
+		}
+		if len(others) > 0 {
+			fmt.Fprintf(textOut, "The following resources failed to %s:\n", mode)
+			for _, res := range others {
+				fmt.Fprintf(textOut, "- %s %s: %v\n", res.Target.Label(), res.Target.Ref(), res.Err)
+			}
+		}
+		if len(denied) > 0 {
+			fmt.Fprintf(textOut, "The %s of the following resources was denied by an admission policy:\n", mode)
+			for _, res := range denied {
+				fmt.Fprintf(textOut, "- %s %s: %v\n", res.Target.Label(), res.Target.Ref(), res.Err)
+			}
+		}
+		printRollback(report)
+		printResets(report)
+		fmt.Fprintln(textOut, "---------------------------------------------------")
+		return withExitCode(failureExitCode(report), err)
+	}
+	if err != nil {
+		return err
+	}
 
-	return waitForStatefulSetScaleDown(ctx, clientset, r, targetReplicas)
+	fmt.Fprintln(textOut, "\n---------------------------------------------------")
+	if mode == scaler.ModeRestore {
+		fmt.Fprintln(textOut, "All resources are restored to target.")
+		fmt.Fprintln(textOut, "Maintenance is complete.")
+	} else {
+		fmt.Fprintln(textOut, "All resources are scaled to target.")
+		if !printResets(report) {
+			fmt.Fprintln(textOut, "Ready to start the maintenance.")
+		}
+	}
+	fmt.Fprintln(textOut, "---------------------------------------------------")
+	return nil
 }
 
-func waitForDeploymentScaleDown(ctx context.Context, clientset *kubernetes.Clientset, r ResourceItem, targetReplicas int32) error {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+func printRollback(report scaler.Report) {
+	if len(report.Rollback) == 0 {
+		return
+	}
+	if rollbackFailed := report.RollbackFailed(); len(rollbackFailed) > 0 {
+		fmt.Fprintln(textOut, "\nThe following resources could not be rolled back:")
+		for _, res := range rollbackFailed {
+			fmt.Fprintf(textOut, "- %s %s: %v\n", res.Target.Label(), res.Target.Ref(), res.Err)
+		}
+	} else {
+		fmt.Fprintf(textOut, "\nRolled back %d resources to their original replica counts.\n", len(report.Rollback))
+	}
+}
 
-	for range ticker.C {
-		d, err := clientset.AppsV1().Deployments(r.Namespace).Get(ctx, r.Name, metav1.GetOptions{})
-		if err != nil {
-			return err
+// printResets lists the resources whose replicas were raised back after the
+// scale down, see --watch-resets, and reports whether there were any.
+func printResets(report scaler.Report) bool {
+	reset := false
+	for _, res := range report.Results {
+		if res.ResetBy == "" {
+			continue
+		}
+		if !reset {
+			fmt.Fprintln(textOut, "\nThe replicas of the following resources were raised back after the scale down, pause the controllers that did it:")
+			reset = true
 		}
+		fmt.Fprintf(textOut, "- %s %s: by %s\n", res.Target.Label(), res.Target.Ref(), res.ResetBy)
+	}
+	return reset
+}
 
-		if d.Status.Replicas == targetReplicas {
-			fmt.Printf("[%s/%s] Scale complete.\n", r.Namespace, r.Name)
-			break
+// printInterrupted summarizes an interrupted run: the resources that were
+// already changed, and the ones that were not touched.
+func printInterrupted(mode scaler.Mode, report scaler.Report) {
+	fmt.Fprintln(textOut, "\n---------------------------------------------------")
+	fmt.Fprintf(textOut, "The %s was interrupted before all resources reached their target.\n", mode)
+	if changed := report.Changed(); len(changed) > 0 {
+		fmt.Fprintln(textOut, "\nThe following resources were already changed:")
+		for _, res := range changed {
+			previous, target := strconv.Itoa(int(res.PreviousReplicas)), strconv.Itoa(int(res.TargetReplicas))
+			if res.Target.Kind == scaler.KindCronJob {
+				previous, target = cronJobState(res.PreviousReplicas), cronJobState(res.TargetReplicas)
+			}
+			fmt.Fprintf(textOut, "- %s %s: %s -> %s", res.Target.Label(), res.Target.Ref(), previous, target)
+			if res.Err != nil {
+				fmt.Fprintf(textOut, " (not reached: %v)", res.Err)
+			}
+			fmt.Fprintln(textOut)
+		}
+	} else {
+		fmt.Fprintln(textOut, "\nNo resource was changed.")
+	}
+	var untouched []scaler.Result
+	for _, res := range report.Results {
+		if !res.Changed() && res.Status != scaler.StatusUnchanged {
+			untouched = append(untouched, res)
 		}
-		fmt.Printf("[%s/%s] Waiting for deployment scale down... Current replicas: %d\n", r.Namespace, r.Name, d.Status.Replicas)
 	}
+	if len(untouched) > 0 {
+		fmt.Fprintln(textOut, "\nThe following resources were not changed:")
+		for _, res := range untouched {
+			fmt.Fprintf(textOut, "- %s %s\n", res.Target.Label(), res.Target.Ref())
+		}
+	}
+	printRollback(report)
+	fmt.Fprintln(textOut, "---------------------------------------------------")
+}
 
-	return nil
+func printTargets(targets []scaler.Target, mode scaler.Mode) {
+	wave := func(t scaler.Target) string {
+		if !scaler.HasWaves(targets) {
+			return ""
+		}
+		return fmt.Sprintf(" (wave %d)", t.Wave)
+	}
+	for _, group := range []struct {
+		kind  scaler.Kind
+		title string
+	}{
+		{scaler.KindDeployment, "Deployments"},
+		{scaler.KindStatefulSet, "StatefulSets"},
+		{scaler.KindCronJob, "CronJobs"},
+		{scaler.KindJob, "Jobs"},
+		{scaler.KindReplicationController, "ReplicationControllers"},
+		{scaler.KindDeploymentConfig, "DeploymentConfigs"},
+		{scaler.KindRollout, "Rollouts"},
+		{scaler.KindKnativeService, "KnativeServices"},
+		{scaler.KindCustom, "Custom resources"},
+	} {
+		printed := false
+		for _, t := range targets {
+			if t.Kind != group.kind {
+				continue
+			}
+			if !printed {
+				fmt.Fprintf(textOut, "\n%s to %s:\n", group.title, mode)
+				printed = true
+			}
+			if t.Kind == scaler.KindCustom {
+				fmt.Fprintf(textOut, "- %s %s%s\n", t.Item.Kind, t.Ref(), wave(t))
+			} else {
+				fmt.Fprintf(textOut, "- %s%s\n", t.Ref(), wave(t))
+			}
+		}
+	}
 }
 
-func waitForStatefulSetScaleDown(ctx context.Context, clientset *kubernetes.Clientset, r ResourceItem, targetReplicas int32) error {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+func cronJobState(active int32) string {
+	if active == 0 {
+		return "suspended"
+	}
+	return "active"
+}
 
-	for range ticker.C {
-		s, err := clientset.AppsV1().StatefulSets(r.Namespace).Get(ctx, r.Name, metav1.GetOptions{})
-		if err != nil {
-			return err
+// rejectedChanges returns an error counting the changes refused by the API
+// server in a server-side dry run, if any.
+func rejectedChanges(plan []scaler.PlanEntry) error {
+	rejected := 0
+	for _, p := range plan {
+		if p.Rejected != nil {
+			rejected++
 		}
+	}
+	if rejected == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d changes rejected by the API server in the server-side dry run", rejected, len(plan))
+}
 
-		if s.Status.Replicas == targetReplicas {
-			fmt.Printf("[%s/%s] Scale complete.\n", r.Namespace, r.Name)
-			break
+func printPlan(title string, mode scaler.Mode, plan []scaler.PlanEntry) {
+	fmt.Fprintf(textOut, "\n%s\n\n", title)
+	var targets []scaler.Target
+	for _, p := range plan {
+		targets = append(targets, p.Target)
+	}
+	withWaves := scaler.HasWaves(targets)
+	withPDBs := false
+	for _, p := range plan {
+		withPDBs = withPDBs || len(p.PDBs) > 0
+	}
+	reversed := scaler.ReversesWaves(mode, scaler.RestoreOrder(restoreOrder))
+	sort.SliceStable(plan, func(i, j int) bool {
+		if reversed {
+			return plan[i].Target.Wave > plan[j].Target.Wave
 		}
+		return plan[i].Target.Wave < plan[j].Target.Wave
+	})
 
-		fmt.Printf("[%s/%s] Waiting for statefulset scale down... Current replicas: %d\n", r.Namespace, r.Name, s.Status.Replicas)
+	w := tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	if withWaves {
+		fmt.Fprint(w, "WAVE\t")
+	}
+	fmt.Fprint(w, "KIND\tRESOURCE\tCURRENT\tTARGET\tACTION")
+	if withPDBs {
+		fmt.Fprint(w, "\tPDB")
+	}
+	fmt.Fprintln(w)
+	for _, p := range plan {
+		current, target := strconv.Itoa(int(p.CurrentReplicas)), strconv.Itoa(int(p.TargetReplicas))
+		if p.Target.Kind == scaler.KindCronJob {
+			current, target = cronJobState(p.CurrentReplicas), cronJobState(p.TargetReplicas)
+		}
+		if p.Err != nil || p.Missing {
+			current, target = "-", "-"
+		}
+		if withWaves {
+			fmt.Fprintf(w, "%d\t", p.Target.Wave)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s", p.Target.Label(), p.Target.Ref(), current, target, p.Action())
+		if withPDBs {
+			fmt.Fprintf(w, "\t%s", pdbSummary(p.PDBs))
+		}
+		fmt.Fprintln(w)
 	}
+	_ = w.Flush()
+}
 
-	return nil
+// pdbSummary lists the PodDisruptionBudgets of a plan entry, flagging the
+// violated ones.
+func pdbSummary(pdbs []scaler.PDB) string {
+	if len(pdbs) == 0 {
+		return "-"
+	}
+	var names []string
+	for _, pdb := range pdbs {
+		name := pdb.String()
+		if pdb.Violation != "" {
+			name += " VIOLATED"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	markLabels      map[string]string
+	markAnnotations map[string]string
+	ticket          string
+	reason          string
+)
+
+// maintenanceMarks returns the labels and annotations of --mark-label,
+// --mark-annotation, --ticket and --reason, set on every resource during the
+// maintenance.
+func maintenanceMarks() (scaler.Marks, error) {
+	for key, value := range markLabels {
+		if errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(errs) > 0 {
+			return scaler.Marks{}, fmt.Errorf("invalid --mark-label %s=%s: %s", key, value, strings.Join(errs, ", "))
+		}
+	}
+	for key := range markAnnotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return scaler.Marks{}, fmt.Errorf("invalid --mark-annotation %s: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	marks := scaler.Marks{Labels: markLabels, Annotations: maps.Clone(markAnnotations)}
+	for key, value := range map[string]string{scaler.TicketAnnotation: ticket, scaler.ReasonAnnotation: reason} {
+		if value == "" {
+			continue
+		}
+		if marks.Annotations == nil {
+			marks.Annotations = map[string]string{}
+		}
+		marks.Annotations[key] = value
+	}
+	return marks, nil
+}
+
+// marksAt adds the start and the ID of the scale down to marks, unless there
+// are no marks.
+func marksAt(marks scaler.Marks, start time.Time, runID string) scaler.Marks {
+	if len(marks.Labels) == 0 && len(marks.Annotations) == 0 {
+		return marks
+	}
+	annotations := maps.Clone(marks.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[scaler.MaintenanceSinceAnnotation] = start.UTC().Format(time.RFC3339)
+	annotations[scaler.RunAnnotation] = runID
+	marks.Annotations = annotations
+	return marks
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var nodeNames []string
+
+// addNodeWorkloads adds the Deployments and StatefulSets with pods on --nodes
+// to the config of the current cluster. They are discovered once, before the
+// first run, since their pods are gone once they are scaled down. Resources
+// the config already names keep their configuration.
+func addNodeWorkloads(ctx context.Context, clusters []*cluster) error {
+	if len(nodeNames) == 0 {
+		return nil
+	}
+	for _, c := range clusters {
+		if !c.current {
+			continue
+		}
+		found, err := scaler.New(c.clientset, scaler.Options{}).NodeWorkloads(ctx, nodeNames)
+		if err != nil {
+			return c.wrap(fmt.Errorf("error finding the workloads of nodes: %v", err))
+		}
+		c.config.Deployments = addMissing(c.config.Deployments, found.Deployments)
+		c.config.StatefulSets = addMissing(c.config.StatefulSets, found.StatefulSets)
+		logger.Info("Found workloads with pods on the nodes", "nodes", nodeNames, "deployments", len(found.Deployments), "statefulsets", len(found.StatefulSets))
+	}
+	return nil
+}
+
+// addMissing appends the items not named in items already.
+func addMissing(items, found []scaler.ResourceItem) []scaler.ResourceItem {
+	listed := map[string]bool{}
+	for _, item := range items {
+		listed[item.Namespace+"/"+item.Name] = true
+	}
+	for _, item := range found {
+		if !listed[item.Namespace+"/"+item.Name] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
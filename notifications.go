@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"parallel-scale-down/pkg/notify"
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	webhookURLs      []string
+	slackWebhookURLs []string
+)
+
+// newNotifier returns a notifier for the configured webhooks, or nil if none
+// is configured.
+func newNotifier() *notify.Notifier {
+	var endpoints []notify.Endpoint
+	for _, u := range webhookURLs {
+		endpoints = append(endpoints, notify.Endpoint{URL: u})
+	}
+	for _, u := range slackWebhookURLs {
+		endpoints = append(endpoints, notify.Endpoint{URL: u, Slack: true})
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return notify.New(endpoints, func(err error) {
+		logger.Warn("Notification failed", "error", err)
+	})
+}
+
+// notifyEvent forwards per-resource completions and failures.
+func notifyEvent(n *notify.Notifier, mode scaler.Mode, ev scaler.Event) {
+	if ev.Type != scaler.EventCompleted && ev.Type != scaler.EventFailed {
+		return
+	}
+	n.Notify(notify.Message{
+		Event:     string(ev.Type),
+		Mode:      string(mode),
+		Run:       currentRunID,
+		Cluster:   ev.Target.Cluster,
+		Kind:      ev.Target.Label(),
+		Namespace: ev.Target.Item.Namespace,
+		Name:      ev.Target.Item.Name,
+		Text:      ev.Message,
+	})
+}
+
+func notifyStart(n *notify.Notifier, mode scaler.Mode, targets []scaler.Target) {
+	n.Notify(notify.Message{
+		Event: "started",
+		Mode:  string(mode),
+		Run:   currentRunID,
+		Text:  fmt.Sprintf("Starting parallel %s of %d resources, run %s.", mode, len(targets), currentRunID),
+	})
+}
+
+func notifySummary(n *notify.Notifier, mode scaler.Mode, report scaler.Report, runErr error) {
+	text := fmt.Sprintf("Parallel %s run %s finished: all %d resources reached their target.", mode, currentRunID, len(report.Results))
+	if runErr != nil {
+		text = fmt.Sprintf("Parallel %s run %s failed: %v", mode, currentRunID, runErr)
+		for _, res := range report.Failed() {
+			text += fmt.Sprintf("\n- %s %s: %v", res.Target.Label(), res.Target.Ref(), res.Err)
+		}
+	}
+	n.Notify(notify.Message{
+		Event: "summary",
+		Mode:  string(mode),
+		Run:   currentRunID,
+		Text:  text,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// objectStorageTimeout bounds reading a config from a bucket, credentials
+// included.
+const objectStorageTimeout = 30 * time.Second
+
+// gcsReadScope is the OAuth scope needed to read Google Cloud Storage
+// objects.
+const gcsReadScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// readObject reads an s3:// or gs:// URL with the default credentials of its
+// cloud. Without credentials, the object is read from the public HTTPS
+// endpoint of the bucket, which only works for public objects.
+func readObject(u *url.URL) ([]byte, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid %s URL %q: missing bucket", u.Scheme, u.String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), objectStorageTimeout)
+	defer cancel()
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme == "s3" {
+		return readS3(ctx, u.Host, key)
+	}
+	return readGCS(ctx, u.Host, key)
+}
+
+// readS3 reads an object with the default credential chain of the AWS SDK:
+// the AWS_* environment variables, the shared config and credentials files,
+// SSO, web identity (e.g. IRSA) and the ECS or EC2 instance roles. The
+// object is read from the region of its bucket.
+func readS3(ctx context.Context, bucket, key string) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading the AWS config: %w", err)
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		logger.Debug("No AWS credentials, reading the public object", "bucket", bucket, "error", err)
+		return fetch(fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key))
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	client := s3.NewFromConfig(cfg)
+	if region, err := manager.GetBucketRegion(ctx, client, bucket); err == nil && region != cfg.Region {
+		client = s3.NewFromConfig(cfg, func(o *s3.Options) { o.Region = region })
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err)
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// readGCS reads an object with the Application Default Credentials of
+// Google Cloud: GOOGLE_APPLICATION_CREDENTIALS, the gcloud credentials and
+// the metadata server, e.g. with GKE Workload Identity.
+func readGCS(ctx context.Context, bucket, object string) ([]byte, error) {
+	creds, err := google.FindDefaultCredentials(ctx, gcsReadScope)
+	if err != nil {
+		logger.Debug("No Google Cloud credentials, reading the public object", "bucket", bucket, "error", err)
+		return fetch(fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, object))
+	}
+	location := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media", url.PathEscape(bucket), url.PathEscape(object))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := oauth2.NewClient(ctx, creds.TokenSource).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reading gs://%s/%s: %w", bucket, object, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading gs://%s/%s: %s", bucket, object, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/operator"
+)
+
+var (
+	operatorWorkers int
+	operatorCmd     = &cobra.Command{
+		Use:          "operator",
+		Short:        "Reconcile ScaleDownPlan custom resources until interrupted",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOperator(cmd)
+		},
+	}
+)
+
+func init() {
+	operatorCmd.Flags().IntVar(&operatorWorkers, "workers", 4, "Number of ScaleDownPlans processed at the same time")
+	rootCmd.AddCommand(operatorCmd)
+}
+
+// runOperator watches the ScaleDownPlans of the namespace given with
+// --namespace, or of all namespaces without it.
+func runOperator(cmd *cobra.Command) error {
+	if err := setupLogging(os.Stdout); err != nil {
+		return err
+	}
+
+	backoff, err := retryBackoffFlags()
+	if err != nil {
+		return err
+	}
+
+	kubeConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+
+	namespace, explicit, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("error resolving namespace: %v", err)
+	}
+	if !explicit {
+		namespace = ""
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	mapper, err := configFlags.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("error creating rest mapper: %v", err)
+	}
+
+	return operator.New(clientset, operator.Options{
+		Dynamic:   dynamicClient,
+		Mapper:    mapper,
+		Namespace: namespace,
+		Workers:   operatorWorkers,
+		Retry:     backoff,
+		Logger:    logger,
+	}).Run(cmd.Context())
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// textOut receives the human readable output. It is stdout in text mode and
+// stderr otherwise, so that stdout only contains JSON.
+var textOut io.Writer = os.Stdout
+
+var jsonMu sync.Mutex
+
+func setOutputFormat(format string) error {
+	switch format {
+	case outputText:
+		textOut = os.Stdout
+	case outputJSON, outputNDJSON:
+		textOut = os.Stderr
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: text, json, ndjson", format)
+	}
+	return nil
+}
+
+type jsonEvent struct {
+	Type      string `json:"type"`
+	Cluster   string `json:"cluster,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Wave      int    `json:"wave,omitempty"`
+	Message   string `json:"message"`
+}
+
+type jsonResult struct {
+	Type             string  `json:"type,omitempty"`
+	Cluster          string  `json:"cluster,omitempty"`
+	Kind             string  `json:"kind"`
+	Namespace        string  `json:"namespace"`
+	Name             string  `json:"name"`
+	Wave             int     `json:"wave"`
+	PreviousReplicas int32   `json:"previousReplicas"`
+	TargetReplicas   int32   `json:"targetReplicas"`
+	DurationSeconds  float64 `json:"durationSeconds"`
+	// ScaleDurationSeconds is the time from the scale command to the target
+	// replicas, without hooks.
+	ScaleDurationSeconds float64 `json:"scaleDurationSeconds,omitempty"`
+	Status               string  `json:"status"`
+	Error                string  `json:"error,omitempty"`
+	// ResetBy is the field manager that raised the replicas back after the
+	// scale down, see --watch-resets.
+	ResetBy string `json:"resetBy,omitempty"`
+	// DeniedBy is the admission webhook or ValidatingAdmissionPolicy that
+	// denied the change, see scaler.AdmissionError.
+	DeniedBy string `json:"deniedBy,omitempty"`
+}
+
+type jsonPlanEntry struct {
+	Cluster         string    `json:"cluster,omitempty"`
+	Kind            string    `json:"kind"`
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	Wave            int       `json:"wave"`
+	CurrentReplicas int32     `json:"currentReplicas"`
+	TargetReplicas  int32     `json:"targetReplicas"`
+	Action          string    `json:"action"`
+	PDBs            []jsonPDB `json:"pdbs,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	// Rejected is the error of the API server with --server-dry-run.
+	Rejected string `json:"rejected,omitempty"`
+}
+
+type jsonPDB struct {
+	Name           string `json:"name"`
+	MinAvailable   string `json:"minAvailable,omitempty"`
+	MaxUnavailable string `json:"maxUnavailable,omitempty"`
+	Violation      string `json:"violation,omitempty"`
+}
+
+type jsonReport struct {
+	RunID   string `json:"runId,omitempty"`
+	Mode    string `json:"mode"`
+	DryRun  bool   `json:"dryRun"`
+	Success bool   `json:"success"`
+	// Interrupted is set when the run was stopped by SIGINT or SIGTERM.
+	Interrupted bool   `json:"interrupted,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// DurationSeconds is the wall-clock time of the run.
+	DurationSeconds float64         `json:"durationSeconds,omitempty"`
+	Plan            []jsonPlanEntry `json:"plan,omitempty"`
+	Results         []jsonResult    `json:"results"`
+	// Rollback lists the restored resources after --on-error=rollback.
+	Rollback []jsonResult `json:"rollback,omitempty"`
+}
+
+func writeJSON(v interface{}) {
+	jsonMu.Lock()
+	defer jsonMu.Unlock()
+	enc := json.NewEncoder(os.Stdout)
+	if outputFormat == outputJSON {
+		enc.SetIndent("", "  ")
+	}
+	_ = enc.Encode(v)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func toJSONEvent(ev scaler.Event) jsonEvent {
+	return jsonEvent{
+		Type:      string(ev.Type),
+		Cluster:   ev.Target.Cluster,
+		Kind:      ev.Target.Label(),
+		Namespace: ev.Target.Item.Namespace,
+		Name:      ev.Target.Item.Name,
+		Wave:      ev.Target.Wave,
+		Message:   ev.Message,
+	}
+}
+
+func toJSONResult(res scaler.Result) jsonResult {
+	var deniedBy string
+	var denial *scaler.AdmissionError
+	if errors.As(res.Err, &denial) {
+		deniedBy = denial.Name
+	}
+	return jsonResult{
+		Cluster:              res.Target.Cluster,
+		Kind:                 res.Target.Label(),
+		Namespace:            res.Target.Item.Namespace,
+		Name:                 res.Target.Item.Name,
+		Wave:                 res.Target.Wave,
+		PreviousReplicas:     res.PreviousReplicas,
+		TargetReplicas:       res.TargetReplicas,
+		DurationSeconds:      res.Duration.Seconds(),
+		ScaleDurationSeconds: res.ScaleDuration.Seconds(),
+		Status:               string(res.Status),
+		Error:                errString(res.Err),
+		ResetBy:              res.ResetBy,
+		DeniedBy:             deniedBy,
+	}
+}
+
+func toJSONPlan(plan []scaler.PlanEntry) []jsonPlanEntry {
+	entries := []jsonPlanEntry{}
+	for _, p := range plan {
+		var pdbs []jsonPDB
+		for _, pdb := range p.PDBs {
+			pdbs = append(pdbs, jsonPDB{Name: pdb.Name, MinAvailable: pdb.MinAvailable, MaxUnavailable: pdb.MaxUnavailable, Violation: pdb.Violation})
+		}
+		entries = append(entries, jsonPlanEntry{
+			Cluster:         p.Target.Cluster,
+			Kind:            p.Target.Label(),
+			Namespace:       p.Target.Item.Namespace,
+			Name:            p.Target.Item.Name,
+			Wave:            p.Target.Wave,
+			CurrentReplicas: p.CurrentReplicas,
+			TargetReplicas:  p.TargetReplicas,
+			Action:          p.Action(),
+			PDBs:            pdbs,
+			Error:           errString(p.Err),
+			Rejected:        errString(p.Rejected),
+		})
+	}
+	return entries
+}
+
+// writeReport writes the results of a run in the selected JSON format. In
+// ndjson mode every result is a line of type "result", followed by the
+// rollback results as lines of type "rollback".
+func writeReport(mode scaler.Mode, report scaler.Report, runErr error, elapsed time.Duration) {
+	results := []jsonResult{}
+	for _, res := range report.Results {
+		results = append(results, toJSONResult(res))
+	}
+
+	var rollback []jsonResult
+	for _, res := range report.Rollback {
+		rollback = append(rollback, toJSONResult(res))
+	}
+
+	if outputFormat == outputNDJSON {
+		for _, r := range results {
+			r.Type = "result"
+			writeJSON(r)
+		}
+		for _, r := range rollback {
+			r.Type = "rollback"
+			writeJSON(r)
+		}
+		return
+	}
+
+	writeJSON(jsonReport{
+		RunID:           currentRunID,
+		Mode:            string(mode),
+		Success:         runErr == nil,
+		Interrupted:     report.Interrupted,
+		Error:           errString(runErr),
+		DurationSeconds: elapsed.Seconds(),
+		Results:         results,
+		Rollback:        rollback,
+	})
+}
+
+func writePlan(mode scaler.Mode, plan []scaler.PlanEntry) {
+	entries := toJSONPlan(plan)
+	if outputFormat == outputNDJSON {
+		for _, p := range entries {
+			writeJSON(struct {
+				Type string `json:"type"`
+				jsonPlanEntry
+			}{"plan", p})
+		}
+		return
+	}
+	writeJSON(jsonReport{
+		Mode:    string(mode),
+		DryRun:  true,
+		Success: rejectedChanges(plan) == nil,
+		Plan:    entries,
+		Results: []jsonResult{},
+	})
+}
@@ -0,0 +1,180 @@
+// Package metrics exposes the progress of a run as Prometheus metrics, or
+// pushes its outcome to a Pushgateway.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const (
+	statePending   = "pending"
+	stateRunning   = "running"
+	stateCompleted = "completed"
+	stateFailed    = "failed"
+	stateSkipped   = "skipped"
+)
+
+var states = []string{statePending, stateRunning, stateCompleted, stateFailed, stateSkipped}
+
+// Recorder tracks the state of every target of a run.
+type Recorder struct {
+	registry *prometheus.Registry
+
+	resources *prometheus.GaugeVec
+	completed *prometheus.CounterVec
+	failed    *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+
+	// The outcome of the last run and of its resources, see Finish.
+	resourceResult *prometheus.GaugeVec
+	runDuration    *prometheus.GaugeVec
+	runSuccess     *prometheus.GaugeVec
+	runTimestamp   *prometheus.GaugeVec
+
+	mu     sync.Mutex
+	byKey  map[string]string
+	counts map[string]int
+}
+
+// NewRecorder returns a Recorder with its own registry.
+func NewRecorder() *Recorder {
+	r := &Recorder{
+		registry: prometheus.NewRegistry(),
+		resources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "parallel_scale_down_resources",
+			Help: "Number of resources of the run by state (pending, running, completed, failed, skipped).",
+		}, []string{"state"}),
+		completed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parallel_scale_down_resources_completed_total",
+			Help: "Number of resources that reached their target replicas.",
+		}, []string{"mode", "kind"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parallel_scale_down_resources_failed_total",
+			Help: "Number of resources that failed to reach their target replicas.",
+		}, []string{"mode", "kind"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "parallel_scale_down_resource_duration_seconds",
+			Help:    "Time taken by a resource to reach its target replicas.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"mode", "kind"}),
+		resourceResult: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "parallel_scale_down_resource_last_duration_seconds",
+			Help: "Time taken by each resource in the last run, by status (scaled, unchanged, failed, skipped).",
+		}, []string{"mode", "cluster", "kind", "namespace", "name", "status"}),
+		runDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "parallel_scale_down_last_run_duration_seconds",
+			Help: "Wall-clock time of the last run.",
+		}, []string{"mode"}),
+		runSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "parallel_scale_down_last_run_success",
+			Help: "Whether every resource of the last run reached its target (1) or not (0).",
+		}, []string{"mode"}),
+		runTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "parallel_scale_down_last_run_timestamp_seconds",
+			Help: "Unix time the last run finished at.",
+		}, []string{"mode"}),
+		byKey:  map[string]string{},
+		counts: map[string]int{},
+	}
+	r.registry.MustRegister(r.resources, r.completed, r.failed, r.duration, r.resourceResult, r.runDuration, r.runSuccess, r.runTimestamp)
+	for _, state := range states {
+		r.resources.WithLabelValues(state).Set(0)
+	}
+	return r
+}
+
+func key(t scaler.Target) string {
+	return t.Label() + "/" + t.Ref()
+}
+
+func (r *Recorder) setState(t scaler.Target, state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := key(t)
+	if previous, ok := r.byKey[k]; ok {
+		r.counts[previous]--
+		r.resources.WithLabelValues(previous).Set(float64(r.counts[previous]))
+	}
+	r.byKey[k] = state
+	r.counts[state]++
+	r.resources.WithLabelValues(state).Set(float64(r.counts[state]))
+}
+
+// Start marks every target as pending, and forgets the resource results of
+// the previous run.
+func (r *Recorder) Start(targets []scaler.Target) {
+	r.resourceResult.Reset()
+	for _, t := range targets {
+		r.setState(t, statePending)
+	}
+}
+
+// Event records a target as running once it starts.
+func (r *Recorder) Event(ev scaler.Event) {
+	if ev.Type == scaler.EventStarted {
+		r.setState(ev.Target, stateRunning)
+	}
+}
+
+// Result records the outcome of a target of a run in the given mode.
+func (r *Recorder) Result(mode scaler.Mode, res scaler.Result) {
+	kind := res.Target.Label()
+	r.resourceResult.WithLabelValues(string(mode), res.Target.Cluster, kind, res.Target.Item.Namespace, res.Target.Item.Name, string(res.Status)).Set(res.Duration.Seconds())
+	switch {
+	case res.Status == scaler.StatusSkipped:
+		r.setState(res.Target, stateSkipped)
+		return
+	case res.Err != nil:
+		r.setState(res.Target, stateFailed)
+		r.failed.WithLabelValues(string(mode), kind).Inc()
+	default:
+		r.setState(res.Target, stateCompleted)
+		r.completed.WithLabelValues(string(mode), kind).Inc()
+	}
+	r.duration.WithLabelValues(string(mode), kind).Observe(res.Duration.Seconds())
+}
+
+// Serve exposes the metrics on addr under /metrics until the process exits.
+func (r *Recorder) Serve(addr string, onError func(error)) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, http.ErrServerClosed) && onError != nil {
+			onError(err)
+		}
+	}()
+	return nil
+}
+
+// Finish records the outcome of a run in the given mode.
+func (r *Recorder) Finish(mode scaler.Mode, runErr error, elapsed time.Duration) {
+	success := 0.0
+	if runErr == nil {
+		success = 1
+	}
+	r.runDuration.WithLabelValues(string(mode)).Set(elapsed.Seconds())
+	r.runSuccess.WithLabelValues(string(mode)).Set(success)
+	r.runTimestamp.WithLabelValues(string(mode)).SetToCurrentTime()
+}
+
+// Push replaces the metrics of job on the Pushgateway at url with the
+// metrics of the recorder, so that the outcome of a short-lived run outlives
+// it.
+func (r *Recorder) Push(ctx context.Context, url, job string) error {
+	return push.New(url, job).Gatherer(r.registry).PushContext(ctx)
+}
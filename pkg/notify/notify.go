@@ -0,0 +1,117 @@
+// Package notify posts run progress to Slack incoming webhooks or generic
+// HTTP endpoints.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Message is a single notification. Generic webhooks receive it as JSON.
+type Message struct {
+	Event     string `json:"event"`
+	Mode      string `json:"mode"`
+	Run       string `json:"run,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Text      string `json:"text"`
+}
+
+// Endpoint is a webhook that receives notifications.
+type Endpoint struct {
+	URL string
+	// Slack sends Slack incoming webhook payloads instead of Message.
+	Slack bool
+}
+
+// Notifier delivers messages to its endpoints in the background, so slow
+// endpoints do not hold up the run.
+type Notifier struct {
+	endpoints []Endpoint
+	client    *http.Client
+	queue     chan Message
+	done      chan struct{}
+	onError   func(error)
+}
+
+// New starts a Notifier. onError, if set, is called for every failed
+// delivery.
+func New(endpoints []Endpoint, onError func(error)) *Notifier {
+	n := &Notifier{
+		endpoints: endpoints,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		queue:     make(chan Message, 100),
+		done:      make(chan struct{}),
+		onError:   onError,
+	}
+	go n.loop()
+	return n
+}
+
+// Notify queues a message. It is safe to call from multiple goroutines.
+func (n *Notifier) Notify(m Message) {
+	n.queue <- m
+}
+
+// Close waits for the queued messages to be delivered.
+func (n *Notifier) Close() {
+	close(n.queue)
+	<-n.done
+}
+
+func (n *Notifier) loop() {
+	defer close(n.done)
+	for m := range n.queue {
+		for _, e := range n.endpoints {
+			if err := n.send(e, m); err != nil && n.onError != nil {
+				n.onError(err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) send(e Endpoint, m Message) error {
+	var payload interface{} = m
+	if e.Slack {
+		payload = map[string]string{"text": slackText(m)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(e.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// Webhook URLs usually embed a secret, so keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send %s notification: %v", m.Event, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send %s notification: %s", m.Event, resp.Status)
+	}
+	return nil
+}
+
+func slackText(m Message) string {
+	if m.Name == "" {
+		if m.Cluster != "" {
+			return fmt.Sprintf("`%s` %s", m.Cluster, m.Text)
+		}
+		return m.Text
+	}
+	if m.Cluster != "" {
+		return fmt.Sprintf("`%s %s:%s/%s` %s", m.Kind, m.Cluster, m.Namespace, m.Name, m.Text)
+	}
+	return fmt.Sprintf("`%s %s/%s` %s", m.Kind, m.Namespace, m.Name, m.Text)
+}
@@ -0,0 +1,374 @@
+// Package operator reconciles ScaleDownPlan custom resources, so that
+// maintenances can be declared in the cluster, e.g. through GitOps, instead
+// of being run from the CLI.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Options configures a Controller.
+type Options struct {
+	// Dynamic is required to watch ScaleDownPlans. Mapper is required for
+	// plans scaling custom resources.
+	Dynamic dynamic.Interface
+	Mapper  meta.RESTMapper
+
+	// Namespace limits the controller to the plans of one namespace. Empty
+	// watches every namespace.
+	Namespace string
+
+	// Workers is the number of plans processed at the same time. It
+	// defaults to 1.
+	Workers int
+
+	// Retry is the backoff of failed API requests, see scaler.Options.
+	Retry wait.Backoff
+
+	// Logger receives the progress of every plan. Nil discards it.
+	Logger *slog.Logger
+}
+
+// Controller scales the resources of ScaleDownPlans down when their window
+// starts, and restores them when it ends.
+type Controller struct {
+	client kubernetes.Interface
+	opts   Options
+	log    *slog.Logger
+	queue  workqueue.TypedRateLimitingInterface[string]
+}
+
+// New returns a Controller using the given clientset.
+func New(client kubernetes.Interface, opts Options) *Controller {
+	log := opts.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	return &Controller{
+		client: client,
+		opts:   opts,
+		log:    log,
+		queue:  workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+	}
+}
+
+// Run watches ScaleDownPlans and reconciles them until ctx is cancelled. A
+// plan interrupted by the cancellation is resumed by the next Run.
+func (c *Controller) Run(ctx context.Context) error {
+	if c.opts.Dynamic == nil {
+		return fmt.Errorf("the operator requires a dynamic client")
+	}
+	defer c.queue.ShutDown()
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.opts.Dynamic, 0, c.opts.Namespace, nil)
+	informer := factory.ForResource(Resource).Informer()
+	enqueue := func(obj interface{}) {
+		if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj interface{}) { enqueue(obj) },
+	}); err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for the %s cache to sync: %v", Resource.Resource, ctx.Err())
+	}
+	c.log.Info("Watching ScaleDownPlans", "namespace", c.opts.Namespace, "workers", c.opts.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.processNext(ctx) {
+			}
+		}()
+	}
+	<-ctx.Done()
+	c.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+func (c *Controller) processNext(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	requeueAfter, err := c.reconcile(ctx, key)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.log.Error("Failed to reconcile ScaleDownPlan", "plan", key, "error", err)
+			c.queue.AddRateLimited(key)
+		}
+		return true
+	}
+	c.queue.Forget(key)
+	if requeueAfter > 0 {
+		c.queue.AddAfter(key, requeueAfter)
+	}
+	return true
+}
+
+// reconcile moves a plan through its phases and returns when it should be
+// looked at again.
+func (c *Controller) reconcile(ctx context.Context, key string) (time.Duration, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return 0, err
+	}
+	// The plan is read from the API server rather than the informer cache,
+	// so that a status written by the previous reconcile is never missed.
+	obj, err := c.opts.Dynamic.Resource(Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	plan := &ScaleDownPlan{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), plan); err != nil {
+		return 0, fmt.Errorf("invalid ScaleDownPlan: %w", err)
+	}
+
+	now := time.Now()
+	window := plan.Spec.Window
+	switch plan.Status.Phase {
+	case "", PhasePending:
+		if window.Start != nil && now.Before(window.Start.Time) {
+			if plan.Status.Phase == "" {
+				plan.Status.Phase = PhasePending
+				plan.Status.Message = fmt.Sprintf("Waiting for the window to start at %s", window.Start.Format(time.RFC3339))
+				if err := c.updateStatus(ctx, plan); err != nil {
+					return 0, err
+				}
+			}
+			return window.Start.Sub(now), nil
+		}
+		if window.End != nil && !now.Before(window.End.Time) {
+			plan.Status.Phase = PhaseFailed
+			plan.Status.Message = "The window ended before the plan could start"
+			return 0, c.updateStatus(ctx, plan)
+		}
+		return c.run(ctx, plan, scaler.ModeScaleDown)
+	case PhaseScalingDown:
+		return c.run(ctx, plan, scaler.ModeScaleDown)
+	case PhaseScaledDown:
+		if window.End == nil {
+			return 0, nil
+		}
+		if now.Before(window.End.Time) {
+			return window.End.Sub(now), nil
+		}
+		return c.run(ctx, plan, scaler.ModeRestore)
+	case PhaseRestoring:
+		return c.run(ctx, plan, scaler.ModeRestore)
+	}
+	return 0, nil
+}
+
+// run scales the resources of a plan down or restores them, recording the
+// result of every resource in the status as soon as it is done.
+func (c *Controller) run(ctx context.Context, plan *ScaleDownPlan, mode scaler.Mode) (time.Duration, error) {
+	key := plan.Namespace + "/" + plan.Name
+	running, done := PhaseScalingDown, PhaseScaledDown
+	if mode == scaler.ModeRestore {
+		running, done = PhaseRestoring, PhaseCompleted
+	}
+	plan.Status.Phase = running
+	plan.Status.Message = fmt.Sprintf("Starting %s", mode)
+	plan.Status.ObservedGeneration = plan.Generation
+	if err := c.updateStatus(ctx, plan); err != nil {
+		return 0, err
+	}
+
+	state := &scaler.State{}
+	if counts := plan.Status.OriginalReplicas; counts != nil && mode == scaler.ModeRestore {
+		state.Deployments, state.StatefulSets, state.Custom = counts.Deployments, counts.StatefulSets, counts.Custom
+		state.ReplicationControllers, state.DeploymentConfigs = counts.ReplicationControllers, counts.DeploymentConfigs
+	}
+
+	// Replicas in the spec are the scale down targets. Restore brings every
+	// resource back to its original count instead.
+	config := plan.Spec.Config.WithDefaults()
+	if mode == scaler.ModeRestore {
+		config = config.WithoutReplicas()
+	}
+	config.ApplyDefaultNamespace(plan.Namespace, true)
+
+	var mu sync.Mutex
+	var timeout time.Duration
+	if plan.Spec.Timeout != nil {
+		timeout = plan.Spec.Timeout.Duration
+	}
+	var budget *scaler.Budget
+	if mode == scaler.ModeScaleDown && config.MaxUnavailable != "" {
+		budget = &scaler.Budget{}
+	}
+	s := scaler.New(c.client, scaler.Options{
+		Unavailable:     budget,
+		Dynamic:         c.opts.Dynamic,
+		Mapper:          c.opts.Mapper,
+		State:           state,
+		MaxConcurrency:  plan.Spec.MaxConcurrency,
+		MaxPerNamespace: plan.Spec.MaxPerNamespace,
+		PauseHPA:        plan.Spec.PauseHPA,
+		Timeout:         timeout,
+		Retry:           c.opts.Retry,
+		OnError:         scaler.ErrorPolicy(plan.Spec.OnError),
+		WaitFor:         scaler.WaitFor(plan.Spec.WaitFor),
+		RecordEvents:    true,
+		Actor:           "ScaleDownPlan " + key,
+		PreHook:         plan.Spec.PreHook,
+		PostHook:        plan.Spec.PostHook,
+		Nodes:           plan.Spec.Nodes,
+		OnEvent: func(ev scaler.Event) {
+			c.log.Info(ev.Message, "plan", key, "event", string(ev.Type), "kind", ev.Target.Label(), "namespace", ev.Target.Item.Namespace, "name", ev.Target.Item.Name)
+		},
+		OnResult: func(res scaler.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			setResult(plan, res)
+			if err := c.updateStatus(ctx, plan); err != nil {
+				c.log.Warn("Failed to update ScaleDownPlan status", "plan", key, "error", err)
+			}
+		},
+	})
+
+	targets, err := s.Resolve(ctx, config)
+	if err != nil {
+		return c.fail(ctx, plan, err)
+	}
+	if budget != nil {
+		if _, _, err := budget.SetMaxUnavailable(config.MaxUnavailable, targets); err != nil {
+			return c.fail(ctx, plan, err)
+		}
+	}
+	plan.Status.Resources = nil
+	for _, t := range targets {
+		plan.Status.Resources = append(plan.Status.Resources, ResourceStatus{
+			Kind:      t.Label(),
+			Namespace: t.Item.Namespace,
+			Name:      t.Item.Name,
+			Wave:      t.Wave,
+			Status:    "pending",
+		})
+	}
+	if err := c.updateStatus(ctx, plan); err != nil {
+		return 0, err
+	}
+
+	report, runErr := s.Run(ctx, mode, targets)
+	if ctx.Err() != nil {
+		// Leave the plan in its running phase, so it is resumed on restart.
+		return 0, ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, res := range report.Results {
+		setResult(plan, res)
+	}
+	if mode == scaler.ModeScaleDown {
+		plan.Status.OriginalReplicas = &ReplicaCounts{
+			Deployments:            state.Deployments,
+			StatefulSets:           state.StatefulSets,
+			ReplicationControllers: state.ReplicationControllers,
+			DeploymentConfigs:      state.DeploymentConfigs,
+			Custom:                 state.Custom,
+		}
+	}
+	if runErr != nil {
+		return c.fail(ctx, plan, runErr)
+	}
+
+	now := metav1.Now()
+	plan.Status.Phase = done
+	if mode == scaler.ModeScaleDown {
+		plan.Status.ScaledDownAt = &now
+		plan.Status.Message = fmt.Sprintf("Scaled down %d resources", len(targets))
+	} else {
+		plan.Status.RestoredAt = &now
+		plan.Status.Message = fmt.Sprintf("Restored %d resources", len(targets))
+	}
+	if err := c.updateStatus(ctx, plan); err != nil {
+		return 0, err
+	}
+	c.log.Info(plan.Status.Message, "plan", key)
+	if end := plan.Spec.Window.End; done == PhaseScaledDown && end != nil {
+		return time.Until(end.Time), nil
+	}
+	return 0, nil
+}
+
+func (c *Controller) fail(ctx context.Context, plan *ScaleDownPlan, err error) (time.Duration, error) {
+	plan.Status.Phase = PhaseFailed
+	plan.Status.Message = err.Error()
+	c.log.Error("ScaleDownPlan failed", "plan", plan.Namespace+"/"+plan.Name, "error", err)
+	return 0, c.updateStatus(ctx, plan)
+}
+
+// updateStatus writes the status of the plan, on top of the latest version
+// of the object.
+func (c *Controller) updateStatus(ctx context.Context, plan *ScaleDownPlan) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&plan.Status)
+	if err != nil {
+		return err
+	}
+	plans := c.opts.Dynamic.Resource(Resource).Namespace(plan.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := plans.Get(ctx, plan.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		latest.Object["status"] = status
+		_, err = plans.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func setResult(plan *ScaleDownPlan, res scaler.Result) {
+	for i := range plan.Status.Resources {
+		r := &plan.Status.Resources[i]
+		if r.Kind != res.Target.Label() || r.Namespace != res.Target.Item.Namespace || r.Name != res.Target.Item.Name {
+			continue
+		}
+		r.PreviousReplicas = res.PreviousReplicas
+		r.TargetReplicas = res.TargetReplicas
+		if res.Status != "" {
+			r.Status = string(res.Status)
+		}
+		r.Error = ""
+		if res.Err != nil {
+			r.Error = res.Err.Error()
+		}
+		return
+	}
+}
@@ -0,0 +1,103 @@
+package operator
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Resource is the ScaleDownPlan custom resource reconciled by the Controller.
+var Resource = schema.GroupVersionResource{Group: "parallel-scale-down.io", Version: "v1alpha1", Resource: "scaledownplans"}
+
+// Phase is the lifecycle stage of a ScaleDownPlan.
+type Phase string
+
+const (
+	// PhasePending waits for the start of the window.
+	PhasePending Phase = "Pending"
+	// PhaseScalingDown scales the resources down.
+	PhaseScalingDown Phase = "ScalingDown"
+	// PhaseScaledDown keeps the resources scaled down until the end of the
+	// window.
+	PhaseScaledDown Phase = "ScaledDown"
+	// PhaseRestoring scales the resources back to their original replicas.
+	PhaseRestoring Phase = "Restoring"
+	// PhaseCompleted is reached once the resources are restored.
+	PhaseCompleted Phase = "Completed"
+	// PhaseFailed is reached when scaling down or restoring failed. The plan
+	// is not retried.
+	PhaseFailed Phase = "Failed"
+)
+
+// ScaleDownPlan declares a maintenance: the resources to scale down and the
+// window during which they stay scaled down.
+type ScaleDownPlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScaleDownPlanSpec   `json:"spec"`
+	Status ScaleDownPlanStatus `json:"status,omitempty"`
+}
+
+// ScaleDownPlanSpec lists the resources in the config file format, plus the
+// options of a run.
+type ScaleDownPlanSpec struct {
+	scaler.Config `json:",inline"`
+
+	// Window is when the resources are scaled down. Without a start, they
+	// are scaled down right away, and without an end they are never
+	// restored.
+	Window Window `json:"window,omitempty"`
+
+	MaxConcurrency  int              `json:"maxConcurrency,omitempty"`
+	MaxPerNamespace int              `json:"maxPerNamespace,omitempty"`
+	PauseHPA        bool             `json:"pauseHPA,omitempty"`
+	Timeout         *metav1.Duration `json:"timeout,omitempty"`
+	OnError         string           `json:"onError,omitempty"`
+	WaitFor         string           `json:"waitFor,omitempty"`
+}
+
+// Window is a maintenance window.
+type Window struct {
+	Start *metav1.Time `json:"start,omitempty"`
+	End   *metav1.Time `json:"end,omitempty"`
+}
+
+// ScaleDownPlanStatus reports the progress of a plan.
+type ScaleDownPlanStatus struct {
+	Phase              Phase        `json:"phase,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	ScaledDownAt       *metav1.Time `json:"scaledDownAt,omitempty"`
+	RestoredAt         *metav1.Time `json:"restoredAt,omitempty"`
+	// Resources holds one entry per resolved resource, updated as each of
+	// them completes.
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// OriginalReplicas records the replica counts before the scale down, and
+	// is used to restore them.
+	OriginalReplicas *ReplicaCounts `json:"originalReplicas,omitempty"`
+}
+
+// ReplicaCounts mirrors scaler.State, keyed the same way.
+type ReplicaCounts struct {
+	Deployments            map[string]int32 `json:"deployments,omitempty"`
+	StatefulSets           map[string]int32 `json:"statefulsets,omitempty"`
+	ReplicationControllers map[string]int32 `json:"replicationcontrollers,omitempty"`
+	DeploymentConfigs      map[string]int32 `json:"deploymentconfigs,omitempty"`
+	Custom                 map[string]int32 `json:"custom,omitempty"`
+}
+
+// ResourceStatus is the progress of a single resource.
+type ResourceStatus struct {
+	Kind             string `json:"kind"`
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	Wave             int    `json:"wave,omitempty"`
+	PreviousReplicas int32  `json:"previousReplicas"`
+	TargetReplicas   int32  `json:"targetReplicas"`
+	// Status is "pending" until the resource is done, then the status of
+	// its result.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
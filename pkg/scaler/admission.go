@@ -0,0 +1,83 @@
+package scaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// The messages of the API server for a change denied by a validating
+// admission webhook, e.g. of OPA Gatekeeper or Kyverno, and by a
+// ValidatingAdmissionPolicy.
+var (
+	webhookDenial = regexp.MustCompile(`admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+	policyDenial  = regexp.MustCompile(`ValidatingAdmissionPolicy '([^']+)' with binding '[^']*' denied request:?\s*(.*)`)
+)
+
+// AdmissionError is the error of a target whose change was denied by an
+// admission webhook or a ValidatingAdmissionPolicy, as opposed to a conflict
+// or a missing resource: retrying is pointless until the policy allows it.
+type AdmissionError struct {
+	// Source is "admission webhook" or "ValidatingAdmissionPolicy", and
+	// Name the name of the webhook or policy.
+	Source string
+	Name   string
+	// Message is the reason given by the policy, e.g. the constraint and
+	// message of a Gatekeeper violation.
+	Message string
+	Err     error
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("denied by %s %q: %s", e.Source, e.Name, e.Message)
+}
+
+func (e *AdmissionError) Unwrap() error { return e.Err }
+
+// admissionDenial returns err as an AdmissionError if it wraps an API error
+// of a change denied at admission, nil otherwise.
+func admissionDenial(err error) *AdmissionError {
+	var denial *AdmissionError
+	if errors.As(err, &denial) {
+		return denial
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return nil
+	}
+	message := status.Status().Message
+	if m := webhookDenial.FindStringSubmatch(message); m != nil {
+		return &AdmissionError{Source: "admission webhook", Name: m[1], Message: m[2], Err: err}
+	}
+	if m := policyDenial.FindStringSubmatch(message); m != nil {
+		return &AdmissionError{Source: "ValidatingAdmissionPolicy", Name: m[1], Message: m[2], Err: err}
+	}
+	return nil
+}
+
+// scaleTargetAdmitted scales a target, retrying it up to
+// Options.AdmissionRetries times, Options.AdmissionRetryDelay apart, while
+// its change is denied at admission, e.g. by a policy forbidding changes
+// outside of a maintenance window that is about to open.
+func (e *execution) scaleTargetAdmitted(ctx context.Context, t Target, res *Result) error {
+	for retry := 1; ; retry++ {
+		err := e.scaleTarget(ctx, t, res)
+		denial := admissionDenial(err)
+		if denial == nil {
+			return err
+		}
+		if retry > e.opts.AdmissionRetries {
+			return denial
+		}
+		e.emit(EventWarning, t, "Denied by %s %q: %s. Retrying in %s (retry %d of %d)...", denial.Source, denial.Name, denial.Message, e.opts.AdmissionRetryDelay, retry, e.opts.AdmissionRetries)
+		select {
+		case <-time.After(e.opts.AdmissionRetryDelay):
+		case <-ctx.Done():
+			return denial
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Budget limits how many targets are scaled down at the same time across
+// every Scaler sharing it, see Options.Unavailable. The zero value has no
+// limit.
+type Budget struct {
+	slots chan struct{}
+}
+
+// SetMaxUnavailable limits the budget to a maxUnavailable value resolved
+// against the targets of the run, with ParseMaxUnavailable. CronJobs and
+// Jobs are not counted. It returns the limit and the number of targets counted, and must
+// be called before the Scalers sharing the budget run.
+func (b *Budget) SetMaxUnavailable(value string, targets []Target) (limit, total int, err error) {
+	for _, t := range targets {
+		if t.Kind != KindCronJob && t.Kind != KindJob {
+			total++
+		}
+	}
+	if limit, err = ParseMaxUnavailable(value, total); err != nil {
+		return 0, 0, err
+	}
+	b.slots = make(chan struct{}, limit)
+	return limit, total, nil
+}
+
+// acquire waits for a free slot, or returns the error of ctx if it is
+// cancelled first.
+func (b *Budget) acquire(ctx context.Context) error {
+	if b.slots == nil {
+		return nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Budget) release() {
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// ParseMaxUnavailable resolves a maxUnavailable value against the number of
+// targets of the run: a count such as "3", or a percentage such as "30%"
+// rounded down. The result is at least 1, so that the run can progress.
+func ParseMaxUnavailable(value string, total int) (int, error) {
+	value = strings.TrimSpace(value)
+	invalid := fmt.Errorf("invalid maxUnavailable %q, must be a positive count or a percentage such as \"30%%\"", value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.Atoi(percent)
+		if err != nil || p <= 0 || p > 100 {
+			return 0, invalid
+		}
+		return max(total*p/100, 1), nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, invalid
+	}
+	return n, nil
+}
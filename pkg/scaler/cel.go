@@ -0,0 +1,46 @@
+package scaler
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// celCondition is a WaitCondition CEL expression, evaluated with the
+// resource as the variable object.
+type celCondition struct {
+	program cel.Program
+}
+
+func parseCELCondition(expr string) (*celCondition, error) {
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("%q is neither a JSONPath expression in braces nor a valid CEL expression: %w", expr, issues.Err())
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("CEL expression %q must return a bool, not %s", expr, t)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &celCondition{program: program}, nil
+}
+
+// check returns the result of the expression as the description. An
+// evaluation error, such as a field that is not set yet, does not hold.
+func (c *celCondition) check(obj map[string]interface{}) (bool, string, error) {
+	out, _, err := c.program.Eval(map[string]interface{}{"object": obj})
+	if err != nil {
+		return false, err.Error(), nil
+	}
+	holds, ok := out.Value().(bool)
+	if !ok {
+		return false, "", fmt.Errorf("the expression returned %v instead of a bool", out.Value())
+	}
+	return holds, fmt.Sprint(holds), nil
+}
@@ -0,0 +1,63 @@
+package scaler
+
+import (
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Checkpoint records the targets that completed during a run, so that a
+// re-run after a partial failure only retries the remaining ones.
+type Checkpoint struct {
+	mu sync.Mutex
+
+	Mode      Mode     `yaml:"mode"`
+	Completed []string `yaml:"completed"`
+}
+
+// LoadCheckpoint reads a checkpoint file. A missing file yields an empty
+// checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Save writes the checkpoint to path.
+func (c *Checkpoint) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (c *Checkpoint) completed(t Target) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := targetKey(t)
+	for _, k := range c.Completed {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Checkpoint) markCompleted(t Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Completed = append(c.Completed, targetKey(t))
+}
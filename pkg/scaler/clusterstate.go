@@ -0,0 +1,168 @@
+package scaler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// StateConfigMapPrefix is the prefix of the names of the ConfigMaps of
+	// a StateStore, followed by the ID of their run.
+	StateConfigMapPrefix = "parallel-scale-down-state-"
+	// StateRunLabel is set on the ConfigMaps of a StateStore to the ID of
+	// their run.
+	StateRunLabel = "parallel-scale-down/state-run"
+	// LatestRun names the most recent scale down in StateStore.Load.
+	LatestRun = "latest"
+)
+
+// RunStatus is the progress of a run kept in a StateStore.
+type RunStatus string
+
+const (
+	RunStatusRunning     RunStatus = "running"
+	RunStatusScaledDown  RunStatus = "scaled-down"
+	RunStatusFailed      RunStatus = "failed"
+	RunStatusInterrupted RunStatus = "interrupted"
+	RunStatusRestored    RunStatus = "restored"
+)
+
+// StateRecord is a scale down kept in the cluster by a StateStore, with the
+// original replica counts and the config needed to restore it.
+type StateRecord struct {
+	RunID      string
+	Status     RunStatus
+	Message    string
+	Holder     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Config     Config
+	State      *State
+}
+
+// StateStore keeps StateRecords in ConfigMaps, so that a restore can run
+// from another machine than the scale down. The state and config are kept
+// in the same format as the state and config files.
+type StateStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// NewStateStore returns a StateStore keeping its ConfigMaps in namespace.
+func NewStateStore(client kubernetes.Interface, namespace string) *StateStore {
+	return &StateStore{client: client, namespace: namespace}
+}
+
+// Name returns the namespace and name of the ConfigMap of a run.
+func (s *StateStore) Name(runID string) string {
+	return s.namespace + "/" + StateConfigMapPrefix + runID
+}
+
+// Save creates or updates the ConfigMap of a record.
+func (s *StateStore) Save(ctx context.Context, rec *StateRecord) error {
+	state := rec.State
+	if state == nil {
+		state = &State{}
+	}
+	state.mu.Lock()
+	stateData, err := yaml.Marshal(state)
+	state.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	configData, err := yaml.Marshal(rec.Config)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{
+		"status":      string(rec.Status),
+		"message":     rec.Message,
+		"holder":      rec.Holder,
+		"startedAt":   rec.StartedAt.UTC().Format(time.RFC3339),
+		"state.yaml":  string(stateData),
+		"config.yaml": string(configData),
+	}
+	if !rec.FinishedAt.IsZero() {
+		data["finishedAt"] = rec.FinishedAt.UTC().Format(time.RFC3339)
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	return withRetry(ctx, DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, StateConfigMapPrefix+rec.RunID, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   StateConfigMapPrefix + rec.RunID,
+					Labels: map[string]string{StateRunLabel: rec.RunID},
+				},
+				Data: data,
+			}, metav1.CreateOptions{FieldManager: FieldManager})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		cm.Data = data
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{FieldManager: FieldManager})
+		return err
+	})
+}
+
+// Load reads the record of a run, or of the most recent one for LatestRun.
+func (s *StateStore) Load(ctx context.Context, runID string) (*StateRecord, error) {
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	var cm *corev1.ConfigMap
+	if runID == LatestRun {
+		list, err := configMaps.List(ctx, metav1.ListOptions{LabelSelector: StateRunLabel})
+		if err != nil {
+			return nil, err
+		}
+		if len(list.Items) == 0 {
+			return nil, fmt.Errorf("no run state found in namespace %s", s.namespace)
+		}
+		sort.Slice(list.Items, func(i, j int) bool {
+			return list.Items[i].Data["startedAt"] > list.Items[j].Data["startedAt"]
+		})
+		cm = &list.Items[0]
+	} else {
+		var err error
+		if cm, err = configMaps.Get(ctx, StateConfigMapPrefix+runID, metav1.GetOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	rec := &StateRecord{
+		RunID:   cm.Labels[StateRunLabel],
+		Status:  RunStatus(cm.Data["status"]),
+		Message: cm.Data["message"],
+		Holder:  cm.Data["holder"],
+		State:   &State{},
+	}
+	rec.StartedAt, _ = time.Parse(time.RFC3339, cm.Data["startedAt"])
+	rec.FinishedAt, _ = time.Parse(time.RFC3339, cm.Data["finishedAt"])
+	if err := yaml.Unmarshal([]byte(cm.Data["state.yaml"]), rec.State); err != nil {
+		return nil, fmt.Errorf("invalid state in configmap %s: %w", cm.Name, err)
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data["config.yaml"]), &rec.Config); err != nil {
+		return nil, fmt.Errorf("invalid config in configmap %s: %w", cm.Name, err)
+	}
+	return rec, nil
+}
+
+// NewRunID returns a sortable run ID that is also a valid suffix of object
+// names, e.g. "20240601-020000-1a2b3c".
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,216 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// WaitCondition is the condition of WaitForCustom for each mode: a JSONPath
+// expression over the resource, as with kubectl wait --for=jsonpath, e.g.
+// "{.status.phase}=Stopped", or a CEL expression over the resource named
+// object, e.g. `object.status.phase == "Stopped"`. Without a value, such as
+// "{.status.stoppedAt}", the JSONPath field only has to be set. A mode
+// without a condition only waits for the replicas.
+type WaitCondition struct {
+	ScaleDown string `json:"scaleDown,omitempty" yaml:"scaleDown,omitempty"`
+	Restore   string `json:"restore,omitempty" yaml:"restore,omitempty"`
+}
+
+func (c *WaitCondition) validate() []string {
+	var problems []string
+	for _, condition := range []struct {
+		key  string
+		expr string
+	}{{"scaleDown", c.ScaleDown}, {"restore", c.Restore}} {
+		if condition.expr == "" {
+			continue
+		}
+		if _, err := parseCondition(condition.expr); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s condition: %v", condition.key, err))
+		}
+	}
+	return problems
+}
+
+// forMode returns the condition of a mode, if any.
+func (c *WaitCondition) forMode(mode Mode) string {
+	switch {
+	case c == nil:
+		return ""
+	case mode == ModeRestore:
+		return c.Restore
+	}
+	return c.ScaleDown
+}
+
+// condition is a parsed WaitCondition expression.
+type condition interface {
+	// check evaluates the condition on an object, returning whether it
+	// holds and a description of its current value.
+	check(obj map[string]interface{}) (bool, string, error)
+}
+
+// jsonPathCondition is a WaitCondition JSONPath expression.
+type jsonPathCondition struct {
+	expr     string
+	path     *jsonpath.JSONPath
+	value    string
+	hasValue bool
+}
+
+// parseCondition parses a JSONPath expression, which starts with a brace,
+// or a CEL expression.
+func parseCondition(expr string) (condition, error) {
+	if !strings.HasPrefix(expr, "{") {
+		return parseCELCondition(expr)
+	}
+	end := strings.LastIndex(expr, "}")
+	if end < 0 {
+		return nil, fmt.Errorf("%q must be a JSONPath expression in braces, optionally followed by =value, e.g. {.status.phase}=Stopped", expr)
+	}
+	c := &jsonPathCondition{expr: expr, path: jsonpath.New("condition").AllowMissingKeys(true)}
+	if rest := expr[end+1:]; rest != "" {
+		value, ok := strings.CutPrefix(rest, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q must be followed by =value, found %q", expr[:end+1], rest)
+		}
+		c.value, c.hasValue = value, true
+	}
+	if err := c.path.Parse(expr[:end+1]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// check returns the current values of the path as the description.
+func (c *jsonPathCondition) check(obj map[string]interface{}) (bool, string, error) {
+	results, err := c.path.FindResults(obj)
+	if err != nil {
+		return false, "", err
+	}
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			values = append(values, fmt.Sprint(v.Interface()))
+		}
+	}
+	if len(values) == 0 {
+		return false, "<none>", nil
+	}
+	for _, v := range values {
+		if c.hasValue && v != c.value || !c.hasValue && v == "" {
+			return false, strings.Join(values, ","), nil
+		}
+	}
+	return true, strings.Join(values, ","), nil
+}
+
+// awaitCompletion waits for the part of the WaitFor of a target that follows
+// its replicas: the endpoints drained, the pods deleted, the volumes detached
+// or the custom condition.
+func (e *execution) awaitCompletion(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	switch {
+	case e.drainsEndpoints(t, targetReplicas):
+		return e.waitForEndpoints(ctx, t, w)
+	case e.waitFor(t) == WaitForPodsDeleted && e.mode == ModeScaleDown:
+		return e.waitForPodsDeleted(ctx, t, w, targetReplicas)
+	case e.waitFor(t) == WaitForVolumesDetached && e.mode == ModeScaleDown:
+		if err := e.waitForPodsDeleted(ctx, t, w, targetReplicas); err != nil || t.Kind != KindStatefulSet {
+			return err
+		}
+		return e.waitForVolumesDetached(ctx, t, w, targetReplicas)
+	case e.waitFor(t) == WaitForCustom:
+		if expr := t.Item.Condition.forMode(e.mode); expr != "" {
+			return e.waitForCondition(ctx, t, w, expr)
+		}
+	}
+	return nil
+}
+
+// waitForPodsDeleted polls the pods of a workload until at most
+// targetReplicas are left, counting the ones still terminating.
+func (e *execution) waitForPodsDeleted(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	if w.selector == "" {
+		e.emit(EventWarning, t, "Pod selector unknown, not waiting for its pods to be deleted.")
+		return nil
+	}
+	poll := e.poller()
+	last := -1
+	for {
+		pods, err := e.client.CoreV1().Pods(t.Item.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.selector})
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		left := len(pods.Items)
+		if left <= int(targetReplicas) {
+			e.emit(EventProgress, t, "Removed pods deleted.")
+			return nil
+		}
+		progressed := left != last
+		if progressed {
+			e.emit(EventProgress, t, "Waiting for pods to be deleted... Pods left: %d", left)
+			last = left
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForCondition polls a workload until the condition holds on it.
+func (e *execution) waitForCondition(ctx context.Context, t Target, w *workload, expr string) error {
+	condition, err := parseCondition(expr)
+	if err != nil {
+		return err
+	}
+	poll := e.poller()
+	last := ""
+	for {
+		obj, err := w.object(ctx)
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		content, err := unstructuredContent(obj)
+		if err != nil {
+			return err
+		}
+		ok, current, err := condition.check(content)
+		if err != nil {
+			return fmt.Errorf("evaluating condition %s: %w", expr, err)
+		}
+		if ok {
+			e.emit(EventProgress, t, "Condition %s met.", expr)
+			return nil
+		}
+		progressed := current != last
+		if progressed {
+			e.emit(EventProgress, t, "Waiting for %s... Current: %s", expr, current)
+			last = current
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
+
+// unstructuredContent returns the fields of a typed or unstructured object.
+func unstructuredContent(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
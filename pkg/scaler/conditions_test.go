@@ -0,0 +1,70 @@
+package scaler
+
+import "testing"
+
+func TestConditionCheck(t *testing.T) {
+	stopped := map[string]interface{}{
+		"status": map[string]interface{}{"phase": "Stopped", "activeTasks": int64(0)},
+	}
+	running := map[string]interface{}{
+		"status": map[string]interface{}{"phase": "Running", "activeTasks": int64(3)},
+	}
+	unset := map[string]interface{}{}
+
+	tests := []struct {
+		name string
+		expr string
+		obj  map[string]interface{}
+		want bool
+	}{
+		{name: "jsonpath value", expr: "{.status.phase}=Stopped", obj: stopped, want: true},
+		{name: "jsonpath other value", expr: "{.status.phase}=Stopped", obj: running, want: false},
+		{name: "jsonpath set", expr: "{.status.phase}", obj: running, want: true},
+		{name: "jsonpath unset", expr: "{.status.phase}", obj: unset, want: false},
+		{name: "cel", expr: `object.status.phase == "Stopped"`, obj: stopped, want: true},
+		{name: "cel false", expr: `object.status.phase == "Stopped"`, obj: running, want: false},
+		{name: "cel combined", expr: `object.status.phase == "Stopped" && object.status.activeTasks == 0`, obj: stopped, want: true},
+		{name: "cel unset field", expr: `object.status.phase == "Stopped"`, obj: unset, want: false},
+		{name: "cel has", expr: `!has(object.status)`, obj: unset, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCondition(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCondition(%q): %v", tt.expr, err)
+			}
+			got, _, err := c.check(tt.obj)
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConditionInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"{.status.phase",
+		"{.status.phase}Stopped",
+		`object.status.phase ==`,
+		`"Stopped"`,
+		`size(object.spec)`,
+		`status.phase == "Stopped"`,
+	} {
+		if _, err := parseCondition(expr); err == nil {
+			t.Errorf("parseCondition(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCELConditionNotBool(t *testing.T) {
+	c, err := parseCondition("object.status.phase")
+	if err != nil {
+		t.Fatalf("parseCondition: %v", err)
+	}
+	if _, _, err := c.check(map[string]interface{}{"status": map[string]interface{}{"phase": "Stopped"}}); err == nil {
+		t.Error("check() of a string expression succeeded, want an error")
+	}
+}
@@ -0,0 +1,588 @@
+package scaler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config lists the resources to scale, grouped by kind.
+type Config struct {
+	Deployments  []ResourceItem `json:"deployments,omitempty" yaml:"deployments,omitempty"`
+	StatefulSets []ResourceItem `json:"statefulsets,omitempty" yaml:"statefulsets,omitempty"`
+	CronJobs     []ResourceItem `json:"cronjobs,omitempty" yaml:"cronjobs,omitempty"`
+	// ReplicationControllers and the OpenShift DeploymentConfigs are scaled
+	// like Deployments.
+	ReplicationControllers []ResourceItem `json:"replicationcontrollers,omitempty" yaml:"replicationcontrollers,omitempty"`
+	DeploymentConfigs      []ResourceItem `json:"deploymentconfigs,omitempty" yaml:"deploymentconfigs,omitempty"`
+	// Jobs are stopped on scale down with the JobPolicy of their item.
+	Jobs []ResourceItem `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	// Rollouts are Argo Rollouts, and KnativeServices are Knative Services
+	// scaled through their autoscaling annotations.
+	Rollouts        []ResourceItem `json:"rollouts,omitempty" yaml:"rollouts,omitempty"`
+	KnativeServices []ResourceItem `json:"knativeservices,omitempty" yaml:"knativeservices,omitempty"`
+	Custom          []ResourceItem `json:"custom,omitempty" yaml:"custom,omitempty"`
+	// Resources lists items of any kind in a single list, each naming its
+	// kind, e.g. "Deployment", or the group, version and kind of a custom
+	// resource. They are moved to the list of their kind once parsed, see
+	// Grouped.
+	Resources []ResourceItem `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Namespaces scales every Deployment and StatefulSet of a namespace.
+	Namespaces []NamespaceItem `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	// Nodes are cordoned once every resource is scaled down, and uncordoned
+	// before the resources are restored. They are passed to Options.Nodes.
+	Nodes []NodeItem `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	// PreHook runs before anything is scaled, and PostHook once every
+	// resource has reached its target. They are passed to Options.PreHook
+	// and Options.PostHook.
+	PreHook  *Hook `json:"preHook,omitempty" yaml:"preHook,omitempty"`
+	PostHook *Hook `json:"postHook,omitempty" yaml:"postHook,omitempty"`
+	// MaxUnavailable limits how many resources are scaled down at the same
+	// time, as a count or a percentage of the resources of the run, see
+	// ParseMaxUnavailable and Options.Unavailable.
+	MaxUnavailable string `json:"maxUnavailable,omitempty" yaml:"maxUnavailable,omitempty"`
+	// Defaults are applied to the items and namespaces that omit their
+	// fields, see WithDefaults.
+	Defaults *Defaults `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+}
+
+// NamespaceItem selects every Deployment and StatefulSet of a namespace,
+// except the ones named in Exclude.
+type NamespaceItem struct {
+	Name     string    `json:"name" yaml:"name"`
+	Exclude  []string  `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Replicas *Replicas `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Wave     int       `json:"wave,omitempty" yaml:"wave,omitempty"`
+	// PauseAfter waits for an approval once the wave is done, see
+	// ResourceItem.PauseAfter.
+	PauseAfter bool `json:"pauseAfter,omitempty" yaml:"pauseAfter,omitempty"`
+	// Context is the kubeconfig context of the namespace, see
+	// ResourceItem.Context.
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+	// Timeout and WaitFor apply to every resource of the namespace, see
+	// ResourceItem.Timeout and ResourceItem.WaitFor.
+	Timeout string  `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	WaitFor WaitFor `json:"waitFor,omitempty" yaml:"waitFor,omitempty"`
+	// Groups tags every resource of the namespace, see ResourceItem.Groups.
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// NodeItem selects one node by name or several by label selector.
+type NodeItem struct {
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// Context is the kubeconfig context of the node, see
+	// ResourceItem.Context.
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// ResourceItem selects one resource by name or several by label selector.
+type ResourceItem struct {
+	Name      string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Replicas  *Replicas         `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Selector  string            `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Group     string            `json:"group,omitempty" yaml:"group,omitempty"`
+	Version   string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Kind      string            `json:"kind,omitempty" yaml:"kind,omitempty"`
+	// ReplicasPath is the field holding the replica count of a custom
+	// resource without a scale subresource, e.g. ".spec.size". The field is
+	// applied instead of the scale subresource.
+	ReplicasPath string `json:"replicasPath,omitempty" yaml:"replicasPath,omitempty"`
+	// StatusReplicasPath is the field reporting the current replica count
+	// of a resource with a ReplicasPath, waited on after the patch. Without
+	// it, the resource is complete once patched.
+	StatusReplicasPath string `json:"statusReplicasPath,omitempty" yaml:"statusReplicasPath,omitempty"`
+	// FieldManager applies the replicas of the resource instead of
+	// FieldManager, so that its managedFields tell which maintenance owns
+	// them, e.g. "parallel-scale-down-payments".
+	FieldManager string `json:"fieldManager,omitempty" yaml:"fieldManager,omitempty"`
+	// Groups tags the item, e.g. with the team or service it belongs to, so
+	// that a run can be limited to the items of some groups, see
+	// FilterGroups. Group is the API group of custom resources instead.
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// Context is the kubeconfig context of the cluster the item lives in.
+	// Empty uses the current context. Items of different contexts are
+	// scaled in parallel, see Config.SplitByContext.
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+	// Optional skips the resource when it does not exist, instead of
+	// failing the run, for configs shared by environments that do not all
+	// run it. See Options.IgnoreMissing.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+	// Wave orders items: every item of a wave is scaled in parallel, and a
+	// wave starts once the previous one has completed.
+	Wave int `json:"wave,omitempty" yaml:"wave,omitempty"`
+	// Priority orders the items of a wave when not all of them can start at
+	// once, e.g. with Options.MaxConcurrency: items with a higher priority
+	// are scaled first. It defaults to 0, and may be negative.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// PauseAfter waits for an approval once every item of the wave of this
+	// item is done, before the next wave starts, see Options.Approve.
+	PauseAfter bool `json:"pauseAfter,omitempty" yaml:"pauseAfter,omitempty"`
+	// DependsOn lists items ("name" or "namespace/name") that must only be
+	// scaled down after this item.
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	// Strategy selects how the resource is scaled down.
+	Strategy Strategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// Timeout is a duration such as "5m" overriding Options.Timeout for
+	// this resource.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// WaitFor overrides Options.WaitFor for this resource.
+	WaitFor WaitFor `json:"waitFor,omitempty" yaml:"waitFor,omitempty"`
+	// Condition is waited for with WaitForCustom.
+	Condition *WaitCondition `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// Steps are the replica counts a scale down goes through before its
+	// target, e.g. [6, 3, 1, 0], waiting for each of them to be reached.
+	// Steps that are not between the current and the target replicas are
+	// skipped.
+	Steps []int32 `json:"steps,omitempty" yaml:"steps,omitempty"`
+	// StepSize scales down by at most this many replicas at a time, as an
+	// alternative to Steps.
+	StepSize int32 `json:"stepSize,omitempty" yaml:"stepSize,omitempty"`
+	// StepInterval is a duration such as "30s" waited for after every step
+	// of Steps or StepSize, once its replicas are reached.
+	StepInterval string `json:"stepInterval,omitempty" yaml:"stepInterval,omitempty"`
+	// JobPolicy selects how an item of jobs is stopped on scale down.
+	JobPolicy JobPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// PreHook runs before the resource is scaled, and PostHook once it has
+	// reached its target replicas. A failing hook fails the resource.
+	PreHook  *Hook `json:"preHook,omitempty" yaml:"preHook,omitempty"`
+	PostHook *Hook `json:"postHook,omitempty" yaml:"postHook,omitempty"`
+	// Quiesce runs a command in every pod removed by a scale down before
+	// the replicas are reduced, e.g. to flush or checkpoint its data.
+	Quiesce *Quiesce `json:"quiesce,omitempty" yaml:"quiesce,omitempty"`
+	// MaintenancePage points the Service of the resource at a maintenance
+	// page while it is scaled down.
+	MaintenancePage *MaintenancePage `json:"maintenancePage,omitempty" yaml:"maintenancePage,omitempty"`
+	// HealthCheck is checked by Verify once the resource is restored.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty"`
+}
+
+// Strategy selects how a resource is scaled down.
+type Strategy string
+
+const (
+	// StrategyParallel sets the target replicas in a single step. It is the
+	// default.
+	StrategyParallel Strategy = "parallel"
+	// StrategySequential removes one replica at a time and waits for its pod
+	// to terminate before the next step.
+	StrategySequential Strategy = "sequential"
+	// StrategyEvict evicts the pods removed by the scale down through the
+	// Eviction API, honoring PodDisruptionBudgets, before setting the target
+	// replicas. See Options.Evict.
+	StrategyEvict Strategy = "evict"
+)
+
+// JobPolicy selects how a Job still running is stopped on scale down.
+type JobPolicy string
+
+const (
+	// JobPolicyWait waits for the Job to complete or fail, within
+	// Options.Timeout. It is the default.
+	JobPolicyWait JobPolicy = "wait"
+	// JobPolicySuspend sets spec.suspend, which terminates the active pods
+	// of the Job. Restore resumes it.
+	JobPolicySuspend JobPolicy = "suspend"
+	// JobPolicyDelete deletes the Job and its pods. Restore cannot bring it
+	// back.
+	JobPolicyDelete JobPolicy = "delete"
+)
+
+// LoadConfig reads a YAML config file, or a JSON one with a .json extension.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfigAs(data, FormatOf(path))
+}
+
+// FormatOf returns the format of a config file from its extension.
+func FormatOf(path string) ConfigFormat {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ConfigFormatJSON
+	}
+	return ConfigFormatYAML
+}
+
+// ConfigFormat is the format of a config file.
+type ConfigFormat string
+
+const (
+	// ConfigFormatYAML accepts YAML, and JSON as a subset of YAML.
+	ConfigFormatYAML ConfigFormat = "yaml"
+	// ConfigFormatJSON only accepts valid JSON.
+	ConfigFormatJSON ConfigFormat = "json"
+)
+
+// ParseConfig parses and validates a YAML config. Unknown fields are
+// rejected. Environment variables and templates in names, namespaces and
+// replicas are expanded. A *ConfigError lists every problem found, with its line.
+func ParseConfig(data []byte) (*Config, error) {
+	return ParseConfigAs(data, ConfigFormatYAML)
+}
+
+// ParseConfigAs parses and validates a config of the given format, see
+// ParseConfig. JSON is decoded as YAML once checked, so that its problems are
+// reported with their lines as well. The "---" separated documents of a YAML
+// config are merged, see MergeConfigs.
+func ParseConfigAs(data []byte, format ConfigFormat) (*Config, error) {
+	switch format {
+	case ConfigFormatYAML:
+	case ConfigFormatJSON:
+		if err := checkJSON(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q, must be one of: yaml, json", format)
+	}
+	if format == ConfigFormatYAML {
+		if docs := splitDocuments(data); len(docs) > 1 {
+			return parseDocuments(docs)
+		}
+	}
+	return parseDocument(data)
+}
+
+// parseDocument parses and validates a config holding a single document.
+func parseDocument(data []byte) (*Config, error) {
+	var cfg Config
+	var problems []string
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		// Type errors do not stop the decoding, so the rest of the config
+		// is still validated.
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+		problems = append(problems, typeErr.Errors...)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	lines := entryLines(&doc)
+	line := func(section string, i int) int {
+		entries := lines[section]
+		i = max(i, 0)
+		if i < len(entries) {
+			return entries[i]
+		}
+		return 0
+	}
+
+	cfg.expandFields(func(section string, i int, err error) {
+		problems = append(problems, fmt.Sprintf("line %d: %s[%d]: %v", line(section, i), section, i, err))
+	})
+	err := cfg.validate(line)
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		problems = append(problems, configErr.Problems...)
+	}
+
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool { return problemLine(problems[i]) < problemLine(problems[j]) })
+		return nil, &ConfigError{Problems: problems}
+	}
+	cfg = cfg.WithDefaults().Grouped()
+	return &cfg, nil
+}
+
+// builtinKinds are the kinds that an item of resources can name instead of
+// a custom resource, by their Kind.Label.
+var builtinKinds = []Kind{KindDeployment, KindStatefulSet, KindCronJob, KindJob, KindReplicationController, KindDeploymentConfig, KindRollout, KindKnativeService}
+
+// resourceKind returns the kind of an item of resources, and the item as it
+// is listed under that kind: built-in kinds are named without group and
+// version, e.g. "Deployment", and other items are custom resources.
+func resourceKind(item ResourceItem) (Kind, ResourceItem) {
+	if item.Group == "" && item.Version == "" {
+		for _, kind := range builtinKinds {
+			if strings.EqualFold(item.Kind, kind.Label()) {
+				item.Kind = ""
+				return kind, item
+			}
+		}
+	}
+	return KindCustom, item
+}
+
+// Grouped returns a copy of the config with the items of Resources moved to
+// the end of the list of their kind.
+func (c Config) Grouped() Config {
+	if len(c.Resources) == 0 {
+		return c
+	}
+	for _, item := range c.Resources {
+		kind, item := resourceKind(item)
+		section := c.sectionRef(kind)
+		*section = append((*section)[:len(*section):len(*section)], item)
+	}
+	c.Resources = nil
+	return c
+}
+
+// checkJSON returns the syntax error of invalid JSON with its line.
+func checkJSON(data []byte) error {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %d: invalid JSON: %v", line, err)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	if _, ok := value.(map[string]interface{}); !ok && value != nil {
+		return fmt.Errorf("invalid JSON: the config must be an object")
+	}
+	return nil
+}
+
+// problemLine returns the line of a problem starting with "line N:".
+func problemLine(problem string) int {
+	rest, ok := strings.CutPrefix(problem, "line ")
+	if !ok {
+		return 0
+	}
+	number, _, _ := strings.Cut(rest, ":")
+	line, _ := strconv.Atoi(number)
+	return line
+}
+
+// SplitByContext groups the items of the config by kubeconfig context, using
+// defaultContext for items without one. The hooks and maxUnavailable of the
+// config are copied to every group.
+func (c Config) SplitByContext(defaultContext string) map[string]Config {
+	c = c.Grouped()
+	groups := map[string]*Config{}
+	group := func(context string) *Config {
+		if context == "" {
+			context = defaultContext
+		}
+		if groups[context] == nil {
+			groups[context] = &Config{PreHook: c.PreHook, PostHook: c.PostHook, MaxUnavailable: c.MaxUnavailable}
+		}
+		return groups[context]
+	}
+	for _, item := range c.Deployments {
+		cfg := group(item.Context)
+		cfg.Deployments = append(cfg.Deployments, item)
+	}
+	for _, item := range c.StatefulSets {
+		cfg := group(item.Context)
+		cfg.StatefulSets = append(cfg.StatefulSets, item)
+	}
+	for _, item := range c.CronJobs {
+		cfg := group(item.Context)
+		cfg.CronJobs = append(cfg.CronJobs, item)
+	}
+	for _, item := range c.Jobs {
+		cfg := group(item.Context)
+		cfg.Jobs = append(cfg.Jobs, item)
+	}
+	for _, item := range c.ReplicationControllers {
+		cfg := group(item.Context)
+		cfg.ReplicationControllers = append(cfg.ReplicationControllers, item)
+	}
+	for _, item := range c.DeploymentConfigs {
+		cfg := group(item.Context)
+		cfg.DeploymentConfigs = append(cfg.DeploymentConfigs, item)
+	}
+	for _, item := range c.Rollouts {
+		cfg := group(item.Context)
+		cfg.Rollouts = append(cfg.Rollouts, item)
+	}
+	for _, item := range c.KnativeServices {
+		cfg := group(item.Context)
+		cfg.KnativeServices = append(cfg.KnativeServices, item)
+	}
+	for _, item := range c.Custom {
+		cfg := group(item.Context)
+		cfg.Custom = append(cfg.Custom, item)
+	}
+	for _, ns := range c.Namespaces {
+		cfg := group(ns.Context)
+		cfg.Namespaces = append(cfg.Namespaces, ns)
+	}
+	for _, node := range c.Nodes {
+		cfg := group(node.Context)
+		cfg.Nodes = append(cfg.Nodes, node)
+	}
+
+	result := map[string]Config{}
+	for context, cfg := range groups {
+		result[context] = *cfg
+	}
+	return result
+}
+
+// MergeConfigs merges configs read from several sources into one, in order.
+// sources names the config of the same index in errors. A resource listed by
+// several sources, or a top-level hook set by several, is reported in a
+// *ConfigError.
+func MergeConfigs(sources []string, configs []*Config) (*Config, error) {
+	merged := &Config{}
+	var problems []string
+	listedBy := map[string]string{}
+	for i, c := range configs {
+		source := sources[i]
+		grouped := c.Grouped()
+		c := &grouped
+		for _, s := range configSections {
+			section := merged.sectionRef(s.kind)
+			for _, item := range c.section(s.kind) {
+				if item.Name != "" {
+					key := string(s.kind) + "/" + itemKey(item)
+					if first, ok := listedBy[key]; ok {
+						problems = append(problems, fmt.Sprintf("%s %s is listed in both %s and %s", s.kind.Label(), itemDescription(item), first, source))
+						continue
+					}
+					listedBy[key] = source
+				}
+				*section = append(*section, item)
+			}
+		}
+		for _, ns := range c.Namespaces {
+			key := "namespace/" + ns.Context + "/" + ns.Name
+			if first, ok := listedBy[key]; ok {
+				problems = append(problems, fmt.Sprintf("namespace %s is listed in both %s and %s", ns.Name, first, source))
+				continue
+			}
+			listedBy[key] = source
+			merged.Namespaces = append(merged.Namespaces, ns)
+		}
+		for _, node := range c.Nodes {
+			if node.Name != "" {
+				key := "node/" + node.Context + "/" + node.Name
+				if first, ok := listedBy[key]; ok {
+					problems = append(problems, fmt.Sprintf("node %s is listed in both %s and %s", node.Name, first, source))
+					continue
+				}
+				listedBy[key] = source
+			}
+			merged.Nodes = append(merged.Nodes, node)
+		}
+		for _, hook := range []struct {
+			key  string
+			from *Hook
+			to   **Hook
+		}{{"preHook", c.PreHook, &merged.PreHook}, {"postHook", c.PostHook, &merged.PostHook}} {
+			if hook.from == nil {
+				continue
+			}
+			if first, ok := listedBy[hook.key]; ok {
+				problems = append(problems, fmt.Sprintf("%s is set in both %s and %s", hook.key, first, source))
+				continue
+			}
+			listedBy[hook.key] = source
+			*hook.to = hook.from
+		}
+		if c.MaxUnavailable != "" {
+			if first, ok := listedBy["maxUnavailable"]; ok {
+				problems = append(problems, fmt.Sprintf("maxUnavailable is set in both %s and %s", first, source))
+			} else {
+				listedBy["maxUnavailable"] = source
+				merged.MaxUnavailable = c.MaxUnavailable
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return merged, nil
+}
+
+// WithoutReplicas returns a copy of the config without target replicas, so
+// that a restore brings every resource back to its original count instead of
+// the scale down target.
+func (c Config) WithoutReplicas() Config {
+	strip := func(items []ResourceItem) []ResourceItem {
+		result := make([]ResourceItem, len(items))
+		for i, item := range items {
+			item.Replicas = nil
+			result[i] = item
+		}
+		return result
+	}
+	c.Deployments = strip(c.Deployments)
+	c.StatefulSets = strip(c.StatefulSets)
+	c.CronJobs = strip(c.CronJobs)
+	c.Jobs = strip(c.Jobs)
+	c.ReplicationControllers = strip(c.ReplicationControllers)
+	c.DeploymentConfigs = strip(c.DeploymentConfigs)
+	c.Rollouts = strip(c.Rollouts)
+	c.KnativeServices = strip(c.KnativeServices)
+	c.Custom = strip(c.Custom)
+	c.Resources = strip(c.Resources)
+	namespaces := make([]NamespaceItem, len(c.Namespaces))
+	for i, ns := range c.Namespaces {
+		ns.Replicas = nil
+		namespaces[i] = ns
+	}
+	c.Namespaces = namespaces
+	if c.Defaults != nil {
+		defaults := *c.Defaults
+		defaults.Replicas = nil
+		c.Defaults = &defaults
+	}
+	return c
+}
+
+// items returns the item lists of every kind.
+func (c Config) items() [][]ResourceItem {
+	return [][]ResourceItem{c.Deployments, c.StatefulSets, c.CronJobs, c.Jobs, c.ReplicationControllers, c.DeploymentConfigs, c.Rollouts, c.KnativeServices, c.Custom, c.Resources}
+}
+
+// MultiCluster reports whether any item of the config names a context.
+func (c Config) MultiCluster() bool {
+	for _, items := range c.items() {
+		for _, item := range items {
+			if item.Context != "" {
+				return true
+			}
+		}
+	}
+	for _, ns := range c.Namespaces {
+		if ns.Context != "" {
+			return true
+		}
+	}
+	for _, node := range c.Nodes {
+		if node.Context != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyDefaultNamespace fills in the namespace for items that omit it. Named
+// items always use the given namespace, while selector items only inherit an
+// explicit namespace and otherwise match across all namespaces.
+func (c *Config) ApplyDefaultNamespace(namespace string, explicit bool) {
+	for _, items := range c.items() {
+		for i := range items {
+			if items[i].Namespace != "" {
+				continue
+			}
+			if items[i].Name != "" || explicit {
+				items[i].Namespace = namespace
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CordonedAnnotation marks the nodes cordoned by a scale down, so that a
+// restore only uncordons those, not the nodes cordoned by someone else.
+const CordonedAnnotation = "parallel-scale-down/cordoned"
+
+// resolveNodes returns the nodes of Options.Nodes, by name. A node named by a
+// scale down must exist, while a restore only warns about it, since the node
+// may have been replaced during the maintenance.
+func (e *execution) resolveNodes(ctx context.Context) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, item := range e.opts.Nodes {
+		if item.Name != "" {
+			err := withRetry(ctx, e.opts.Retry, func() error {
+				_, err := e.client.CoreV1().Nodes().Get(ctx, item.Name, metav1.GetOptions{})
+				return err
+			})
+			switch {
+			case apierrors.IsNotFound(err) && e.mode != ModeScaleDown:
+				e.emit(EventWarning, Target{}, "Node %s not found, not uncordoning it.", item.Name)
+			case err != nil:
+				return nil, fmt.Errorf("failed to get node %s: %w", item.Name, err)
+			default:
+				add(item.Name)
+			}
+			continue
+		}
+		var nodes *corev1.NodeList
+		if err := withRetry(ctx, e.opts.Retry, func() error {
+			var err error
+			nodes, err = e.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: item.Selector})
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to list nodes with selector %q: %w", item.Selector, err)
+		}
+		if len(nodes.Items) == 0 {
+			e.emit(EventWarning, Target{}, "No node matches selector %q.", item.Selector)
+		}
+		for _, node := range nodes.Items {
+			add(node.Name)
+		}
+	}
+	return names, nil
+}
+
+// cordonNodes marks the nodes of the run unschedulable once every target is
+// scaled down, and returns the nodes it cordoned. Nodes that are already
+// cordoned are left alone, and stay cordoned on restore.
+func (e *execution) cordonNodes(ctx context.Context) ([]NodeItem, error) {
+	var cordoned []NodeItem
+	for _, name := range e.nodes {
+		node, err := e.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return cordoned, fmt.Errorf("failed to get node %s: %w", name, err)
+		}
+		if node.Spec.Unschedulable {
+			e.emit(EventCompleted, Target{}, "Node %s is already cordoned.", name)
+			continue
+		}
+		if err := e.patchNode(ctx, name, "true", true); err != nil {
+			return cordoned, fmt.Errorf("failed to cordon node %s: %w", name, err)
+		}
+		cordoned = append(cordoned, NodeItem{Name: name, Context: e.opts.Cluster})
+		e.emit(EventCompleted, Target{}, "Node %s cordoned.", name)
+	}
+	return cordoned, nil
+}
+
+// uncordonNodes marks the nodes of the run schedulable again before the
+// targets are restored, if they were cordoned by a scale down.
+func (e *execution) uncordonNodes(ctx context.Context) error {
+	for _, name := range e.nodes {
+		node, err := e.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get node %s: %w", name, err)
+		}
+		if _, marked := node.Annotations[CordonedAnnotation]; !marked {
+			if node.Spec.Unschedulable {
+				e.emit(EventCompleted, Target{}, "Node %s was not cordoned by parallel-scale-down, leaving it cordoned.", name)
+			}
+			continue
+		}
+		if err := e.patchNode(ctx, name, nil, nil); err != nil {
+			return fmt.Errorf("failed to uncordon node %s: %w", name, err)
+		}
+		e.emit(EventCompleted, Target{}, "Node %s uncordoned.", name)
+	}
+	return nil
+}
+
+// patchNode sets the unschedulable field and the CordonedAnnotation of a
+// node, removing them when nil.
+func (e *execution) patchNode(ctx context.Context, name string, annotation, unschedulable interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{CordonedAnnotation: annotation},
+		},
+		"spec": map[string]interface{}{"unschedulable": unschedulable},
+	})
+	if err != nil {
+		return err
+	}
+	return withRetry(ctx, e.opts.Retry, func() error {
+		_, err := e.client.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	})
+}
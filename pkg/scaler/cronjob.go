@@ -0,0 +1,89 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func isSuspended(c *batchv1.CronJob) bool {
+	return c.Spec.Suspend != nil && *c.Spec.Suspend
+}
+
+// handleCronJob suspends a CronJob on scale down and marks it so restore only
+// resumes CronJobs that were suspended by this tool.
+func (e *execution) handleCronJob(ctx context.Context, t Target, res *Result) error {
+	r := t.Item
+	cronJobsClient := e.client.BatchV1().CronJobs(r.Namespace)
+
+	c, err := cronJobsClient.Get(ctx, r.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	res.Status = StatusUnchanged
+	if !isSuspended(c) {
+		res.PreviousReplicas = 1
+	}
+	res.TargetReplicas = res.PreviousReplicas
+
+	var annotation interface{}
+	var suspend bool
+	if e.mode == ModeScaleDown {
+		if isSuspended(c) {
+			e.emit(EventCompleted, t, "Already suspended.")
+			return nil
+		}
+		if err := e.pauseGitOps(ctx, t, c.Labels, c.Annotations); err != nil {
+			return err
+		}
+		annotation, suspend = "true", true
+	} else {
+		if _, marked := c.Annotations[SuspendedAnnotation]; !marked {
+			if isSuspended(c) {
+				e.emit(EventCompleted, t, "Not suspended by parallel-scale-down, leaving it suspended.")
+			} else {
+				e.emit(EventCompleted, t, "Already active.")
+			}
+			return nil
+		}
+		annotation, suspend = nil, false
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{SuspendedAnnotation: annotation},
+		},
+		"spec": map[string]interface{}{"suspend": suspend},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := withRetry(ctx, e.opts.Retry, func() error {
+		_, err := cronJobsClient.Patch(ctx, r.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	}); err != nil {
+		return err
+	}
+
+	res.Status = StatusScaled
+	res.changed = true
+	if _, err := e.applyMarks(ctx, c.Annotations, func(ctx context.Context, data []byte) error {
+		_, err := cronJobsClient.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	}); err != nil {
+		return err
+	}
+	if suspend {
+		res.TargetReplicas = 0
+		e.emit(EventCompleted, t, "Suspended.")
+	} else {
+		res.TargetReplicas = 1
+		e.emit(EventCompleted, t, "Resumed.")
+		return e.resumeGitOps(ctx, t, c.Labels, c.Annotations)
+	}
+	return nil
+}
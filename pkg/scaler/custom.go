@@ -0,0 +1,217 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	autoscalingv1apply "k8s.io/client-go/applyconfigurations/autoscaling/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// customClient scales arbitrary resources that implement the scale
+// subresource, such as Argo Rollouts or operator-managed custom resources.
+type customClient struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+}
+
+func (c *customClient) mappingFor(r ResourceItem) (*meta.RESTMapping, error) {
+	if r.Kind == "" || r.Version == "" {
+		return nil, fmt.Errorf("custom resources require kind and version")
+	}
+	if c.dynamic == nil || c.mapper == nil {
+		return nil, fmt.Errorf("custom resources require a dynamic client and rest mapper")
+	}
+	return c.mapper.RESTMapping(schema.GroupKind{Group: r.Group, Kind: r.Kind}, r.Version)
+}
+
+func (c *customClient) resourceFor(r ResourceItem) (dynamic.ResourceInterface, error) {
+	mapping, err := c.mappingFor(r)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return c.dynamic.Resource(mapping.Resource), nil
+	}
+	return c.dynamic.Resource(mapping.Resource).Namespace(r.Namespace), nil
+}
+
+// dynamicScaleClient adapts a dynamic resource client to scaleClient by
+// reading and applying its scale subresource.
+type dynamicScaleClient struct {
+	resource dynamic.ResourceInterface
+}
+
+func (c dynamicScaleClient) GetScale(ctx context.Context, name string, options metav1.GetOptions) (*autoscalingv1.Scale, error) {
+	obj, err := c.resource.Get(ctx, name, options, "scale")
+	if err != nil {
+		return nil, err
+	}
+	var scale autoscalingv1.Scale
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &scale); err != nil {
+		return nil, err
+	}
+	return &scale, nil
+}
+
+func (c dynamicScaleClient) ApplyScale(ctx context.Context, name string, scale *autoscalingv1apply.ScaleApplyConfiguration, opts metav1.ApplyOptions) (*autoscalingv1.Scale, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(scale)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	obj.SetName(name)
+	applied, err := c.resource.Apply(ctx, name, obj, opts, "scale")
+	if err != nil {
+		return nil, err
+	}
+	var updated autoscalingv1.Scale
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// fieldPath matches the replica paths of custom resources: a JSONPath of
+// object fields, such as ".spec.size".
+var fieldPath = regexp.MustCompile(`^(\.[A-Za-z0-9_-]+)+$`)
+
+// parseFieldPath splits a replica path into its fields.
+func parseFieldPath(path string) ([]string, error) {
+	if !fieldPath.MatchString(path) {
+		return nil, fmt.Errorf("invalid replica path %q, must be a path of fields such as .spec.replicas", path)
+	}
+	return strings.Split(path[1:], "."), nil
+}
+
+// pathScaleClient adapts a dynamic resource client to scaleClient for
+// custom resources without a scale subresource, by reading the fields of
+// ResourceItem.ReplicasPath and StatusReplicasPath, and applying the first.
+type pathScaleClient struct {
+	resource dynamic.ResourceInterface
+	// apiVersion and kind identify the resource in apply requests.
+	apiVersion string
+	kind       string
+	spec       []string
+	// status is nil when the current replicas are not reported, in which
+	// case the status replicas follow the spec.
+	status []string
+}
+
+func newPathScaleClient(resource dynamic.ResourceInterface, obj *unstructured.Unstructured, r ResourceItem) (pathScaleClient, error) {
+	c := pathScaleClient{resource: resource, apiVersion: obj.GetAPIVersion(), kind: obj.GetKind()}
+	var err error
+	if c.spec, err = parseFieldPath(r.ReplicasPath); err != nil {
+		return c, err
+	}
+	if r.StatusReplicasPath != "" {
+		if c.status, err = parseFieldPath(r.StatusReplicasPath); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// replicasAt returns the integer at path, which is decoded as int64 or
+// float64 depending on the client.
+func replicasAt(obj *unstructured.Unstructured, path []string) (int32, bool, error) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	if err != nil || !found {
+		return 0, found, err
+	}
+	switch v := value.(type) {
+	case int64:
+		return int32(v), true, nil
+	case float64:
+		return int32(v), true, nil
+	}
+	return 0, true, fmt.Errorf(".%s is a %T, not a replica count", strings.Join(path, "."), value)
+}
+
+func (c pathScaleClient) GetScale(ctx context.Context, name string, options metav1.GetOptions) (*autoscalingv1.Scale, error) {
+	obj, err := c.resource.Get(ctx, name, options)
+	if err != nil {
+		return nil, err
+	}
+	return c.scaleOf(obj)
+}
+
+// scaleOf returns the scale of a resource from its replica paths.
+func (c pathScaleClient) scaleOf(obj *unstructured.Unstructured) (*autoscalingv1.Scale, error) {
+	replicas, found, err := replicasAt(obj, c.spec)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("replicas path .%s not found", strings.Join(c.spec, "."))
+	}
+	scale := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Name: obj.GetName(), Namespace: obj.GetNamespace(), ResourceVersion: obj.GetResourceVersion()},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+		Status:     autoscalingv1.ScaleStatus{Replicas: replicas},
+	}
+	if c.status != nil {
+		// A missing status has not been reported yet, e.g. while the
+		// operator creates the first replicas.
+		if scale.Status.Replicas, _, err = replicasAt(obj, c.status); err != nil {
+			return nil, err
+		}
+	}
+	return scale, nil
+}
+
+func (c pathScaleClient) ApplyScale(ctx context.Context, name string, scale *autoscalingv1apply.ScaleApplyConfiguration, opts metav1.ApplyOptions) (*autoscalingv1.Scale, error) {
+	if scale.Spec == nil || scale.Spec.Replicas == nil {
+		return nil, fmt.Errorf("scale provided to ApplyScale must set the replicas")
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(c.apiVersion)
+	obj.SetKind(c.kind)
+	obj.SetName(name)
+	if err := unstructured.SetNestedField(obj.Object, int64(*scale.Spec.Replicas), c.spec...); err != nil {
+		return nil, err
+	}
+	applied, err := c.resource.Apply(ctx, name, obj, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.scaleOf(applied)
+}
+
+// waitForScaleSubresource polls the scale subresource, or the replica paths
+// of the resource, since the status layout of custom resources is not known
+// up front.
+func (e *execution) waitForScaleSubresource(ctx context.Context, t Target, scales scaleClient, targetReplicas int32) error {
+	poll := e.poller()
+	lastReplicas := int32(-1)
+	for {
+		scale, err := scales.GetScale(ctx, t.Item.Name, metav1.GetOptions{})
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if scale.Status.Replicas == targetReplicas {
+			return nil
+		}
+		progressed := scale.Status.Replicas != lastReplicas
+		if progressed {
+			e.emitReplicas(t, scale.Status.Replicas, targetReplicas, "Waiting for %s scale... Current replicas: %d", t.Item.Kind, scale.Status.Replicas)
+			lastReplicas = scale.Status.Replicas
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var rolloutsResource = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+func TestDynamicScaleClientApplyScale(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{rolloutsResource: "RolloutList"})
+	var action k8stesting.PatchAction
+	client.PrependReactor("patch", "rollouts", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		action = a.(k8stesting.PatchAction)
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+			"spec":       map[string]interface{}{"replicas": int64(0)},
+			"status":     map[string]interface{}{"replicas": int64(3)},
+		}}, nil
+	})
+
+	scales := dynamicScaleClient{resource: client.Resource(rolloutsResource).Namespace("shop")}
+	item := ResourceItem{Name: "web", Namespace: "shop", FieldManager: "parallel-scale-down-payments"}
+	scale, err := scales.ApplyScale(context.Background(), "web", scaleApply(0), applyOptions(item))
+	if err != nil {
+		t.Fatalf("ApplyScale() error = %v", err)
+	}
+	if scale.Spec.Replicas != 0 || scale.Status.Replicas != 3 {
+		t.Errorf("ApplyScale() = spec %d, status %d, want spec 0, status 3", scale.Spec.Replicas, scale.Status.Replicas)
+	}
+
+	if action == nil {
+		t.Fatal("ApplyScale() sent no patch")
+	}
+	if action.GetSubresource() != "scale" || action.GetName() != "web" || action.GetNamespace() != "shop" {
+		t.Errorf("patch of %s/%s subresource %q, want shop/web subresource scale", action.GetNamespace(), action.GetName(), action.GetSubresource())
+	}
+	if action.GetPatchType() != types.ApplyPatchType {
+		t.Errorf("patch type = %s, want %s", action.GetPatchType(), types.ApplyPatchType)
+	}
+	opts := action.(k8stesting.PatchActionImpl).PatchOptions
+	if opts.FieldManager != "parallel-scale-down-payments" || opts.Force == nil || !*opts.Force {
+		t.Errorf("patch options = %+v, want the field manager of the item, forced", opts)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(action.GetPatch(), &body); err != nil {
+		t.Fatalf("invalid patch %s: %v", action.GetPatch(), err)
+	}
+	want := map[string]interface{}{
+		"apiVersion": "autoscaling/v1",
+		"kind":       "Scale",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"spec":       map[string]interface{}{"replicas": float64(0)},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("patch = %s, want only the replicas of the scale", action.GetPatch())
+	}
+}
+
+func TestPathScaleClientApplyScale(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{rolloutsResource: "RolloutList"})
+	var action k8stesting.PatchAction
+	client.PrependReactor("patch", "rollouts", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		action = a.(k8stesting.PatchAction)
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+			"spec":       map[string]interface{}{"size": int64(1)},
+		}}, nil
+	})
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("argoproj.io/v1alpha1")
+	obj.SetKind("Rollout")
+	scales, err := newPathScaleClient(client.Resource(rolloutsResource).Namespace("shop"), obj, ResourceItem{ReplicasPath: ".spec.size"})
+	if err != nil {
+		t.Fatalf("newPathScaleClient() error = %v", err)
+	}
+	scale, err := scales.ApplyScale(context.Background(), "web", scaleApply(1), applyOptions(ResourceItem{}))
+	if err != nil {
+		t.Fatalf("ApplyScale() error = %v", err)
+	}
+	if scale.Spec.Replicas != 1 {
+		t.Errorf("ApplyScale() = spec %d, want 1", scale.Spec.Replicas)
+	}
+	if action.GetSubresource() != "" || action.GetPatchType() != types.ApplyPatchType {
+		t.Errorf("patch of subresource %q with type %s, want an apply of the resource", action.GetSubresource(), action.GetPatchType())
+	}
+	if opts := action.(k8stesting.PatchActionImpl).PatchOptions; opts.FieldManager != FieldManager {
+		t.Errorf("field manager = %q, want %q", opts.FieldManager, FieldManager)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(action.GetPatch(), &body); err != nil {
+		t.Fatalf("invalid patch %s: %v", action.GetPatch(), err)
+	}
+	want := map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"spec":       map[string]interface{}{"size": float64(1)},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("patch = %s, want only the replica path of the resource", action.GetPatch())
+	}
+}
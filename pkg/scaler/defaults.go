@@ -0,0 +1,127 @@
+package scaler
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults are the values of the defaults section of a config, applied to
+// every item that omits them.
+type Defaults struct {
+	// Namespace is the namespace of the items without one. It takes
+	// precedence over the namespace of the kubeconfig context.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Replicas is the target of the items and namespaces without one. It
+	// does not apply to cronjobs and jobs.
+	Replicas *Replicas `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	// Timeout and WaitFor are the ResourceItem.Timeout and
+	// ResourceItem.WaitFor of the items and namespaces without one.
+	Timeout string  `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	WaitFor WaitFor `json:"waitFor,omitempty" yaml:"waitFor,omitempty"`
+}
+
+// WithDefaults returns a copy of the config with Defaults applied to its
+// items and namespaces, and without Defaults, so that configs merged or split
+// afterwards keep the defaults of the file they came from.
+func (c Config) WithDefaults() Config {
+	if c.Defaults == nil {
+		return c
+	}
+	d := *c.Defaults
+	apply := func(items []ResourceItem, kindOf func(ResourceItem) Kind) []ResourceItem {
+		result := make([]ResourceItem, len(items))
+		for i, item := range items {
+			kind := kindOf(item)
+			if item.Namespace == "" {
+				item.Namespace = d.Namespace
+			}
+			if item.Replicas == nil && kind != KindCronJob && kind != KindJob {
+				item.Replicas = d.Replicas
+			}
+			if item.Timeout == "" {
+				item.Timeout = d.Timeout
+			}
+			if item.WaitFor == "" {
+				item.WaitFor = d.WaitFor
+			}
+			result[i] = item
+		}
+		return result
+	}
+	for _, s := range configSections {
+		section := c.sectionRef(s.kind)
+		*section = apply(*section, func(ResourceItem) Kind { return s.kind })
+	}
+	c.Resources = apply(c.Resources, func(item ResourceItem) Kind {
+		kind, _ := resourceKind(item)
+		return kind
+	})
+	namespaces := make([]NamespaceItem, len(c.Namespaces))
+	for i, ns := range c.Namespaces {
+		if ns.Replicas == nil {
+			ns.Replicas = d.Replicas
+		}
+		if ns.Timeout == "" {
+			ns.Timeout = d.Timeout
+		}
+		if ns.WaitFor == "" {
+			ns.WaitFor = d.WaitFor
+		}
+		namespaces[i] = ns
+	}
+	c.Namespaces = namespaces
+	c.Defaults = nil
+	return c
+}
+
+// validateTimeout returns the problem of the timeout of an item, if any.
+func validateTimeout(timeout string) []string {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d < 0 {
+		return []string{fmt.Sprintf("invalid timeout %q", timeout)}
+	}
+	return nil
+}
+
+// validateWaitFor returns the problem of the waitFor of an item, if any.
+// WaitForCustom requires a condition, which only items have.
+func validateWaitFor(waitFor WaitFor, condition *WaitCondition) []string {
+	switch waitFor {
+	case "", WaitForReplicas, WaitForReady, WaitForReadyReplicas, WaitForEndpoints, WaitForEndpointsDrained, WaitForPodsDeleted, WaitForVolumesDetached:
+		if condition != nil {
+			return []string{"condition requires waitFor custom"}
+		}
+		return nil
+	case WaitForCustom:
+		if condition == nil || (condition.ScaleDown == "" && condition.Restore == "") {
+			return []string{"waitFor custom requires a condition"}
+		}
+		return condition.validate()
+	}
+	return []string{fmt.Sprintf("unsupported waitFor %q, must be one of: replicas, ready, readyReplicas, endpoints, endpointsDrained, podsDeleted, volumesDetached, custom", waitFor)}
+}
+
+// timeout returns the time limit of a target, see ResourceItem.Timeout.
+func (e *execution) timeout(t Target) time.Duration {
+	if t.Item.Timeout != "" {
+		if timeout, err := time.ParseDuration(t.Item.Timeout); err == nil {
+			return timeout
+		}
+	}
+	return e.opts.Timeout
+}
+
+// waitFor returns the condition a target is waited for, see
+// ResourceItem.WaitFor. The waves of a reverse restore followed by another
+// wave are waited for until ready.
+func (e *execution) waitFor(t Target) WaitFor {
+	switch {
+	case t.Item.WaitFor != "":
+		return t.Item.WaitFor.normalized()
+	case e.readyForNextWave:
+		return WaitForReady
+	}
+	return e.opts.WaitFor.normalized()
+}
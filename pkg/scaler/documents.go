@@ -0,0 +1,88 @@
+package scaler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// document is a YAML document of a config file and the line it starts at.
+type document struct {
+	data []byte
+	line int
+}
+
+// splitDocuments splits a YAML stream at its "---" separators. Documents
+// holding only comments and blank lines are dropped.
+func splitDocuments(data []byte) []document {
+	var docs []document
+	var current bytes.Buffer
+	start := 1
+	flush := func() {
+		if hasContent(current.Bytes()) {
+			docs = append(docs, document{data: bytes.Clone(current.Bytes()), line: start})
+		}
+		current.Reset()
+	}
+	for i, line := range strings.SplitAfter(string(data), "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- ") || strings.HasPrefix(trimmed, "---\t") {
+			flush()
+			start = i + 2
+			continue
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return docs
+}
+
+func hasContent(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDocuments parses every document of a YAML config file and merges
+// them, as MergeConfigs does with files: an item listed by two documents is
+// a problem. The problems of a document are reported with their line in the
+// file.
+func parseDocuments(docs []document) (*Config, error) {
+	var sources []string
+	var configs []*Config
+	var problems []string
+	for i, doc := range docs {
+		cfg, err := parseDocument(doc.data)
+		var configErr *ConfigError
+		switch {
+		case errors.As(err, &configErr):
+			for _, problem := range configErr.Problems {
+				problems = append(problems, shiftLine(problem, doc.line-1))
+			}
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		sources = append(sources, fmt.Sprintf("document %d (line %d)", i+1, doc.line))
+		configs = append(configs, cfg)
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return MergeConfigs(sources, configs)
+}
+
+// shiftLine adds offset to the line of a problem, see problemLine.
+func shiftLine(problem string, offset int) string {
+	line := problemLine(problem)
+	if line == 0 {
+		return problem
+	}
+	return "line " + strconv.Itoa(line+offset) + strings.TrimPrefix(problem, "line "+strconv.Itoa(line))
+}
@@ -0,0 +1,86 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ServerDryRun submits the replica change of every entry of a plan to the
+// API server with dryRun=All, so that validation and admission webhooks check
+// it without anything being persisted. A change that is refused is set in
+// PlanEntry.Rejected.
+func (s *Scaler) ServerDryRun(ctx context.Context, mode Mode, plan []PlanEntry) []PlanEntry {
+	for i := range plan {
+		p := &plan[i]
+		if p.Err != nil || p.CurrentReplicas == p.TargetReplicas {
+			continue
+		}
+		p.Rejected = s.dryRun(ctx, mode, *p)
+	}
+	return plan
+}
+
+// dryRun submits the change of a plan entry with dryRun=All.
+func (s *Scaler) dryRun(ctx context.Context, mode Mode, p PlanEntry) error {
+	t := p.Target
+	dryRun := []string{metav1.DryRunAll}
+	switch t.Kind {
+	case KindCronJob:
+		patch, err := json.Marshal(map[string]interface{}{
+			"spec": map[string]interface{}{"suspend": p.TargetReplicas == 0},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.client.BatchV1().CronJobs(t.Item.Namespace).Patch(ctx, t.Item.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun, FieldManager: FieldManager})
+		return err
+	case KindJob:
+		jobs := s.client.BatchV1().Jobs(t.Item.Namespace)
+		if mode == ModeScaleDown && jobPolicy(t.Item) == JobPolicyDelete {
+			return jobs.Delete(ctx, t.Item.Name, metav1.DeleteOptions{DryRun: dryRun})
+		}
+		if mode == ModeScaleDown && jobPolicy(t.Item) == JobPolicyWait {
+			return nil
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"spec": map[string]interface{}{"suspend": mode == ModeScaleDown},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = jobs.Patch(ctx, t.Item.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun, FieldManager: FieldManager})
+		return err
+	case KindKnativeService:
+		resource, err := s.custom.kindResource(KindKnativeService, t.Item.Namespace)
+		if err != nil {
+			return err
+		}
+		svc, err := resource.Get(ctx, t.Item.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var patch []byte
+		if mode == ModeScaleDown {
+			patch, err = knativeScaleDownPatch(svc, p.CurrentReplicas, p.TargetReplicas)
+		} else {
+			patch, err = knativeRestorePatch(svc.GetAnnotations()[KnativeScaleAnnotation])
+		}
+		if err != nil {
+			return err
+		}
+		_, err = resource.Patch(ctx, t.Item.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun, FieldManager: FieldManager})
+		return err
+	}
+
+	w, err := s.getWorkload(ctx, t)
+	if err != nil {
+		return err
+	}
+	opts := applyOptions(t.Item)
+	opts.DryRun = dryRun
+	_, err = w.scales.ApplyScale(ctx, t.Item.Name, scaleApply(p.TargetReplicas), opts)
+	return err
+}
@@ -0,0 +1,110 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// drainsEndpoints reports whether the Services selecting the pods of a target
+// are waited for, see WaitForEndpoints.
+func (e *execution) drainsEndpoints(t Target, target int32) bool {
+	return e.waitFor(t) == WaitForEndpoints && e.mode == ModeScaleDown && target == 0
+}
+
+// selectingServices returns the names of the Services of a namespace whose
+// selector matches the pod labels of a workload. Services without a selector
+// have their endpoints managed by hand and are ignored.
+func (s *Scaler) selectingServices(ctx context.Context, namespace string, podLabels map[string]string) ([]string, error) {
+	list, err := s.client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing services: %w", err)
+	}
+	var names []string
+	for _, svc := range list.Items {
+		if len(svc.Spec.Selector) > 0 && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(podLabels)) {
+			names = append(names, svc.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readyEndpoints counts the ready endpoints of a Service across its
+// EndpointSlices. An endpoint without a ready condition is ready.
+func (s *Scaler) readyEndpoints(ctx context.Context, namespace, service string) (int, error) {
+	list, err := s.client.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{LabelSelector: discoveryv1.LabelServiceName + "=" + service})
+	if err != nil {
+		return 0, fmt.Errorf("listing endpoint slices of service %s: %w", service, err)
+	}
+	var count int
+	for _, slice := range list.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// readyEndpointsOf counts the ready endpoints of several Services.
+func (s *Scaler) readyEndpointsOf(ctx context.Context, namespace string, services []string) (int, error) {
+	var ready int
+	for _, service := range services {
+		count, err := s.readyEndpoints(ctx, namespace, service)
+		if err != nil {
+			return 0, err
+		}
+		ready += count
+	}
+	return ready, nil
+}
+
+// waitForEndpoints polls the Services selecting the pods of a workload scaled
+// down to zero until none of them has a ready endpoint left, so that no
+// traffic is routed to the workload anymore.
+func (e *execution) waitForEndpoints(ctx context.Context, t Target, w *workload) error {
+	if w.podLabels == nil {
+		e.emit(EventWarning, t, "Pod labels unknown, not waiting for the endpoints of its Services.")
+		return nil
+	}
+	services, err := e.selectingServices(ctx, t.Item.Namespace, w.podLabels)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		e.emit(EventProgress, t, "No Service selects its pods.")
+		return nil
+	}
+
+	poll := e.poller()
+	last := -1
+	for {
+		ready, err := e.readyEndpointsOf(ctx, t.Item.Namespace, services)
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if ready == 0 {
+			e.emit(EventProgress, t, "Endpoints drained from Services %s.", strings.Join(services, ", "))
+			return nil
+		}
+		progressed := ready != last
+		if progressed {
+			e.emit(EventProgress, t, "Waiting for endpoints to drain... Ready endpoints: %d", ready)
+			last = ready
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EventSource is the component of the Kubernetes Events recorded on
+	// scaled resources.
+	EventSource = "parallel-scale-down"
+	// EventReasonScaledDown is the reason of the Events recorded by a scale
+	// down, and EventReasonScaledUp of a scale down raising the replicas.
+	EventReasonScaledDown = "ScaledDownForMaintenance"
+	EventReasonScaledUp   = "ScaledUpForMaintenance"
+	// EventReasonRestored is the reason of the Events recorded by a restore.
+	EventReasonRestored = "RestoredAfterMaintenance"
+)
+
+// recordEvent records a Kubernetes Event on a scaled resource, so that
+// kubectl describe and cluster audits show why its replicas changed. A
+// failure only raises a warning.
+func (e *execution) recordEvent(ctx context.Context, t Target, w *workload, previous, target int32) {
+	if !e.opts.RecordEvents {
+		return
+	}
+	reason, action := EventReasonScaledDown, "for maintenance"
+	switch {
+	case e.mode == ModeRestore:
+		reason, action = EventReasonRestored, "after maintenance"
+	case target > previous:
+		reason = EventReasonScaledUp
+	}
+	message := fmt.Sprintf("Scaled from %d to %d replicas %s (original replicas: %d) by %s", previous, target, action, originalReplicas(w.annotations, previous), EventSource)
+	if e.opts.Actor != "" {
+		message += ", run by " + e.opts.Actor
+	}
+	var annotations map[string]string
+	if e.opts.RunID != "" {
+		message += " (run " + e.opts.RunID + ")"
+		annotations = map[string]string{RunAnnotation: e.opts.RunID}
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s.%x", t.Item.Name, time.Now().UnixNano()),
+			Namespace:   t.Item.Namespace,
+			Annotations: annotations,
+		},
+		InvolvedObject:      w.ref,
+		Reason:              reason,
+		Message:             message,
+		Type:                corev1.EventTypeNormal,
+		Source:              corev1.EventSource{Component: EventSource},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: EventSource,
+	}
+	if _, err := e.client.CoreV1().Events(t.Item.Namespace).Create(ctx, event, metav1.CreateOptions{FieldManager: FieldManager}); err != nil {
+		e.emit(EventWarning, t, "Could not record a Kubernetes Event: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// evictionRetryInterval is how often an eviction refused by a
+// PodDisruptionBudget is retried, as kubectl drain does.
+const evictionRetryInterval = 5 * time.Second
+
+// evicting reports whether the pods of a target are evicted before it is
+// scaled down.
+func (e *execution) evicting(t Target) bool {
+	if e.mode != ModeScaleDown || t.Kind == KindCronJob || t.Kind == KindJob || t.Kind == KindKnativeService {
+		return false
+	}
+	return t.Item.Strategy == StrategyEvict || (t.Item.Strategy == "" && e.opts.Evict)
+}
+
+// scaleByEviction evicts the pods removed by a scale down through the
+// Eviction API, one at a time, waiting for each to terminate, and only then
+// sets the target replicas. PodDisruptionBudgets are honored: a refused
+// eviction is retried until the budget allows it or ctx is done.
+func (e *execution) scaleByEviction(ctx context.Context, t Target, w *workload, targetReplicas int32, res *Result) error {
+	if w.selector == "" {
+		return fmt.Errorf("strategy %q requires a pod selector, %s has none", StrategyEvict, t.Kind)
+	}
+	pods := e.client.CoreV1().Pods(t.Item.Namespace)
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: w.selector})
+	if err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+	victims := evictionOrder(t.Kind, list.Items, int(w.replicas-targetReplicas))
+
+	for i, pod := range victims {
+		e.emitReplicas(t, w.replicas-int32(i), targetReplicas, "Evicting pod %s (%d/%d)...", pod.Name, i+1, len(victims))
+		if err := e.evictPod(ctx, t, pod); err != nil {
+			return fmt.Errorf("evicting pod %s: %w", pod.Name, err)
+		}
+		if err := e.waitForPodDeleted(ctx, pod.Namespace, pod.Name, pod.UID); err != nil {
+			return fmt.Errorf("waiting for pod %s to terminate: %w", pod.Name, err)
+		}
+	}
+
+	changed, err := updateScale(ctx, e.opts.Retry, w.scales, t, targetReplicas)
+	if err != nil {
+		return err
+	}
+	res.changed = res.changed || changed || len(victims) > 0
+	e.emitReplicas(t, w.replicas, targetReplicas, "Evicted %d pods, scale command sent. Watching for %d replicas...", len(victims), targetReplicas)
+	return e.waitForReplicas(ctx, t, w, targetReplicas)
+}
+
+// evictPod evicts a pod, retrying while a PodDisruptionBudget refuses it. A
+// pod that is already gone counts as evicted.
+func (e *execution) evictPod(ctx context.Context, t Target, pod corev1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		DeleteOptions: &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &pod.UID},
+		},
+	}
+	warned := false
+	for {
+		err := e.client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil, apierrors.IsNotFound(err), apierrors.IsConflict(err):
+			// A conflict means the UID changed: the pod was replaced.
+			return nil
+		case !apierrors.IsTooManyRequests(err):
+			return err
+		}
+		if !warned {
+			e.emit(EventWarning, t, "Eviction of pod %s refused by a PodDisruptionBudget, retrying every %s...", pod.Name, evictionRetryInterval)
+			warned = true
+		}
+		select {
+		case <-time.After(evictionRetryInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("refused by a PodDisruptionBudget: %w", ctx.Err())
+		}
+	}
+}
+
+// evictionOrder returns the count pods that the controller of the kind
+// would remove first when scaling down: the highest ordinals of a
+// StatefulSet, and otherwise the pods that are not ready, then the newest.
+// Pods already terminating are left out.
+func evictionOrder(kind Kind, pods []corev1.Pod, count int) []corev1.Pod {
+	var candidates []corev1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			candidates = append(candidates, pod)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if kind == KindStatefulSet {
+			return podOrdinal(a.Name) > podOrdinal(b.Name)
+		}
+		if podReady(a) != podReady(b) {
+			return !podReady(a)
+		}
+		return b.CreationTimestamp.Before(&a.CreationTimestamp)
+	})
+	if count < len(candidates) {
+		candidates = candidates[:max(count, 0)]
+	}
+	return candidates
+}
+
+// podOrdinal returns the ordinal of a StatefulSet pod, or -1 if its name has
+// none.
+func podOrdinal(name string) int {
+	i := strings.LastIndex(name, "-")
+	ordinal, err := strconv.Atoi(name[i+1:])
+	if i < 0 || err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package scaler
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// envReference matches ${VAR} and ${VAR:-default}. A "$" that is not
+// followed by a brace is left as is.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+var templateFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// default returns value, or def if value is empty, for pipelines such
+	// as {{ env "ENV" | default "dev" }}.
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// expand evaluates the Go template of a config value, such as
+// {{ .Env.ENV }} or {{ env "ENV" | lower }}, then substitutes its ${VAR} and
+// ${VAR:-default} references to environment variables. A variable that is
+// not set and has no default is an error.
+func expand(value string) (string, error) {
+	if strings.Contains(value, "{{") {
+		tmpl, err := template.New("value").Option("missingkey=error").Funcs(templateFuncs).Parse(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid template %q: %w", value, err)
+		}
+		env := map[string]string{}
+		for _, kv := range os.Environ() {
+			k, v, _ := strings.Cut(kv, "=")
+			env[k] = v
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, map[string]interface{}{"Env": env}); err != nil {
+			return "", fmt.Errorf("template %q: %w", value, err)
+		}
+		value = b.String()
+	}
+
+	var missing []string
+	value = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		m := envReference.FindStringSubmatch(ref)
+		v, ok := os.LookupEnv(m[1])
+		switch {
+		case ok && (m[2] == "" || v != ""):
+			return v
+		case m[2] != "":
+			return m[3]
+		}
+		missing = append(missing, m[1])
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return value, nil
+}
+
+// expandFields expands the names and namespaces of the items of a parsed
+// config. It calls problem for every value that cannot be expanded.
+func (c *Config) expandFields(problem func(section string, i int, err error)) {
+	expandField := func(section string, i int, field *string) {
+		expanded, err := expand(*field)
+		if err != nil {
+			problem(section, i, err)
+			return
+		}
+		*field = expanded
+	}
+	for _, s := range configSections {
+		items := *c.sectionRef(s.kind)
+		for i := range items {
+			expandField(s.key, i, &items[i].Name)
+			expandField(s.key, i, &items[i].Namespace)
+		}
+	}
+	for i := range c.Resources {
+		expandField("resources", i, &c.Resources[i].Name)
+		expandField("resources", i, &c.Resources[i].Namespace)
+	}
+	for i := range c.Namespaces {
+		expandField("namespaces", i, &c.Namespaces[i].Name)
+	}
+	if c.Defaults != nil {
+		expandField("defaults", -1, &c.Defaults.Namespace)
+	}
+}
@@ -0,0 +1,78 @@
+package scaler
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Filter keeps the targets matching a reference of only, or every target if
+// only is empty, and drops the ones matching a reference of exclude.
+// References are "name" or "namespace/name". A reference that matches no
+// target is an error, so that a typo cannot scale a resource that was meant
+// to be left alone.
+func Filter(targets []Target, only, exclude []string) ([]Target, error) {
+	if err := checkRefs(targets, "only", only); err != nil {
+		return nil, err
+	}
+	if err := checkRefs(targets, "exclude", exclude); err != nil {
+		return nil, err
+	}
+
+	var result []Target
+	for _, t := range targets {
+		if len(only) > 0 && !matchesAnyRef(t, only) {
+			continue
+		}
+		if matchesAnyRef(t, exclude) {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+func matchesAnyRef(t Target, refs []string) bool {
+	for _, ref := range refs {
+		if matchesRef(t, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRefs(targets []Target, name string, refs []string) error {
+	for _, ref := range refs {
+		found := false
+		for _, t := range targets {
+			if matchesRef(t, ref) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s filter %q does not match any resource", name, ref)
+		}
+	}
+	return nil
+}
+
+// FilterGroups keeps the targets of an item tagged with any of groups, see
+// ResourceItem.Groups, or every target if groups is empty. A group that
+// tags no target is an error, like the references of Filter.
+func FilterGroups(targets []Target, groups []string) ([]Target, error) {
+	if len(groups) == 0 {
+		return targets, nil
+	}
+	for _, group := range groups {
+		if !slices.ContainsFunc(targets, func(t Target) bool { return slices.Contains(t.Item.Groups, group) }) {
+			return nil, fmt.Errorf("group %q does not match any resource", group)
+		}
+	}
+	var result []Target
+	for _, t := range targets {
+		if slices.ContainsFunc(t.Item.Groups, func(group string) bool { return slices.Contains(groups, group) }) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,246 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultArgoCDNamespace is where Argo CD Applications are looked up unless
+// the tracking annotation names another namespace.
+const DefaultArgoCDNamespace = "argocd"
+
+const (
+	argoTrackingAnnotation   = "argocd.argoproj.io/tracking-id"
+	fluxKustomizationLabel   = "kustomize.toolkit.fluxcd.io/name"
+	fluxKustomizationNSLabel = "kustomize.toolkit.fluxcd.io/namespace"
+	fluxHelmReleaseLabel     = "helm.toolkit.fluxcd.io/name"
+	fluxHelmReleaseNSLabel   = "helm.toolkit.fluxcd.io/namespace"
+)
+
+var (
+	argoApplications   = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+	fluxKustomizations = schema.GroupVersionResource{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"}
+	fluxHelmReleases   = schema.GroupVersionResource{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"}
+)
+
+// gitOpsOwner is an Argo CD Application or Flux object that reconciles a
+// workload and would revert a scale down.
+type gitOpsOwner struct {
+	kind      string
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func (o gitOpsOwner) String() string {
+	return fmt.Sprintf("%s %s/%s", o.kind, o.namespace, o.name)
+}
+
+func (o gitOpsOwner) key() string {
+	return o.resource.String() + "/" + o.namespace + "/" + o.name
+}
+
+// gitOpsOwners detects the GitOps objects managing a workload from the
+// labels and annotations Argo CD and Flux set on the objects they apply.
+func (s *Scaler) gitOpsOwners(t Target, labels, annotations map[string]string) []gitOpsOwner {
+	var owners []gitOpsOwner
+	if id := annotations[argoTrackingAnnotation]; id != "" {
+		// The tracking id is "<app>:<group>/<kind>:<namespace>/<name>", where
+		// <app> is "<namespace>_<name>" for Applications outside the Argo CD
+		// namespace.
+		app, _, _ := strings.Cut(id, ":")
+		namespace := s.opts.ArgoCDNamespace
+		if namespace == "" {
+			namespace = DefaultArgoCDNamespace
+		}
+		if ns, name, ok := strings.Cut(app, "_"); ok {
+			namespace, app = ns, name
+		}
+		owners = append(owners, gitOpsOwner{kind: "Argo CD Application", resource: argoApplications, namespace: namespace, name: app})
+	}
+	if name := labels[fluxKustomizationLabel]; name != "" {
+		owners = append(owners, gitOpsOwner{kind: "Flux Kustomization", resource: fluxKustomizations, namespace: fluxNamespace(labels[fluxKustomizationNSLabel], t), name: name})
+	}
+	if name := labels[fluxHelmReleaseLabel]; name != "" {
+		owners = append(owners, gitOpsOwner{kind: "Flux HelmRelease", resource: fluxHelmReleases, namespace: fluxNamespace(labels[fluxHelmReleaseNSLabel], t), name: name})
+	}
+	return owners
+}
+
+func fluxNamespace(label string, t Target) string {
+	if label != "" {
+		return label
+	}
+	return t.Item.Namespace
+}
+
+// pauseGitOps suspends the reconciliation of the GitOps objects managing a
+// target with Options.SuspendGitOps, or of the Flux ones with
+// Options.SuspendFlux, and otherwise warns that they may scale it back up.
+// Every object is suspended once per run.
+func (e *execution) pauseGitOps(ctx context.Context, t Target, labels, annotations map[string]string) error {
+	for _, owner := range e.gitOpsOwners(t, labels, annotations) {
+		if !e.opts.SuspendGitOps && !(e.opts.SuspendFlux && owner.resource != argoApplications) {
+			e.emit(EventWarning, t, "Managed by %s, which may scale it back up unless its reconciliation is suspended.", owner)
+			continue
+		}
+		if err := e.onceForOwner(owner, func() error { return e.suspendOwner(ctx, t, owner) }); err != nil {
+			return fmt.Errorf("failed to suspend %s: %w", owner, err)
+		}
+	}
+	return nil
+}
+
+// resumeGitOps resumes the reconciliation of the GitOps objects suspended by
+// pauseGitOps.
+func (e *execution) resumeGitOps(ctx context.Context, t Target, labels, annotations map[string]string) error {
+	for _, owner := range e.gitOpsOwners(t, labels, annotations) {
+		if err := e.onceForOwner(owner, func() error { return e.resumeOwner(ctx, t, owner) }); err != nil {
+			return fmt.Errorf("failed to resume %s: %w", owner, err)
+		}
+	}
+	return nil
+}
+
+// onceForOwner runs fn for the first target of an owner and returns its
+// error to every other target of the same owner.
+func (e *execution) onceForOwner(owner gitOpsOwner, fn func() error) error {
+	e.gitOpsMu.Lock()
+	defer e.gitOpsMu.Unlock()
+	if e.gitOpsDone == nil {
+		e.gitOpsDone = map[string]error{}
+	}
+	if err, done := e.gitOpsDone[owner.key()]; done {
+		return err
+	}
+	err := fn()
+	e.gitOpsDone[owner.key()] = err
+	return err
+}
+
+func (e *execution) ownerResource(owner gitOpsOwner) (dynamic.ResourceInterface, error) {
+	if e.opts.Dynamic == nil {
+		return nil, fmt.Errorf("suspending GitOps reconciliation requires a dynamic client")
+	}
+	return e.opts.Dynamic.Resource(owner.resource).Namespace(owner.namespace), nil
+}
+
+func mergePatch(ctx context.Context, resource dynamic.ResourceInterface, name string, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = resource.Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
+
+// suspendOwner sets spec.suspend on Flux objects, and removes the automated
+// sync policy of Argo CD Applications after saving it in an annotation.
+func (e *execution) suspendOwner(ctx context.Context, t Target, owner gitOpsOwner) error {
+	resource, err := e.ownerResource(owner)
+	if err != nil {
+		return err
+	}
+	obj, err := resource.Get(ctx, owner.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if owner.resource == argoApplications {
+		automated, found, err := unstructured.NestedMap(obj.Object, "spec", "syncPolicy", "automated")
+		if err != nil {
+			return err
+		}
+		if !found {
+			e.emit(EventProgress, t, "%s has no automated sync, nothing to suspend.", owner)
+			return nil
+		}
+		saved, err := json.Marshal(automated)
+		if err != nil {
+			return err
+		}
+		if err := mergePatch(ctx, resource, owner.name, map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]interface{}{AutomatedSyncAnnotation: string(saved)}},
+			"spec":     map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": nil}},
+		}); err != nil {
+			return err
+		}
+		e.emit(EventProgress, t, "Disabled automated sync of %s.", owner)
+		return nil
+	}
+
+	if suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend"); suspended {
+		e.emit(EventProgress, t, "%s is already suspended.", owner)
+		return nil
+	}
+	if err := mergePatch(ctx, resource, owner.name, map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{SuspendedAnnotation: "true"}},
+		"spec":     map[string]interface{}{"suspend": true},
+	}); err != nil {
+		return err
+	}
+	e.emit(EventProgress, t, "Suspended %s.", owner)
+	return nil
+}
+
+// resumeOwner undoes suspendOwner. Objects that were not suspended by this
+// tool, or that cannot be read, are left alone.
+func (e *execution) resumeOwner(ctx context.Context, t Target, owner gitOpsOwner) error {
+	resource, err := e.ownerResource(owner)
+	if err != nil {
+		return err
+	}
+	obj, err := resource.Get(ctx, owner.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if apierrors.IsForbidden(err) {
+		// It cannot have been suspended by the caller either.
+		e.emit(EventWarning, t, "Not allowed to read %s, leaving its reconciliation as is.", owner)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+
+	if owner.resource == argoApplications {
+		value, ok := annotations[AutomatedSyncAnnotation]
+		if !ok {
+			return nil
+		}
+		var automated map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &automated); err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", AutomatedSyncAnnotation, err)
+		}
+		if err := mergePatch(ctx, resource, owner.name, map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]interface{}{AutomatedSyncAnnotation: nil}},
+			"spec":     map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": automated}},
+		}); err != nil {
+			return err
+		}
+		e.emit(EventProgress, t, "Re-enabled automated sync of %s.", owner)
+		return nil
+	}
+
+	if _, ok := annotations[SuspendedAnnotation]; !ok {
+		return nil
+	}
+	if err := mergePatch(ctx, resource, owner.name, map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{SuspendedAnnotation: nil}},
+		"spec":     map[string]interface{}{"suspend": false},
+	}); err != nil {
+		return err
+	}
+	e.emit(EventProgress, t, "Resumed %s.", owner)
+	return nil
+}
@@ -0,0 +1,87 @@
+package scaler
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs several Scalers together, e.g. one per cluster, so that they
+// fail as one run: with ErrorPolicyFailFast, a failure in any of them stops
+// all of them, and with ErrorPolicyRollback, every run rolls back what it
+// changed when any of them failed, even if its own targets succeeded.
+type Group struct {
+	mu         sync.Mutex
+	executions []*execution
+	aborted    bool
+
+	pending int
+	failed  bool
+	done    chan struct{}
+}
+
+// NewGroup returns a Group of n runs. Every one of them must be started with
+// Group.Run.
+func NewGroup(n int) *Group {
+	g := &Group{pending: n, done: make(chan struct{})}
+	if n == 0 {
+		close(g.done)
+	}
+	return g
+}
+
+// Run runs the targets of a Scaler like Scaler.Run, as a member of the
+// group. Preflight must have passed for the targets of every member, so it
+// is not run again.
+func (g *Group) Run(ctx context.Context, s *Scaler, mode Mode, targets []Target) (Report, error) {
+	e := &execution{Scaler: s, mode: mode, group: g, preflighted: true}
+	return e.run(ctx, targets)
+}
+
+// join registers the execution of a member, aborting it right away if
+// another member already failed.
+func (g *Group) join(e *execution) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.executions = append(g.executions, e)
+	if g.aborted && e.abort != nil {
+		e.aborted.Store(true)
+		e.abort()
+	}
+}
+
+// abort stops every member that fails fast.
+func (g *Group) abort() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.aborted = true
+	for _, e := range g.executions {
+		if e.abort != nil {
+			e.aborted.Store(true)
+			e.abort()
+		}
+	}
+}
+
+// finish records that a member is done with its targets, and whether it
+// failed. Every member calls it exactly once.
+func (g *Group) finish(failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failed = g.failed || failed
+	g.pending--
+	if g.pending == 0 {
+		close(g.done)
+	}
+}
+
+// anyFailed waits for every member to finish and reports whether any of
+// them failed.
+func (g *Group) anyFailed(ctx context.Context) bool {
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failed
+}
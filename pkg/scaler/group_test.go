@@ -0,0 +1,99 @@
+package scaler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+// groupCluster is a fake cluster with a Deployment web of 3 replicas, whose
+// pods are gone after the scale down unless stuck is set.
+func groupCluster(t *testing.T, stuck bool) *fake.Clientset {
+	t.Helper()
+	status := appsv1.DeploymentStatus{}
+	if stuck {
+		status.Replicas = 3
+	}
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+		Status:     status,
+	})
+	deploymentScales(t, client)
+	return client
+}
+
+// runGroup runs a scale down of web to 0 in every cluster as one group.
+func runGroup(t *testing.T, opts Options, clients ...*fake.Clientset) ([]Report, []error) {
+	t.Helper()
+	opts.SkipPreflight = true
+	group := NewGroup(len(clients))
+	reports := make([]Report, len(clients))
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			targets := []Target{{Kind: KindDeployment, Item: ResourceItem{Name: "web", Namespace: "shop", Replicas: ReplicaCount(0)}}}
+			reports[i], errs[i] = group.Run(context.Background(), New(client, opts), ModeScaleDown, targets)
+		}()
+	}
+	wg.Wait()
+	return reports, errs
+}
+
+func replicasOfWeb(t *testing.T, client *fake.Clientset) int32 {
+	t.Helper()
+	d, err := client.AppsV1().Deployments("shop").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return specReplicas(d.Spec.Replicas)
+}
+
+func TestGroupRollsBackEveryMember(t *testing.T) {
+	ok, stuck := groupCluster(t, false), groupCluster(t, true)
+	reports, errs := runGroup(t, Options{OnError: ErrorPolicyRollback, Timeout: 200 * time.Millisecond}, ok, stuck)
+
+	if errs[0] == nil || !strings.Contains(errs[0].Error(), "another cluster failed") {
+		t.Errorf("error of the succeeding cluster = %v, want another cluster failed", errs[0])
+	}
+	if errs[1] == nil {
+		t.Error("error of the failing cluster = nil, want a timeout")
+	}
+	for i, client := range []*fake.Clientset{ok, stuck} {
+		if len(reports[i].Rollback) != 1 {
+			t.Errorf("cluster %d rolled back %d resources, want 1", i, len(reports[i].Rollback))
+		}
+		if got := replicasOfWeb(t, client); got != 3 {
+			t.Errorf("cluster %d has %d replicas after the rollback, want 3", i, got)
+		}
+	}
+}
+
+func TestGroupFailFastStopsEveryMember(t *testing.T) {
+	missing := fake.NewClientset()
+	stuck := groupCluster(t, true)
+	start := time.Now()
+	reports, errs := runGroup(t, Options{OnError: ErrorPolicyFailFast, Timeout: time.Minute}, missing, stuck)
+
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("the group took %s, want the stuck cluster stopped by the failure of the other", elapsed)
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("error of cluster %d = nil, want a failure", i)
+		}
+	}
+	if err := reports[1].Results[0].Err; err == nil || !strings.Contains(err.Error(), "another resource failed") {
+		t.Errorf("error of the stuck target = %v, want it cancelled because another resource failed", err)
+	}
+}
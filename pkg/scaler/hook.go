@@ -0,0 +1,288 @@
+package scaler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultHookTimeout limits hooks that do not set a timeout.
+const DefaultHookTimeout = time.Minute
+
+// Hook is an action run before or after a resource, or a whole run, is
+// scaled. Exactly one of Command, HTTP and Exec must be set.
+type Hook struct {
+	// Command runs a local command. The mode, phase, cluster and resource
+	// are passed in the SCALE_DOWN_MODE, SCALE_DOWN_PHASE,
+	// SCALE_DOWN_CONTEXT, SCALE_DOWN_KIND, SCALE_DOWN_NAMESPACE and
+	// SCALE_DOWN_NAME environment variables.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// HTTP sends a request and expects a 2xx response.
+	HTTP *HTTPHook `json:"http,omitempty" yaml:"http,omitempty"`
+	// Exec runs a command in a pod.
+	Exec *ExecHook `json:"exec,omitempty" yaml:"exec,omitempty"`
+	// Modes restricts the hook to "scale-down" or "restore". Empty runs it
+	// in both modes.
+	Modes []Mode `json:"modes,omitempty" yaml:"modes,omitempty"`
+	// Timeout is a duration such as "30s". It defaults to
+	// DefaultHookTimeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// HTTPHook is a request sent by a Hook.
+type HTTPHook struct {
+	URL string `json:"url" yaml:"url"`
+	// Method defaults to POST.
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// Headers values expand environment variables, e.g. "Bearer ${TOKEN}".
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Body defaults to a JSON object with the mode, phase and resource.
+	Body string `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// ExecHook runs a command in a pod selected by name or label selector.
+type ExecHook struct {
+	Pod      string `json:"pod,omitempty" yaml:"pod,omitempty"`
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// Namespace defaults to the namespace of the resource.
+	Namespace string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Container string   `json:"container,omitempty" yaml:"container,omitempty"`
+	Command   []string `json:"command" yaml:"command"`
+}
+
+// PodExecFunc runs a command in a container of a pod and returns its output.
+type PodExecFunc func(ctx context.Context, namespace, pod, container string, command []string) ([]byte, error)
+
+// HookPhase tells whether a hook runs before or after scaling.
+type HookPhase string
+
+const (
+	// HookPre runs before the resource is scaled.
+	HookPre HookPhase = "pre"
+	// HookPost runs once the resource has reached its target replicas.
+	HookPost HookPhase = "post"
+)
+
+func (h *Hook) validate() error {
+	set := 0
+	if len(h.Command) > 0 {
+		set++
+	}
+	if h.HTTP != nil {
+		set++
+		if h.HTTP.URL == "" {
+			return fmt.Errorf("http hooks require a url")
+		}
+	}
+	if h.Exec != nil {
+		set++
+		if (h.Exec.Pod == "") == (h.Exec.Selector == "") {
+			return fmt.Errorf("exec hooks require exactly one of pod and selector")
+		}
+		if len(h.Exec.Command) == 0 {
+			return fmt.Errorf("exec hooks require a command")
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("hooks require exactly one of command, http and exec")
+	}
+	for _, mode := range h.Modes {
+		if mode != ModeScaleDown && mode != ModeRestore {
+			return fmt.Errorf("unsupported hook mode %q, must be one of: %s, %s", mode, ModeScaleDown, ModeRestore)
+		}
+	}
+	if h.Timeout != "" {
+		if _, err := time.ParseDuration(h.Timeout); err != nil {
+			return fmt.Errorf("invalid hook timeout: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h *Hook) String() string {
+	switch {
+	case h.HTTP != nil:
+		method := h.HTTP.Method
+		if method == "" {
+			method = http.MethodPost
+		}
+		return method + " " + h.HTTP.URL
+	case h.Exec != nil:
+		pod := h.Exec.Pod
+		if pod == "" {
+			pod = "pod with selector " + h.Exec.Selector
+		}
+		return fmt.Sprintf("%q in %s", strings.Join(h.Exec.Command, " "), pod)
+	default:
+		return fmt.Sprintf("%q", strings.Join(h.Command, " "))
+	}
+}
+
+// runHook runs a hook of a target, or of the run for the zero Target. Hooks
+// restricted to the other mode are skipped.
+func (e *execution) runHook(ctx context.Context, t Target, phase HookPhase, h *Hook) error {
+	if h == nil {
+		return nil
+	}
+	if len(h.Modes) > 0 {
+		matches := false
+		for _, mode := range h.Modes {
+			matches = matches || mode == e.mode
+		}
+		if !matches {
+			return nil
+		}
+	}
+
+	timeout := DefaultHookTimeout
+	if h.Timeout != "" {
+		timeout, _ = time.ParseDuration(h.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	e.emit(EventProgress, t, "Running %s hook %s...", phase, h)
+	var err error
+	switch {
+	case h.HTTP != nil:
+		err = e.runHTTPHook(ctx, t, phase, h.HTTP)
+	case h.Exec != nil:
+		err = e.runExecHook(ctx, t, h.Exec)
+	default:
+		err = e.runCommandHook(ctx, t, phase, h.Command)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook %s failed: %w", phase, h, err)
+	}
+	return nil
+}
+
+// hookPayload is the default body of HTTP hooks.
+type hookPayload struct {
+	Mode      Mode      `json:"mode"`
+	Phase     HookPhase `json:"phase"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+}
+
+func (e *execution) hookPayload(t Target, phase HookPhase) hookPayload {
+	p := hookPayload{Mode: e.mode, Phase: phase, Cluster: e.opts.Cluster, Namespace: t.Item.Namespace, Name: t.Item.Name}
+	if t.Kind != "" {
+		p.Kind = t.Label()
+	}
+	return p
+}
+
+func (e *execution) runCommandHook(ctx context.Context, t Target, phase HookPhase, command []string) error {
+	p := e.hookPayload(t, phase)
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"SCALE_DOWN_MODE="+string(p.Mode),
+		"SCALE_DOWN_PHASE="+string(p.Phase),
+		"SCALE_DOWN_CONTEXT="+p.Cluster,
+		"SCALE_DOWN_KIND="+p.Kind,
+		"SCALE_DOWN_NAMESPACE="+p.Namespace,
+		"SCALE_DOWN_NAME="+p.Name,
+	)
+	output, err := cmd.CombinedOutput()
+	return withOutput(err, output)
+}
+
+func (e *execution) runHTTPHook(ctx context.Context, t Target, phase HookPhase, h *HTTPHook) error {
+	method := h.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	body := []byte(h.Body)
+	if h.Body == "" && method != http.MethodGet {
+		var err error
+		if body, err = json.Marshal(e.hookPayload(t, phase)); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range h.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		output, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return withOutput(fmt.Errorf("unexpected status %s", resp.Status), output)
+	}
+	return nil
+}
+
+func (e *execution) runExecHook(ctx context.Context, t Target, h *ExecHook) error {
+	if e.opts.PodExec == nil {
+		return fmt.Errorf("exec hooks require Options.PodExec")
+	}
+	namespace := h.Namespace
+	if namespace == "" {
+		namespace = t.Item.Namespace
+	}
+	if namespace == "" {
+		return fmt.Errorf("exec hooks of a run require a namespace")
+	}
+	pod := h.Pod
+	if pod == "" {
+		var err error
+		if pod, err = e.runningPod(ctx, namespace, h.Selector); err != nil {
+			return err
+		}
+	}
+	output, err := e.opts.PodExec(ctx, namespace, pod, h.Container, h.Command)
+	return withOutput(err, output)
+}
+
+// runningPod returns the first running pod matching a selector, by name.
+func (e *execution) runningPod(ctx context.Context, namespace, selector string) (string, error) {
+	pods, err := e.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			names = append(names, pod.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no running pod in namespace %s matches selector %q", namespace, selector)
+	}
+	sort.Strings(names)
+	return names[0], nil
+}
+
+// withOutput appends the output of a failed hook to its error.
+func withOutput(err error, output []byte) error {
+	if err == nil {
+		return nil
+	}
+	if out := strings.TrimSpace(string(output)); out != "" {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return err
+}
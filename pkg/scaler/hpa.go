@@ -0,0 +1,142 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// HPAConflictError is returned by Run when scaled resources are managed by
+// HorizontalPodAutoscalers and Options.PauseHPA is not set.
+type HPAConflictError struct {
+	Conflicts []string
+}
+
+func (e *HPAConflictError) Error() string {
+	return fmt.Sprintf("found %d resources managed by HorizontalPodAutoscalers:\n- %s", len(e.Conflicts), strings.Join(e.Conflicts, "\n- "))
+}
+
+type hpaIndex map[string][]autoscalingv2.HorizontalPodAutoscaler
+
+func hpaKey(t Target) string {
+	return t.Kind.Label() + "/" + t.Item.Namespace + "/" + t.Item.Name
+}
+
+func (idx hpaIndex) forTarget(t Target) []autoscalingv2.HorizontalPodAutoscaler {
+	return idx[hpaKey(t)]
+}
+
+// indexHPAs lists the HorizontalPodAutoscalers once per namespace and indexes
+// them by the workload they target.
+func indexHPAs(ctx context.Context, client kubernetes.Interface, targets []Target) (hpaIndex, error) {
+	idx := hpaIndex{}
+	listed := map[string]bool{}
+	for _, t := range targets {
+		if t.Kind == KindCronJob || t.Kind == KindJob || t.Kind == KindCustom || t.Kind == KindKnativeService {
+			continue
+		}
+		namespace := t.Item.Namespace
+		if listed[namespace] {
+			continue
+		}
+		listed[namespace] = true
+
+		list, err := client.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list horizontal pod autoscalers in namespace %s: %w", namespace, err)
+		}
+		for _, hpa := range list.Items {
+			ref := hpa.Spec.ScaleTargetRef
+			key := ref.Kind + "/" + hpa.Namespace + "/" + ref.Name
+			idx[key] = append(idx[key], hpa)
+		}
+	}
+	return idx, nil
+}
+
+func checkHPAConflicts(targets []Target, hpas hpaIndex) error {
+	var conflicts []string
+	for _, t := range targets {
+		for _, hpa := range hpas.forTarget(t) {
+			conflicts = append(conflicts, fmt.Sprintf("%s %s/%s is managed by HorizontalPodAutoscaler %s", t.Label(), t.Item.Namespace, t.Item.Name, hpa.Name))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return &HPAConflictError{Conflicts: conflicts}
+}
+
+// pauseHPAs saves the HPAs targeting a workload in an annotation on the
+// workload and deletes them so they cannot scale it back up.
+func (e *execution) pauseHPAs(ctx context.Context, t Target, w *workload, hpas []autoscalingv2.HorizontalPodAutoscaler) error {
+	if len(hpas) == 0 {
+		return nil
+	}
+
+	saved := make([]autoscalingv2.HorizontalPodAutoscaler, 0, len(hpas))
+	for _, hpa := range hpas {
+		saved = append(saved, autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        hpa.Name,
+				Labels:      hpa.Labels,
+				Annotations: hpa.Annotations,
+			},
+			Spec: hpa.Spec,
+		})
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	patch, err := annotationPatch(PausedHPAsAnnotation, string(data))
+	if err != nil {
+		return err
+	}
+	if err := w.patch(ctx, patch); err != nil {
+		return fmt.Errorf("failed to save horizontal pod autoscalers: %w", err)
+	}
+
+	for _, hpa := range hpas {
+		err := e.client.AutoscalingV2().HorizontalPodAutoscalers(t.Item.Namespace).Delete(ctx, hpa.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to pause horizontal pod autoscaler %s: %w", hpa.Name, err)
+		}
+		e.emit(EventProgress, t, "Paused HorizontalPodAutoscaler %s.", hpa.Name)
+	}
+	return nil
+}
+
+// resumeHPAs recreates the HPAs saved by pauseHPAs and clears the annotation.
+func (e *execution) resumeHPAs(ctx context.Context, t Target, w *workload) error {
+	value, ok := w.annotations[PausedHPAsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var saved []autoscalingv2.HorizontalPodAutoscaler
+	if err := json.Unmarshal([]byte(value), &saved); err != nil {
+		return fmt.Errorf("invalid %s annotation: %w", PausedHPAsAnnotation, err)
+	}
+
+	for _, hpa := range saved {
+		hpa.Namespace = t.Item.Namespace
+		_, err := e.client.AutoscalingV2().HorizontalPodAutoscalers(t.Item.Namespace).Create(ctx, &hpa, metav1.CreateOptions{FieldManager: FieldManager})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to restore horizontal pod autoscaler %s: %w", hpa.Name, err)
+		}
+		e.emit(EventProgress, t, "Restored HorizontalPodAutoscaler %s.", hpa.Name)
+	}
+
+	patch, err := annotationPatch(PausedHPAsAnnotation, nil)
+	if err != nil {
+		return err
+	}
+	return w.patch(ctx, patch)
+}
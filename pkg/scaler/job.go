@@ -0,0 +1,193 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// jobFinished reports whether a Job has completed or failed.
+func jobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func jobSuspended(job *batchv1.Job) bool {
+	return job.Spec.Suspend != nil && *job.Spec.Suspend
+}
+
+// jobPolicy returns the policy of a Job item, JobPolicyWait by default.
+func jobPolicy(item ResourceItem) JobPolicy {
+	if item.JobPolicy == "" {
+		return JobPolicyWait
+	}
+	return item.JobPolicy
+}
+
+// handleJob stops a Job on scale down with the policy of its item: waiting
+// for it to finish, suspending it, or deleting it. The replicas of a Job are
+// its active pods. Restore only resumes the Jobs suspended by this tool,
+// marked like CronJobs.
+func (e *execution) handleJob(ctx context.Context, t Target, res *Result) error {
+	r := t.Item
+	jobs := e.client.BatchV1().Jobs(r.Namespace)
+
+	job, err := jobs.Get(ctx, r.Name, metav1.GetOptions{})
+	if e.mode == ModeRestore && apierrors.IsNotFound(err) {
+		res.Status = StatusUnchanged
+		e.emit(EventCompleted, t, "Not found, nothing to restore.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Status = StatusUnchanged
+	res.PreviousReplicas = job.Status.Active
+	res.TargetReplicas = job.Status.Active
+	patchJob := func(ctx context.Context, data []byte) error {
+		_, err := jobs.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	}
+
+	if e.mode == ModeRestore {
+		if _, marked := job.Annotations[SuspendedAnnotation]; !marked {
+			e.emit(EventCompleted, t, "Not suspended by parallel-scale-down, nothing to restore.")
+			return nil
+		}
+		if err := e.suspendJob(ctx, patchJob, false); err != nil {
+			return err
+		}
+		res.Status = StatusScaled
+		res.changed = true
+		res.TargetReplicas = specReplicas(job.Spec.Parallelism)
+		if _, err := e.applyMarks(ctx, job.Annotations, patchJob); err != nil {
+			return err
+		}
+		e.emit(EventCompleted, t, "Resumed.")
+		return nil
+	}
+
+	res.TargetReplicas = 0
+	if jobFinished(job) {
+		e.emit(EventCompleted, t, "Already finished.")
+		return nil
+	}
+
+	switch jobPolicy(r) {
+	case JobPolicySuspend:
+		if jobSuspended(job) {
+			e.emit(EventCompleted, t, "Already suspended.")
+			return nil
+		}
+		if err := e.suspendJob(ctx, patchJob, true); err != nil {
+			return err
+		}
+		res.Status = StatusScaled
+		res.changed = true
+		if _, err := e.applyMarks(ctx, job.Annotations, patchJob); err != nil {
+			return err
+		}
+		e.emitReplicas(t, job.Status.Active, 0, "Suspended. Watching for its pods to terminate...")
+		return e.waitForJob(ctx, t, func(job *batchv1.Job) bool { return job.Status.Active == 0 })
+	case JobPolicyDelete:
+		propagation := metav1.DeletePropagationForeground
+		if err := withRetry(ctx, e.opts.Retry, func() error {
+			err := jobs.Delete(ctx, r.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+		res.Status = StatusScaled
+		res.changed = true
+		e.emitReplicas(t, job.Status.Active, 0, "Deleted. Watching for it and its pods to be removed...")
+		return e.waitForJob(ctx, t, nil)
+	}
+	e.emitReplicas(t, job.Status.Active, 0, "Waiting for the Job to finish...")
+	return e.waitForJob(ctx, t, jobFinished)
+}
+
+// suspendJob suspends or resumes a Job, marking it so that restore only
+// resumes the Jobs suspended by this tool.
+func (e *execution) suspendJob(ctx context.Context, patch func(ctx context.Context, data []byte) error, suspend bool) error {
+	var annotation interface{}
+	if suspend {
+		annotation = "true"
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{SuspendedAnnotation: annotation},
+		},
+		"spec": map[string]interface{}{"suspend": suspend},
+	})
+	if err != nil {
+		return err
+	}
+	return withRetry(ctx, e.opts.Retry, func() error { return patch(ctx, data) })
+}
+
+// waitForJob polls a Job until done reports true, or until it is gone when
+// done is nil.
+func (e *execution) waitForJob(ctx context.Context, t Target, done func(*batchv1.Job) bool) error {
+	poll := e.poller()
+	last := int32(-1)
+	for {
+		job, err := e.client.BatchV1().Jobs(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && done == nil {
+			e.emit(EventCompleted, t, "Removed.")
+			return nil
+		}
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if done != nil && done(job) {
+			e.emit(EventCompleted, t, "Done.")
+			return nil
+		}
+		progressed := job.Status.Active != last
+		if progressed {
+			e.emitReplicas(t, job.Status.Active, 0, "Waiting for the Job... Active pods: %d", job.Status.Active)
+			last = job.Status.Active
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
+
+// planJob plans a Job from its active pods: a scale down stops them, and
+// restore resumes the Jobs suspended by this tool.
+func (s *Scaler) planJob(ctx context.Context, mode Mode, t Target) PlanEntry {
+	entry := PlanEntry{Target: t}
+	job, err := s.client.BatchV1().Jobs(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+	if mode == ModeRestore && apierrors.IsNotFound(err) {
+		return entry
+	}
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	entry.CurrentReplicas = job.Status.Active
+	entry.TargetReplicas = entry.CurrentReplicas
+	if _, marked := job.Annotations[SuspendedAnnotation]; mode == ModeScaleDown && !jobFinished(job) {
+		entry.TargetReplicas = 0
+	} else if mode == ModeRestore && marked {
+		entry.TargetReplicas = specReplicas(job.Spec.Parallelism)
+	}
+	return entry
+}
@@ -0,0 +1,330 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// knativeServiceResource is the resource of Knative Services.
+var knativeServiceResource = schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"}
+
+const (
+	// KnativeScaleAnnotation records the autoscaling bounds and visibility
+	// of a Knative Service before its scale down, so that restore puts them
+	// back.
+	KnativeScaleAnnotation = "parallel-scale-down/knative-scale"
+
+	knativeMinScaleAnnotation = "autoscaling.knative.dev/min-scale"
+	knativeMaxScaleAnnotation = "autoscaling.knative.dev/max-scale"
+	knativeVisibilityLabel    = "networking.knative.dev/visibility"
+	knativeServiceLabel       = "serving.knative.dev/service"
+)
+
+// knativeScale is the value of KnativeScaleAnnotation. A nil field was not
+// set before the scale down.
+type knativeScale struct {
+	MinScale   *string `json:"minScale,omitempty"`
+	MaxScale   *string `json:"maxScale,omitempty"`
+	Visibility *string `json:"visibility,omitempty"`
+}
+
+func (s *Scaler) getKnativeService(ctx context.Context, t Target) (*unstructured.Unstructured, error) {
+	resource, err := s.custom.kindResource(KindKnativeService, t.Item.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Get(ctx, t.Item.Name, metav1.GetOptions{})
+}
+
+// knativePods counts the pods of every revision of a Knative Service that
+// are not terminating.
+func (s *Scaler) knativePods(ctx context.Context, t Target) (int32, error) {
+	list, err := s.client.CoreV1().Pods(t.Item.Namespace).List(ctx, metav1.ListOptions{LabelSelector: knativeServiceLabel + "=" + t.Item.Name})
+	if err != nil {
+		return 0, fmt.Errorf("listing pods: %w", err)
+	}
+	var count int32
+	for _, pod := range list.Items {
+		if pod.DeletionTimestamp == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// knativeScaleDownPatch builds the merge patch bounding the autoscaling of a
+// Knative Service to target pods. The Knative autoscaler owns the replicas,
+// so a scale down through its Deployment is reverted: instead, max-scale is
+// lowered to the target, and a target of zero drops min-scale to zero and
+// makes the Service cluster-local, so that external traffic no longer wakes
+// it up. A target above the current pods raises min-scale to it instead. The
+// previous values are recorded in KnativeScaleAnnotation, unless an earlier
+// run already did.
+func knativeScaleDownPatch(svc *unstructured.Unstructured, current, target int32) ([]byte, error) {
+	templateAnnotations, _, _ := unstructured.NestedStringMap(svc.Object, "spec", "template", "metadata", "annotations")
+	lookup := func(values map[string]string, key string) *string {
+		if value, ok := values[key]; ok {
+			return &value
+		}
+		return nil
+	}
+	original := knativeScale{
+		MinScale:   lookup(templateAnnotations, knativeMinScaleAnnotation),
+		MaxScale:   lookup(templateAnnotations, knativeMaxScaleAnnotation),
+		Visibility: lookup(svc.GetLabels(), knativeVisibilityLabel),
+	}
+	annotations := map[string]interface{}{}
+	if value, ok := svc.GetAnnotations()[KnativeScaleAnnotation]; ok {
+		// Keep the bounds recorded by the run that first scaled it down.
+		if err := json.Unmarshal([]byte(value), &original); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", KnativeScaleAnnotation, err)
+		}
+	} else {
+		value, err := json.Marshal(original)
+		if err != nil {
+			return nil, err
+		}
+		annotations[KnativeScaleAnnotation] = string(value)
+	}
+	if _, ok := svc.GetAnnotations()[OriginalReplicasAnnotation]; !ok {
+		annotations[OriginalReplicasAnnotation] = strconv.Itoa(int(current))
+	}
+
+	metadata := map[string]interface{}{"annotations": annotations}
+	bounds := map[string]interface{}{}
+	switch {
+	case target > current:
+		bounds[knativeMinScaleAnnotation] = strconv.Itoa(int(target))
+		if maxScale, err := strconv.Atoi(templateAnnotations[knativeMaxScaleAnnotation]); err == nil && maxScale > 0 && int32(maxScale) < target {
+			bounds[knativeMaxScaleAnnotation] = strconv.Itoa(int(target))
+		}
+	case target == 0:
+		bounds[knativeMinScaleAnnotation] = "0"
+		metadata["labels"] = map[string]interface{}{knativeVisibilityLabel: "cluster-local"}
+	default:
+		bounds[knativeMaxScaleAnnotation] = strconv.Itoa(int(target))
+		if minScale, err := strconv.Atoi(templateAnnotations[knativeMinScaleAnnotation]); err == nil && int32(minScale) > target {
+			bounds[knativeMinScaleAnnotation] = strconv.Itoa(int(target))
+		}
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": metadata,
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"annotations": bounds},
+			},
+		},
+	})
+}
+
+// knativeRestorePatch builds the merge patch putting back the autoscaling
+// bounds and visibility recorded in KnativeScaleAnnotation.
+func knativeRestorePatch(record string) ([]byte, error) {
+	var original knativeScale
+	if err := json.Unmarshal([]byte(record), &original); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", KnativeScaleAnnotation, err)
+	}
+	value := func(v *string) interface{} {
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{KnativeScaleAnnotation: nil, OriginalReplicasAnnotation: nil},
+			"labels":      map[string]interface{}{knativeVisibilityLabel: value(original.Visibility)},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						knativeMinScaleAnnotation: value(original.MinScale),
+						knativeMaxScaleAnnotation: value(original.MaxScale),
+					},
+				},
+			},
+		},
+	})
+}
+
+// handleKnativeService bounds the autoscaling of a Knative Service on scale
+// down, see knativeScaleDownPatch, and puts its bounds back on restore. The
+// replicas of a Knative Service are the running pods of its revisions.
+func (e *execution) handleKnativeService(ctx context.Context, t Target, res *Result) error {
+	r := t.Item
+	resource, err := e.custom.kindResource(KindKnativeService, r.Namespace)
+	if err != nil {
+		return err
+	}
+	svc, err := resource.Get(ctx, r.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := e.knativePods(ctx, t)
+	if err != nil {
+		return err
+	}
+	annotations := svc.GetAnnotations()
+	if !res.changed {
+		res.PreviousReplicas = pods
+	}
+	res.TargetReplicas = pods
+	res.Status = StatusUnchanged
+	patchService := func(ctx context.Context, data []byte) error {
+		_, err := resource.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	}
+
+	var patch []byte
+	if e.mode == ModeScaleDown {
+		target, err := e.targetReplicas(t, e.mode, pods, annotations)
+		if err != nil {
+			return err
+		}
+		res.TargetReplicas = target
+		e.state.recordIfMissing(t, originalReplicas(annotations, pods))
+		if err := e.pauseGitOps(ctx, t, svc.GetLabels(), annotations); err != nil {
+			return err
+		}
+		if patch, err = knativeScaleDownPatch(svc, pods, target); err != nil {
+			return err
+		}
+	} else {
+		record, ok := annotations[KnativeScaleAnnotation]
+		if !ok {
+			e.emit(EventCompleted, t, "Not scaled down by parallel-scale-down, leaving its autoscaling as is.")
+			return nil
+		}
+		if target, err := e.targetReplicas(t, e.mode, pods, annotations); err == nil {
+			res.TargetReplicas = target
+		}
+		if patch, err = knativeRestorePatch(record); err != nil {
+			return err
+		}
+	}
+
+	sent := time.Now()
+	if err := withRetry(ctx, e.opts.Retry, func() error { return patchService(ctx, patch) }); err != nil {
+		return err
+	}
+	res.Status = StatusScaled
+	res.changed = true
+	if _, err := e.applyMarks(ctx, annotations, patchService); err != nil {
+		return err
+	}
+
+	if e.mode == ModeScaleDown {
+		e.emitReplicas(t, pods, res.TargetReplicas, "Autoscaling bounded. Watching for %d pods...", res.TargetReplicas)
+		err = e.waitForKnativePods(ctx, t, pods, res.TargetReplicas)
+		res.ScaleDuration = time.Since(sent)
+		return err
+	}
+	e.emit(EventProgress, t, "Autoscaling restored. Watching for the Service to be ready...")
+	err = e.waitForKnativeReady(ctx, t)
+	res.ScaleDuration = time.Since(sent)
+	if err != nil {
+		return err
+	}
+	return e.resumeGitOps(ctx, t, svc.GetLabels(), annotations)
+}
+
+// waitForKnativePods polls the pods of a Knative Service until at most
+// target are left, or at least target run when scaling up from current.
+// Knative only removes pods once its autoscaler's stable window has passed,
+// which may take a minute or more.
+func (e *execution) waitForKnativePods(ctx context.Context, t Target, current, target int32) error {
+	poll := e.poller()
+	last := int32(-1)
+	for {
+		pods, err := e.knativePods(ctx, t)
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		reached := pods <= target
+		if target > current {
+			reached = pods >= target
+		}
+		if reached {
+			e.emit(EventCompleted, t, "Scale complete.")
+			return nil
+		}
+		progressed := pods != last
+		if progressed {
+			e.emitReplicas(t, pods, target, "Waiting for the Knative autoscaler... Current pods: %d", pods)
+			last = pods
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForKnativeReady polls a Knative Service until it has reconciled its
+// latest spec and reports the Ready condition.
+func (e *execution) waitForKnativeReady(ctx context.Context, t Target) error {
+	poll := e.poller()
+	for {
+		svc, err := e.getKnativeService(ctx, t)
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if knativeReady(svc) {
+			e.emit(EventCompleted, t, "Ready.")
+			return nil
+		}
+
+		if err := poll.wait(ctx, false); err != nil {
+			return err
+		}
+	}
+}
+
+func knativeReady(svc *unstructured.Unstructured) bool {
+	observed, _, _ := unstructured.NestedInt64(svc.Object, "status", "observedGeneration")
+	if observed < svc.GetGeneration() {
+		return false
+	}
+	conditions, _, _ := unstructured.NestedSlice(svc.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Ready" {
+			return condition["status"] == "True"
+		}
+	}
+	return false
+}
+
+// planKnativeService plans a Knative Service from its running pods. Restore
+// only plans a change for Services scaled down by this tool.
+func (s *Scaler) planKnativeService(ctx context.Context, mode Mode, t Target) PlanEntry {
+	entry := PlanEntry{Target: t}
+	svc, err := s.getKnativeService(ctx, t)
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	if entry.CurrentReplicas, entry.Err = s.knativePods(ctx, t); entry.Err != nil {
+		return entry
+	}
+	entry.TargetReplicas = entry.CurrentReplicas
+	if _, marked := svc.GetAnnotations()[KnativeScaleAnnotation]; mode == ModeScaleDown || marked {
+		entry.TargetReplicas, entry.Err = s.targetReplicas(t, mode, entry.CurrentReplicas, svc.GetAnnotations())
+	}
+	return entry
+}
@@ -0,0 +1,208 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// LockName is the name of the Lease guarding a cluster against
+	// concurrent runs.
+	LockName = "parallel-scale-down"
+	// DefaultLockNamespace is where the Lease is created unless
+	// LockOptions.Namespace is set.
+	DefaultLockNamespace = "default"
+	// LockModeAnnotation records on the Lease whether its holder is scaling
+	// down or restoring.
+	LockModeAnnotation = "parallel-scale-down/mode"
+
+	// lockDuration is how long a lock that is no longer renewed, e.g. after
+	// a crash, keeps other runs out.
+	lockDuration = 60 * time.Second
+)
+
+// LockOptions configures AcquireLock.
+type LockOptions struct {
+	Namespace string
+	// Holder identifies the run in the error returned to other runs, e.g.
+	// "alice@laptop (pid 4242)". It must be unique per run.
+	Holder string
+	Mode   Mode
+	// Force takes the lock even if another run holds it.
+	Force bool
+	// OnLost is called if another run takes the lock with Force while it
+	// is held.
+	OnLost func(holder string)
+}
+
+// LockHeldError is returned by AcquireLock when another run holds the lock.
+type LockHeldError struct {
+	Holder string
+	Mode   Mode
+	Since  time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	msg := fmt.Sprintf("another run holds the lock: %s", e.Holder)
+	if e.Mode != "" {
+		msg += fmt.Sprintf(" (%s)", e.Mode)
+	}
+	if !e.Since.IsZero() {
+		msg += fmt.Sprintf(" since %s", e.Since.Local().Format(time.RFC1123))
+	}
+	return msg
+}
+
+// Lock is a cluster-wide lock held through a coordination.k8s.io Lease. It
+// is renewed in the background until Release is called.
+type Lock struct {
+	client kubernetes.Interface
+	opts   LockOptions
+	// StolenFrom is the previous holder when the lock was taken with Force.
+	StolenFrom string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// AcquireLock takes the cluster lock, or returns a *LockHeldError naming the
+// current holder. A lock that has not been renewed for a minute is taken over
+// without Force.
+func AcquireLock(ctx context.Context, client kubernetes.Interface, opts LockOptions) (*Lock, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultLockNamespace
+	}
+	l := &Lock{client: client, opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	leases := client.CoordinationV1().Leases(opts.Namespace)
+
+	for {
+		lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = leases.Create(ctx, l.lease(&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: LockName}}), metav1.CreateOptions{FieldManager: FieldManager})
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if holder := activeHolder(lease); holder != "" {
+			if !opts.Force {
+				held := &LockHeldError{Holder: holder, Mode: Mode(lease.Annotations[LockModeAnnotation])}
+				if lease.Spec.AcquireTime != nil {
+					held.Since = lease.Spec.AcquireTime.Time
+				}
+				return nil, held
+			}
+			l.StolenFrom = holder
+		}
+		_, err = leases.Update(ctx, l.lease(lease), metav1.UpdateOptions{FieldManager: FieldManager})
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	go l.renew()
+	return l, nil
+}
+
+// activeHolder returns the holder of a Lease that has not expired.
+func activeHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil {
+		return ""
+	}
+	duration := lockDuration
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	if time.Since(lease.Spec.RenewTime.Time) > duration {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// lease sets this run as the holder of a Lease.
+func (l *Lock) lease(lease *coordinationv1.Lease) *coordinationv1.Lease {
+	lease = lease.DeepCopy()
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(lockDuration.Seconds())
+	lease.Spec.HolderIdentity = &l.opts.Holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[LockModeAnnotation] = string(l.opts.Mode)
+	return lease
+}
+
+// renew keeps the Lease alive until Release, and stops once another run
+// has taken it.
+func (l *Lock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockDuration / 3)
+	defer ticker.Stop()
+	leases := l.client.CoordinationV1().Leases(l.opts.Namespace)
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), lockDuration/3)
+		lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+		if err == nil {
+			if holder := lease.Spec.HolderIdentity; holder == nil || *holder != l.opts.Holder {
+				cancel()
+				if l.opts.OnLost != nil && holder != nil {
+					l.opts.OnLost(*holder)
+				}
+				return
+			}
+			now := metav1.NewMicroTime(time.Now())
+			lease.Spec.RenewTime = &now
+			// A failed renewal is retried on the next tick.
+			_, _ = leases.Update(ctx, lease, metav1.UpdateOptions{FieldManager: FieldManager})
+		}
+		cancel()
+	}
+}
+
+// Release stops renewing the lock and deletes the Lease, unless another run
+// has taken it in the meantime.
+func (l *Lock) Release(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+	leases := l.client.CoordinationV1().Leases(l.opts.Namespace)
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if holder := lease.Spec.HolderIdentity; holder == nil || *holder != l.opts.Holder {
+		return nil
+	}
+	err = leases.Delete(ctx, LockName, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion}})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// OriginalSelectorAnnotation saves the selector of a Service pointed at a
+// maintenance page, see MaintenancePage.
+const OriginalSelectorAnnotation = "parallel-scale-down/original-selector"
+
+// MaintenancePage points the Service of a workload at the pods of a static
+// maintenance page while the workload is scaled down to zero, so that its
+// clients get a maintenance page rather than connection errors. Restore
+// gives the Service its original selector back once the workload is at its
+// replicas.
+type MaintenancePage struct {
+	// Service is the Service of the workload, in its namespace.
+	Service string `json:"service" yaml:"service"`
+	// Selector selects the pods of the maintenance page, e.g.
+	// {app: maintenance-page}. It replaces the selector of the Service.
+	Selector map[string]string `json:"selector" yaml:"selector"`
+}
+
+func (m *MaintenancePage) validate() []string {
+	var problems []string
+	if m.Service == "" {
+		problems = append(problems, "maintenancePage requires a service")
+	} else if errs := validation.NameIsDNS1035Label(m.Service, false); len(errs) > 0 {
+		problems = append(problems, fmt.Sprintf("invalid maintenancePage service %q", m.Service))
+	}
+	if len(m.Selector) == 0 {
+		problems = append(problems, "maintenancePage requires a selector")
+	}
+	for key, value := range m.Selector {
+		if errs := append(utilvalidation.IsQualifiedName(key), utilvalidation.IsValidLabelValue(value)...); len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("invalid maintenancePage selector %s=%s", key, value))
+		}
+	}
+	return problems
+}
+
+// showMaintenancePage points the Service of the MaintenancePage of a target
+// scaled down to zero at the maintenance page, after saving its selector in
+// OriginalSelectorAnnotation. A Service that already has the annotation,
+// e.g. in a resumed run, is left as is.
+func (e *execution) showMaintenancePage(ctx context.Context, t Target, targetReplicas int32) error {
+	page := t.Item.MaintenancePage
+	if page == nil || targetReplicas > 0 {
+		return nil
+	}
+	svc, err := e.getService(ctx, t.Item.Namespace, page.Service)
+	if err != nil {
+		return err
+	}
+	if _, ok := svc.Annotations[OriginalSelectorAnnotation]; ok {
+		e.emit(EventProgress, t, "Service %s already points at the maintenance page.", page.Service)
+		return nil
+	}
+	saved, err := json.Marshal(svc.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	if err := e.patchService(ctx, t.Item.Namespace, page.Service, map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{OriginalSelectorAnnotation: string(saved)}},
+		"spec":     map[string]interface{}{"selector": selectorPatch(svc.Spec.Selector, page.Selector)},
+	}); err != nil {
+		return fmt.Errorf("failed to point service %s at the maintenance page: %w", page.Service, err)
+	}
+	e.emit(EventProgress, t, "Service %s points at the maintenance page.", page.Service)
+	return nil
+}
+
+// hideMaintenancePage undoes showMaintenancePage. A Service without
+// OriginalSelectorAnnotation was not changed by this tool and is left alone.
+func (e *execution) hideMaintenancePage(ctx context.Context, t Target) error {
+	page := t.Item.MaintenancePage
+	if page == nil {
+		return nil
+	}
+	svc, err := e.getService(ctx, t.Item.Namespace, page.Service)
+	if err != nil {
+		return err
+	}
+	value, ok := svc.Annotations[OriginalSelectorAnnotation]
+	if !ok {
+		return nil
+	}
+	var original map[string]string
+	if err := json.Unmarshal([]byte(value), &original); err != nil {
+		return fmt.Errorf("invalid %s annotation on service %s: %w", OriginalSelectorAnnotation, page.Service, err)
+	}
+	if err := e.patchService(ctx, t.Item.Namespace, page.Service, map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{OriginalSelectorAnnotation: nil}},
+		"spec":     map[string]interface{}{"selector": selectorPatch(svc.Spec.Selector, original)},
+	}); err != nil {
+		return fmt.Errorf("failed to restore the selector of service %s: %w", page.Service, err)
+	}
+	e.emit(EventProgress, t, "Service %s points at the workload again.", page.Service)
+	return nil
+}
+
+// selectorPatch is the merge patch replacing the selector current with
+// selector: the keys of current that selector lacks are removed.
+func selectorPatch(current, selector map[string]string) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key := range current {
+		patch[key] = nil
+	}
+	for key, value := range selector {
+		patch[key] = value
+	}
+	return patch
+}
+
+func (e *execution) getService(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+	var svc *corev1.Service
+	if err := withRetry(ctx, e.opts.Retry, func() error {
+		var err error
+		svc, err = e.client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to get service %s: %w", name, err)
+	}
+	return svc, nil
+}
+
+func (e *execution) patchService(ctx context.Context, namespace, name string, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return withRetry(ctx, e.opts.Retry, func() error {
+		_, err := e.client.CoreV1().Services(namespace).Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	})
+}
@@ -0,0 +1,133 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+const (
+	// MarksAnnotation lists the labels and annotations set by Options.Marks
+	// on a resource, so that restore removes them without the options of the
+	// scale down.
+	MarksAnnotation = "parallel-scale-down/marks"
+	// MaintenanceSinceAnnotation, RunAnnotation, TicketAnnotation and
+	// ReasonAnnotation are the annotations added to the marks by the CLI:
+	// when the scale down started, its run ID, and the ticket and reason of
+	// the maintenance.
+	MaintenanceSinceAnnotation = "parallel-scale-down/maintenance-since"
+	RunAnnotation              = "parallel-scale-down/run"
+	TicketAnnotation           = "parallel-scale-down/ticket"
+	ReasonAnnotation           = "parallel-scale-down/reason"
+)
+
+// FieldManager is the field manager of every change made to the cluster, so
+// that managedFields and audit logs attribute them to this tool.
+const FieldManager = "parallel-scale-down"
+
+// maxFieldManagerLength is the longest field manager the API server accepts.
+const maxFieldManagerLength = 128
+
+// replicasManager is the field manager applying the replicas of the item.
+func (r ResourceItem) replicasManager() string {
+	if r.FieldManager == "" {
+		return FieldManager
+	}
+	return r.FieldManager
+}
+
+// Marks are labels and annotations set on every target while it is scaled
+// down, so that other tools and dashboards can see the maintenance, e.g.
+// maintenance.example.com/active=true.
+type Marks struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// markedKeys is the value of MarksAnnotation.
+type markedKeys struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// marksPatch builds a metadata-only merge patch that sets the marks on scale
+// down, and removes the marks recorded in annotations on restore. It
+// returns nil when there is nothing to change.
+func marksPatch(mode Mode, marks Marks, annotations map[string]string) ([]byte, error) {
+	var recorded markedKeys
+	if value, ok := annotations[MarksAnnotation]; ok {
+		// A record that cannot be read is replaced, or only removed itself.
+		_ = json.Unmarshal([]byte(value), &recorded)
+	}
+
+	labelPatch := map[string]interface{}{}
+	annotationPatch := map[string]interface{}{}
+	if mode == ModeRestore {
+		if _, ok := annotations[MarksAnnotation]; !ok {
+			return nil, nil
+		}
+		for _, key := range recorded.Labels {
+			labelPatch[key] = nil
+		}
+		for _, key := range recorded.Annotations {
+			annotationPatch[key] = nil
+		}
+		annotationPatch[MarksAnnotation] = nil
+	} else {
+		if len(marks.Labels) == 0 && len(marks.Annotations) == 0 {
+			return nil, nil
+		}
+		for key, value := range marks.Labels {
+			labelPatch[key] = value
+		}
+		for key, value := range marks.Annotations {
+			annotationPatch[key] = value
+		}
+		// Keys of an earlier run, e.g. one that was resumed, stay recorded.
+		keys := markedKeys{
+			Labels:      mergeKeys(recorded.Labels, marks.Labels),
+			Annotations: mergeKeys(recorded.Annotations, marks.Annotations),
+		}
+		value, err := json.Marshal(keys)
+		if err != nil {
+			return nil, err
+		}
+		annotationPatch[MarksAnnotation] = string(value)
+	}
+
+	metadata := map[string]interface{}{"annotations": annotationPatch}
+	if len(labelPatch) > 0 {
+		metadata["labels"] = labelPatch
+	}
+	return json.Marshal(map[string]interface{}{"metadata": metadata})
+}
+
+// mergeKeys returns the sorted union of keys and the keys of values.
+func mergeKeys(keys []string, values map[string]string) []string {
+	set := map[string]bool{}
+	for _, key := range keys {
+		set[key] = true
+	}
+	for key := range values {
+		set[key] = true
+	}
+	merged := make([]string, 0, len(set))
+	for key := range set {
+		merged = append(merged, key)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// applyMarks sets or removes the marks of a target with patch, see
+// marksPatch, and reports whether it changed anything.
+func (e *execution) applyMarks(ctx context.Context, annotations map[string]string, patch func(ctx context.Context, data []byte) error) (bool, error) {
+	data, err := marksPatch(e.mode, e.opts.Marks, annotations)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := withRetry(ctx, e.opts.Retry, func() error { return patch(ctx, data) }); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,112 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// NodeWorkloads returns a config naming the Deployments and StatefulSets
+// that have pods scheduled on the given nodes, for a node maintenance. Pods
+// of other controllers, such as DaemonSets and Jobs, and pods without a
+// controller are ignored.
+func (s *Scaler) NodeWorkloads(ctx context.Context, nodes []string) (*Config, error) {
+	cfg := &Config{}
+	seen := map[string]bool{}
+	// deploymentOf caches the Deployment owning each ReplicaSet, empty for
+	// ReplicaSets without one.
+	deploymentOf := map[string]string{}
+	for _, node := range nodes {
+		if _, err := s.client.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", node, err)
+		}
+		pods, err := s.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods on node %s: %w", node, err)
+		}
+
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			owner := metav1.GetControllerOf(&pod)
+			if owner == nil {
+				continue
+			}
+			kind, name := owner.Kind, owner.Name
+			if kind == "ReplicaSet" {
+				key := pod.Namespace + "/" + owner.Name
+				deployment, ok := deploymentOf[key]
+				if !ok {
+					rs, err := s.client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+					if err != nil && !apierrors.IsNotFound(err) {
+						return nil, fmt.Errorf("failed to get replicaset %s: %w", key, err)
+					}
+					if err == nil {
+						if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == "Deployment" {
+							deployment = rsOwner.Name
+						}
+					}
+					deploymentOf[key] = deployment
+				}
+				kind, name = "Deployment", deployment
+			}
+
+			var section *[]ResourceItem
+			switch {
+			case name == "":
+				continue
+			case kind == "Deployment":
+				section = &cfg.Deployments
+			case kind == "StatefulSet":
+				section = &cfg.StatefulSets
+			default:
+				continue
+			}
+			key := kind + "/" + pod.Namespace + "/" + name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			*section = append(*section, ResourceItem{Name: name, Namespace: pod.Namespace})
+		}
+	}
+
+	for _, items := range [][]ResourceItem{cfg.Deployments, cfg.StatefulSets} {
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Namespace+"/"+items[i].Name < items[j].Namespace+"/"+items[j].Name
+		})
+	}
+	return cfg, nil
+}
+
+// SnapshotNodes is like Snapshot for the Deployments and StatefulSets found
+// by NodeWorkloads.
+func (s *Scaler) SnapshotNodes(ctx context.Context, nodes []string) (*Config, error) {
+	cfg, err := s.NodeWorkloads(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range cfg.Deployments {
+		d, err := s.client.AppsV1().Deployments(item.Namespace).Get(ctx, item.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployment %s/%s: %w", item.Namespace, item.Name, err)
+		}
+		cfg.Deployments[i] = snapshotItem(d.ObjectMeta, d.Spec.Replicas)
+	}
+	for i, item := range cfg.StatefulSets {
+		sts, err := s.client.AppsV1().StatefulSets(item.Namespace).Get(ctx, item.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statefulset %s/%s: %w", item.Namespace, item.Name, err)
+		}
+		cfg.StatefulSets[i] = snapshotItem(sts.ObjectMeta, sts.Spec.Replicas)
+	}
+	return cfg, nil
+}
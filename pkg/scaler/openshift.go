@@ -0,0 +1,34 @@
+package scaler
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// deploymentConfigResource is the resource of OpenShift DeploymentConfigs.
+// It is addressed directly, so that clusters without it only fail when a
+// DeploymentConfig is configured.
+var deploymentConfigResource = schema.GroupVersionResource{Group: "apps.openshift.io", Version: "v1", Resource: "deploymentconfigs"}
+
+// kindResources are the resources of the kinds that are not part of the
+// typed clientset and are accessed through the dynamic client.
+var kindResources = map[Kind]schema.GroupVersionResource{
+	KindDeploymentConfig: deploymentConfigResource,
+	KindRollout:          rolloutResource,
+	KindKnativeService:   knativeServiceResource,
+}
+
+// kindResource returns the dynamic client of a kind of kindResources in
+// namespace.
+func (c *customClient) kindResource(kind Kind, namespace string) (dynamic.ResourceInterface, error) {
+	gvr, ok := kindResources[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind: %s", kind)
+	}
+	if c.dynamic == nil {
+		return nil, fmt.Errorf("%s require a dynamic client", gvr.Resource)
+	}
+	return c.dynamic.Resource(gvr).Namespace(namespace), nil
+}
@@ -0,0 +1,159 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PDBPolicy selects how a scale down treats the PodDisruptionBudgets covering
+// the pods of its targets.
+type PDBPolicy string
+
+const (
+	// PDBPolicyWarn raises a warning for every target whose target replicas
+	// would violate a budget, and scales it anyway. It is the default.
+	PDBPolicyWarn PDBPolicy = "warn"
+	// PDBPolicyRespect refuses the run with a *PDBViolationError before
+	// anything is changed.
+	PDBPolicyRespect PDBPolicy = "respect"
+	// PDBPolicyIgnore does not look for budgets at all.
+	PDBPolicyIgnore PDBPolicy = "ignore"
+)
+
+// PDBViolationError is returned by Run when the target replicas of a scale
+// down would violate PodDisruptionBudgets and Options.PDBPolicy is
+// PDBPolicyRespect.
+type PDBViolationError struct {
+	Violations []string
+}
+
+func (e *PDBViolationError) Error() string {
+	return fmt.Sprintf("found %d PodDisruptionBudgets violated by the target replicas:\n- %s", len(e.Violations), strings.Join(e.Violations, "\n- "))
+}
+
+// PDB is a PodDisruptionBudget covering the pods of a target.
+type PDB struct {
+	Name string
+	// MinAvailable and MaxUnavailable are as set on the budget, e.g. "2" or
+	// "50%". At most one of them is set.
+	MinAvailable   string
+	MaxUnavailable string
+	// Violation explains how the target replicas would violate the budget.
+	// It is empty if they do not.
+	Violation string
+}
+
+// String describes the budget, e.g. "web (minAvailable 2)".
+func (p PDB) String() string {
+	switch {
+	case p.MinAvailable != "":
+		return fmt.Sprintf("%s (minAvailable %s)", p.Name, p.MinAvailable)
+	case p.MaxUnavailable != "":
+		return fmt.Sprintf("%s (maxUnavailable %s)", p.Name, p.MaxUnavailable)
+	}
+	return p.Name
+}
+
+type pdbIndex map[string][]policyv1.PodDisruptionBudget
+
+// indexPDBs lists the PodDisruptionBudgets once per namespace of the targets.
+func indexPDBs(ctx context.Context, client kubernetes.Interface, targets []Target) (pdbIndex, error) {
+	idx := pdbIndex{}
+	for _, t := range targets {
+		namespace := t.Item.Namespace
+		if _, listed := idx[namespace]; listed || t.Kind == KindCronJob || t.Kind == KindJob {
+			continue
+		}
+		list, err := client.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pod disruption budgets in namespace %s: %w", namespace, err)
+		}
+		idx[namespace] = list.Items
+	}
+	return idx, nil
+}
+
+// budgets returns the budgets selecting the pods of a workload, and whether
+// scaling it to target replicas would violate them. Percentages are taken of
+// the replicas from before the maintenance.
+func (idx pdbIndex) budgets(t Target, w *workload, target int32) []PDB {
+	if w.podLabels == nil {
+		return nil
+	}
+	expected := int(originalReplicas(w.annotations, w.replicas))
+
+	var budgets []PDB
+	for _, pdb := range idx[t.Item.Namespace] {
+		// A budget without a selector selects no pods.
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(w.podLabels)) {
+			continue
+		}
+		b := PDB{Name: pdb.Name}
+		switch {
+		case pdb.Spec.MinAvailable != nil:
+			b.MinAvailable = pdb.Spec.MinAvailable.String()
+			minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, expected, true)
+			if err == nil && int(target) < minAvailable {
+				b.Violation = fmt.Sprintf("%d replicas are below minAvailable %s", target, b.MinAvailable)
+			}
+		case pdb.Spec.MaxUnavailable != nil:
+			b.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+			maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, expected, true)
+			if unavailable := expected - int(target); err == nil && unavailable > maxUnavailable {
+				b.Violation = fmt.Sprintf("%d unavailable replicas exceed maxUnavailable %s", unavailable, b.MaxUnavailable)
+			}
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets
+}
+
+// violations describes the budgets of a workload violated by its target
+// replicas.
+func (idx pdbIndex) violations(t Target, w *workload, target int32) []string {
+	var violations []string
+	for _, b := range idx.budgets(t, w, target) {
+		if b.Violation != "" {
+			violations = append(violations, fmt.Sprintf("PodDisruptionBudget %s: %s", b.Name, b.Violation))
+		}
+	}
+	return violations
+}
+
+// checkPDBs fetches every target to compute its target replicas and returns
+// a *PDBViolationError if any of them would violate a budget. Targets that
+// cannot be fetched are left to the run to report.
+func (e *execution) checkPDBs(ctx context.Context, targets []Target) error {
+	var violations []string
+	for _, t := range targets {
+		if t.Kind == KindCronJob || t.Kind == KindJob {
+			continue
+		}
+		if cp := e.opts.Checkpoint; cp != nil && cp.completed(t) {
+			continue
+		}
+		w, err := e.getWorkload(ctx, t)
+		if err != nil {
+			continue
+		}
+		target, err := e.targetReplicas(t, e.mode, w.replicas, w.annotations)
+		if err != nil {
+			continue
+		}
+		for _, v := range e.pdbs.violations(t, w, target) {
+			violations = append(violations, fmt.Sprintf("%s %s/%s: %s", t.Label(), t.Item.Namespace, t.Item.Name, v))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &PDBViolationError{Violations: violations}
+}
@@ -0,0 +1,119 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PlanEntry describes what a run would do to a single target. CronJobs are
+// planned as 1 (active) or 0 (suspended).
+type PlanEntry struct {
+	Target          Target
+	CurrentReplicas int32
+	TargetReplicas  int32
+	// PDBs lists the PodDisruptionBudgets covering the pods of the target on
+	// scale down.
+	PDBs []PDB
+	Err  error
+	// Missing is set for the optional targets that do not exist, which a
+	// run skips, see Options.IgnoreMissing.
+	Missing bool
+	// Rejected is the error of the API server when the change was refused
+	// in a server-side dry run, see ServerDryRun.
+	Rejected error
+}
+
+// Action summarizes the planned change.
+func (p PlanEntry) Action() string {
+	switch {
+	case p.Missing:
+		return "skip (not found)"
+	case p.Err != nil:
+		return fmt.Sprintf("error: %v", p.Err)
+	case p.Rejected != nil:
+		return fmt.Sprintf("rejected: %v", p.Rejected)
+	case p.CurrentReplicas == p.TargetReplicas:
+		return "none"
+	case p.Target.Kind == KindCronJob && p.TargetReplicas == 0:
+		return "suspend"
+	case p.Target.Kind == KindCronJob:
+		return "resume"
+	case p.Target.Kind == KindJob && p.TargetReplicas < p.CurrentReplicas:
+		return string(jobPolicy(p.Target.Item))
+	case p.Target.Kind == KindJob:
+		return "resume"
+	case p.CurrentReplicas > p.TargetReplicas:
+		return "scale down"
+	default:
+		return "scale up"
+	}
+}
+
+// Plan fetches the current state of every target without changing anything.
+func (s *Scaler) Plan(ctx context.Context, mode Mode, targets []Target) []PlanEntry {
+	var pdbs pdbIndex
+	if mode == ModeScaleDown && s.opts.PDBPolicy != PDBPolicyIgnore {
+		var err error
+		if pdbs, err = indexPDBs(ctx, s.client, targets); err != nil {
+			s.emit(EventWarning, Target{}, "Not checking PodDisruptionBudgets: %v", err)
+		}
+	}
+
+	var plan []PlanEntry
+	for _, t := range targets {
+		entry := s.planFor(ctx, mode, t, pdbs)
+		if apierrors.IsNotFound(entry.Err) && s.optional(t) {
+			entry = PlanEntry{Target: t, Missing: true}
+		}
+		plan = append(plan, entry)
+	}
+	return plan
+}
+
+// optional reports whether a target is skipped when it does not exist.
+func (s *Scaler) optional(t Target) bool {
+	return s.opts.IgnoreMissing || t.Item.Optional
+}
+
+func (s *Scaler) planFor(ctx context.Context, mode Mode, t Target, pdbs pdbIndex) PlanEntry {
+	entry := PlanEntry{Target: t}
+
+	if t.Kind == KindCronJob {
+		c, err := s.client.BatchV1().CronJobs(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+		if err != nil {
+			entry.Err = err
+			return entry
+		}
+		if !isSuspended(c) {
+			entry.CurrentReplicas = 1
+		}
+		entry.TargetReplicas = entry.CurrentReplicas
+		if mode == ModeScaleDown {
+			entry.TargetReplicas = 0
+		} else if _, marked := c.Annotations[SuspendedAnnotation]; marked {
+			entry.TargetReplicas = 1
+		}
+		return entry
+	}
+	if t.Kind == KindJob {
+		return s.planJob(ctx, mode, t)
+	}
+	if t.Kind == KindKnativeService {
+		return s.planKnativeService(ctx, mode, t)
+	}
+
+	w, err := s.getWorkload(ctx, t)
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	entry.CurrentReplicas = w.replicas
+	entry.TargetReplicas, entry.Err = s.targetReplicas(t, mode, w.replicas, w.annotations)
+	if entry.Err == nil {
+		entry.PDBs = pdbs.budgets(t, w, entry.TargetReplicas)
+	}
+	return entry
+}
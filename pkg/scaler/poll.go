@@ -0,0 +1,91 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultPollInterval is the interval of Options.PollInterval when it is
+	// not set.
+	DefaultPollInterval = 2 * time.Second
+	// pollJitter spreads every poll over up to 20% more than its interval.
+	pollJitter = 0.2
+	// pollBackoff grows the interval of a wait that does not progress, up
+	// to pollMaxFactor times the poll interval.
+	pollBackoff   = 1.5
+	pollMaxFactor = 8
+)
+
+// poller paces the polls of a wait. Polls are jittered, so that the waits of
+// hundreds of targets scaled together do not send their requests to the API
+// server at the same time, and back off while nothing changes.
+type poller struct {
+	interval time.Duration
+	next     time.Duration
+	// failing is set while the polls fail with transient errors, and
+	// errors counts them up to maxErrors, see Options.MaxPollErrors.
+	failing   bool
+	errors    int
+	maxErrors int
+}
+
+func (e *execution) poller() *poller {
+	interval := e.opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &poller{interval: interval, next: interval, maxErrors: e.opts.MaxPollErrors}
+}
+
+// wait sleeps until the next poll, or returns the error of ctx if it is done
+// first. progressed resets the backoff.
+func (p *poller) wait(ctx context.Context, progressed bool) error {
+	p.failing = false
+	p.errors = 0
+	return p.sleep(ctx, progressed)
+}
+
+// backoff sleeps until the next poll after a poll failed with the transient
+// error err, backing off, or returns err once more than maxErrors polls
+// failed in a row.
+func (p *poller) backoff(ctx context.Context, err error) error {
+	p.errors++
+	if p.maxErrors > 0 && p.errors > p.maxErrors {
+		return fmt.Errorf("%d polls failed in a row: %w", p.errors, err)
+	}
+	return p.sleep(ctx, false)
+}
+
+func (p *poller) sleep(ctx context.Context, progressed bool) error {
+	if progressed {
+		p.next = p.interval
+	}
+	timer := time.NewTimer(wait.Jitter(p.next, pollJitter))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.next = min(time.Duration(float64(p.next)*pollBackoff), pollMaxFactor*p.interval)
+	return nil
+}
+
+// retry handles the failed poll of a wait: a transient error, such as the
+// connection lost to a restarting API server, is retried with backoff after
+// a warning, so that a target that scaled fine does not fail its wait, until
+// Options.MaxPollErrors polls failed in a row. Other errors are returned.
+func (e *execution) retry(ctx context.Context, t Target, p *poller, err error) error {
+	if !transient(err) {
+		return err
+	}
+	if !p.failing {
+		e.emit(EventWarning, t, "Polling failed, retrying until the API server is reachable again: %v", err)
+		p.failing = true
+	}
+	return p.backoff(ctx, err)
+}
@@ -0,0 +1,238 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PreflightError is returned by Run when some targets do not exist or cannot
+// be scaled by the caller. Nothing has been changed when it is returned.
+type PreflightError struct {
+	Problems []string
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight found %d problems:\n- %s", len(e.Problems), strings.Join(e.Problems, "\n- "))
+}
+
+// access is a permission required to process a target.
+type access struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	name        string
+}
+
+func (a access) String() string {
+	resource := a.resource
+	if a.group != "" {
+		resource += "." + a.group
+	}
+	if a.subresource != "" {
+		resource += "/" + a.subresource
+	}
+	return a.verb + " " + resource
+}
+
+// requiredAccess lists the permissions needed to scale a target in the given
+// mode.
+func (e *execution) requiredAccess(t Target) ([]access, error) {
+	name := t.Item.Name
+	var group, resource string
+	switch t.Kind {
+	case KindDeployment:
+		group, resource = "apps", "deployments"
+	case KindStatefulSet:
+		group, resource = "apps", "statefulsets"
+	case KindReplicationController:
+		group, resource = "", "replicationcontrollers"
+	case KindDeploymentConfig, KindRollout:
+		group, resource = kindResources[t.Kind].Group, kindResources[t.Kind].Resource
+	case KindCronJob:
+		return []access{{verb: "patch", group: "batch", resource: "cronjobs", name: name}}, nil
+	case KindJob:
+		switch {
+		case e.mode == ModeRestore || jobPolicy(t.Item) == JobPolicySuspend:
+			return []access{{verb: "patch", group: "batch", resource: "jobs", name: name}}, nil
+		case jobPolicy(t.Item) == JobPolicyDelete:
+			return []access{{verb: "delete", group: "batch", resource: "jobs", name: name}}, nil
+		}
+		return nil, nil
+	case KindKnativeService:
+		return []access{
+			{verb: "patch", group: knativeServiceResource.Group, resource: knativeServiceResource.Resource, name: name},
+			{verb: "list", resource: "pods"},
+		}, nil
+	case KindCustom:
+		mapping, err := e.custom.mappingFor(t.Item)
+		if err != nil {
+			return nil, err
+		}
+		group, resource = mapping.Resource.Group, mapping.Resource.Resource
+	default:
+		return nil, fmt.Errorf("unsupported kind: %s", t.Kind)
+	}
+
+	required := []access{
+		{verb: "patch", group: group, resource: resource, subresource: "scale", name: name},
+		{verb: "patch", group: group, resource: resource, name: name},
+	}
+	if t.Item.ReplicasPath != "" || t.Kind == KindReplicationController {
+		// The replicas are patched on the resource itself.
+		required = required[1:]
+	}
+	if !polled(t.Kind) {
+		// Informers list the resources before watching them.
+		required = append(required,
+			access{verb: "list", group: group, resource: resource},
+			access{verb: "watch", group: group, resource: resource},
+		)
+	}
+	if e.opts.ForceDeleteStuckAfter > 0 {
+		required = append(required,
+			access{verb: "list", resource: "pods"},
+			access{verb: "delete", resource: "pods"},
+		)
+	}
+	if e.sequential(t) {
+		required = append(required,
+			access{verb: "get", resource: "pods"},
+			access{verb: "watch", resource: "pods"},
+		)
+	}
+	if e.evicting(t) {
+		required = append(required,
+			access{verb: "list", resource: "pods"},
+			access{verb: "get", resource: "pods"},
+			access{verb: "watch", resource: "pods"},
+			access{verb: "create", resource: "pods", subresource: "eviction"},
+		)
+	}
+	if e.waitFor(t) == WaitForEndpoints && e.mode == ModeScaleDown {
+		required = append(required,
+			access{verb: "list", resource: "services"},
+			access{verb: "list", group: "discovery.k8s.io", resource: "endpointslices"},
+		)
+	}
+	if page := t.Item.MaintenancePage; page != nil {
+		required = append(required,
+			access{verb: "get", resource: "services", name: page.Service},
+			access{verb: "patch", resource: "services", name: page.Service},
+		)
+	}
+	if e.mode == ModeScaleDown && e.opts.PauseHPA {
+		for _, hpa := range e.hpas.forTarget(t) {
+			required = append(required, access{verb: "delete", group: "autoscaling", resource: "horizontalpodautoscalers", name: hpa.Name})
+		}
+	}
+	return required, nil
+}
+
+// exists fetches a target to make sure it can be found.
+func (e *execution) exists(ctx context.Context, t Target) error {
+	switch t.Kind {
+	case KindCronJob:
+		_, err := e.client.BatchV1().CronJobs(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+		return err
+	case KindJob:
+		_, err := e.client.BatchV1().Jobs(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+		if e.mode == ModeRestore && apierrors.IsNotFound(err) {
+			// Deleted on scale down, or finished and cleaned up.
+			return nil
+		}
+		return err
+	case KindKnativeService:
+		_, err := e.getKnativeService(ctx, t)
+		return err
+	}
+	_, err := e.getWorkload(ctx, t)
+	return err
+}
+
+func (e *execution) allowed(ctx context.Context, namespace string, a access) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        a.verb,
+				Group:       a.group,
+				Resource:    a.resource,
+				Subresource: a.subresource,
+				Name:        a.name,
+			},
+		},
+	}
+	result, err := e.client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return result.Status.Allowed, nil
+}
+
+// preflightTarget returns the problems that would prevent scaling a target.
+func (e *execution) preflightTarget(ctx context.Context, t Target) []string {
+	prefix := fmt.Sprintf("%s %s/%s", t.Label(), t.Item.Namespace, t.Item.Name)
+	if err := e.exists(ctx, t); err != nil {
+		if apierrors.IsNotFound(err) && e.optional(t) {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: %v", prefix, err)}
+	}
+	required, err := e.requiredAccess(t)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", prefix, err)}
+	}
+	var problems []string
+	for _, a := range required {
+		ok, err := e.allowed(ctx, t.Item.Namespace, a)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: failed to check permission to %s: %v", prefix, a, err))
+		} else if !ok {
+			problems = append(problems, fmt.Sprintf("%s: not allowed to %s", prefix, a))
+		}
+	}
+	return problems
+}
+
+// preflight checks that every target exists and that the caller is allowed
+// to scale it, before anything is changed.
+func (e *execution) preflight(ctx context.Context, targets []Target) error {
+	problems := make([][]string, len(targets))
+
+	limit := len(targets)
+	if e.opts.MaxConcurrency > 0 && e.opts.MaxConcurrency < limit {
+		limit = e.opts.MaxConcurrency
+	}
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if cp := e.opts.Checkpoint; cp != nil && cp.completed(t) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			problems[i] = e.preflightTarget(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	var all []string
+	for _, p := range problems {
+		all = append(all, p...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	return &PreflightError{Problems: all}
+}
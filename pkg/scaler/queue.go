@@ -0,0 +1,114 @@
+package scaler
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// waveQueue hands the targets of a wave out to the workers in the order of
+// queueOrder. With a limit, targets of a namespace that already has limit targets in flight are
+// held back, and the next target of another namespace is handed out instead,
+// so that a busy namespace does not hold up the rest of the wave.
+type waveQueue struct {
+	targets []Target
+	limit   int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []int
+	inFlight map[string]int
+}
+
+func newWaveQueue(targets []Target, indices []int, limit int) *waveQueue {
+	q := &waveQueue{targets: targets, limit: limit, pending: queueOrder(targets, indices), inFlight: map[string]int{}}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// next returns the index of the next target, waiting for a namespace to free
+// a slot if needed. Once ctx is done, the targets are handed out regardless
+// of the limit, to be skipped. It reports false when no target is left.
+func (q *waveQueue) next(ctx context.Context) (int, bool) {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.cond.Broadcast()
+	})
+	defer stop()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) > 0 {
+		for i, idx := range q.pending {
+			namespace := q.targets[idx].Item.Namespace
+			if q.limit > 0 && ctx.Err() == nil && q.inFlight[namespace] >= q.limit {
+				continue
+			}
+			q.pending = slices.Delete(q.pending, i, i+1)
+			q.inFlight[namespace]++
+			return idx, true
+		}
+		q.cond.Wait()
+	}
+	return 0, false
+}
+
+// done frees the slot of the namespace of a target returned by next.
+func (q *waveQueue) done(t Target) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight[t.Item.Namespace]--
+	q.cond.Broadcast()
+}
+
+// queueOrder returns the indices in the order the targets are handed out:
+// by descending ResourceItem.Priority, and within a priority alternating
+// between kinds, StatefulSets first, so that the long waits of slow
+// StatefulSets start early rather than after every Deployment. The targets
+// of a kind keep the order of the config.
+func queueOrder(targets []Target, indices []int) []int {
+	sorted := slices.Clone(indices)
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		return cmp.Compare(targets[b].Item.Priority, targets[a].Item.Priority)
+	})
+	ordered := make([]int, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && targets[sorted[end]].Item.Priority == targets[sorted[start]].Item.Priority {
+			end++
+		}
+		ordered = append(ordered, interleaveKinds(targets, sorted[start:end])...)
+		start = end
+	}
+	return ordered
+}
+
+// interleaveKinds takes a target of every kind in turn, StatefulSets first
+// and the other kinds in the order they first appear.
+func interleaveKinds(targets []Target, indices []int) []int {
+	var kinds []Kind
+	byKind := map[Kind][]int{}
+	for _, idx := range indices {
+		kind := targets[idx].Kind
+		if _, ok := byKind[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+		byKind[kind] = append(byKind[kind], idx)
+	}
+	if i := slices.Index(kinds, KindStatefulSet); i > 0 {
+		kinds = slices.Insert(slices.Delete(kinds, i, i+1), 0, KindStatefulSet)
+	}
+
+	ordered := make([]int, 0, len(indices))
+	for len(ordered) < len(indices) {
+		for _, kind := range kinds {
+			if pending := byKind[kind]; len(pending) > 0 {
+				ordered = append(ordered, pending[0])
+				byKind[kind] = pending[1:]
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,88 @@
+package scaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Quiesce is a command run in the pods of a resource before a scale down
+// removes them, for applications that need to flush or checkpoint their
+// data before they are terminated.
+type Quiesce struct {
+	// Exec is the command, e.g. ["sh", "-c", "nginx -s quit"].
+	Exec []string `json:"exec" yaml:"exec"`
+	// Container defaults to the default container of the pod.
+	Container string `json:"container,omitempty" yaml:"container,omitempty"`
+	// Timeout limits the command in each pod. It defaults to
+	// DefaultHookTimeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+func (q *Quiesce) validate() []string {
+	var problems []string
+	if len(q.Exec) == 0 {
+		problems = append(problems, "quiesce requires an exec command")
+	}
+	if q.Timeout != "" {
+		if d, err := time.ParseDuration(q.Timeout); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("invalid quiesce timeout %q", q.Timeout))
+		}
+	}
+	return problems
+}
+
+// quiesce runs the Quiesce command of a target in parallel in the pods a
+// scale down to targetReplicas removes, picked as the controller of the kind
+// would (see evictionOrder), and fails if it fails in any of them.
+func (e *execution) quiesce(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	q := t.Item.Quiesce
+	if q == nil || e.mode != ModeScaleDown || w.replicas <= targetReplicas {
+		return nil
+	}
+	if e.opts.PodExec == nil {
+		return fmt.Errorf("quiesce requires Options.PodExec")
+	}
+	if w.selector == "" {
+		return fmt.Errorf("quiesce requires a pod selector, %s has none", t.Kind)
+	}
+	list, err := e.client.CoreV1().Pods(t.Item.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.selector})
+	if err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+	pods := evictionOrder(t.Kind, list.Items, int(w.replicas-targetReplicas))
+	if len(pods) == 0 {
+		return nil
+	}
+
+	timeout := DefaultHookTimeout
+	if q.Timeout != "" {
+		timeout, _ = time.ParseDuration(q.Timeout)
+	}
+	e.emit(EventProgress, t, "Quiescing %d pods with %q...", len(pods), strings.Join(q.Exec, " "))
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			output, err := e.opts.PodExec(ctx, pod.Namespace, pod.Name, q.Container, q.Exec)
+			if err = withOutput(err, output); err != nil {
+				errs[i] = fmt.Errorf("pod %s: %w", pod.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("quiesce failed: %w", err)
+	}
+	e.emit(EventProgress, t, "Quiesced %d pods.", len(pods))
+	return nil
+}
@@ -0,0 +1,157 @@
+package scaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Replicas is the replica target of a config item: an absolute count such as
+// 3, a percentage such as "50%", or an offset such as "current-2". Percentages
+// and offsets are resolved at run time against the replica count the
+// resource had before the maintenance.
+type Replicas struct {
+	kind  replicasKind
+	value int32
+}
+
+type replicasKind int
+
+const (
+	replicasAbsolute replicasKind = iota
+	replicasPercent
+	replicasOffset
+)
+
+// ReplicaCount returns an absolute replica target.
+func ReplicaCount(n int32) *Replicas {
+	return &Replicas{kind: replicasAbsolute, value: n}
+}
+
+// ParseReplicas parses a replica target: "3", "50%", "current",
+// "current-2" or "current+1".
+func ParseReplicas(s string) (*Replicas, error) {
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid replicas %q, must be a count, a percentage such as \"50%%\" or an offset such as \"current-2\"", s)
+
+	switch {
+	case strings.HasSuffix(s, "%"):
+		percent, err := strconv.ParseInt(strings.TrimSuffix(s, "%"), 10, 32)
+		if err != nil || percent < 0 {
+			return nil, invalid
+		}
+		return &Replicas{kind: replicasPercent, value: int32(percent)}, nil
+	case strings.HasPrefix(s, "current"):
+		rest := strings.ReplaceAll(strings.TrimPrefix(s, "current"), " ", "")
+		if rest == "" {
+			return &Replicas{kind: replicasOffset}, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			return nil, invalid
+		}
+		offset, err := strconv.ParseInt(rest, 10, 32)
+		if err != nil {
+			return nil, invalid
+		}
+		return &Replicas{kind: replicasOffset, value: int32(offset)}, nil
+	default:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || n < 0 {
+			return nil, invalid
+		}
+		return ReplicaCount(int32(n)), nil
+	}
+}
+
+// Resolve returns the target replica count for a resource that had base
+// replicas before the maintenance. Percentages are rounded up, so that a
+// partial scale down keeps at least one replica of a running resource, and
+// offsets stop at zero.
+func (r Replicas) Resolve(base int32) int32 {
+	switch r.kind {
+	case replicasPercent:
+		return int32(math.Ceil(float64(base) * float64(r.value) / 100))
+	case replicasOffset:
+		return max(base+r.value, 0)
+	default:
+		return r.value
+	}
+}
+
+// IsAbsolute reports whether the target does not depend on the current
+// replica count.
+func (r Replicas) IsAbsolute() bool {
+	return r.kind == replicasAbsolute
+}
+
+func (r Replicas) String() string {
+	switch r.kind {
+	case replicasPercent:
+		return fmt.Sprintf("%d%%", r.value)
+	case replicasOffset:
+		if r.value == 0 {
+			return "current"
+		}
+		return fmt.Sprintf("current%+d", r.value)
+	default:
+		return strconv.Itoa(int(r.value))
+	}
+}
+
+// MarshalYAML writes absolute counts as numbers and other targets as strings.
+func (r Replicas) MarshalYAML() (interface{}, error) {
+	if r.IsAbsolute() {
+		return r.value, nil
+	}
+	return r.String(), nil
+}
+
+// UnmarshalYAML accepts a number or a string. Environment variables and
+// templates in the string are expanded first, as for names and namespaces.
+func (r *Replicas) UnmarshalYAML(node *yaml.Node) error {
+	// A *yaml.TypeError lets the decoding go on and report every problem
+	// of the config at once.
+	if node.Kind != yaml.ScalarNode {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: replicas must be a number or a string", node.Line)}}
+	}
+	value, err := expand(node.Value)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: replicas: %v", node.Line, err)}}
+	}
+	parsed, err := ParseReplicas(value)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", node.Line, err)}}
+	}
+	*r = *parsed
+	return nil
+}
+
+// MarshalJSON writes absolute counts as numbers and other targets as strings.
+func (r Replicas) MarshalJSON() ([]byte, error) {
+	if r.IsAbsolute() {
+		return json.Marshal(r.value)
+	}
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts a number or a string.
+func (r *Replicas) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int32
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("replicas must be a number or a string")
+		}
+		s = strconv.Itoa(int(n))
+	}
+	parsed, err := ParseReplicas(s)
+	if err != nil {
+		return err
+	}
+	*r = *parsed
+	return nil
+}
@@ -0,0 +1,131 @@
+package scaler
+
+import (
+	"sort"
+	"time"
+)
+
+// EventType classifies progress events emitted during a run.
+type EventType string
+
+const (
+	EventStarted   EventType = "started"
+	EventProgress  EventType = "progress"
+	EventCompleted EventType = "completed"
+	EventWarning   EventType = "warning"
+	EventFailed    EventType = "failed"
+	// EventWave announces the start of a wave, or its wait for an
+	// approval (see Options.Approve). Its target only carries the wave
+	// number.
+	EventWave EventType = "wave"
+)
+
+// Event reports progress for a single target. Warnings raised while
+// resolving selectors carry the selector item as target, without a name.
+type Event struct {
+	Type    EventType
+	Target  Target
+	Message string
+	// Replicas is set on the events of a target whose replica count is
+	// known, e.g. while waiting for it to reach its target.
+	Replicas *ReplicaProgress
+}
+
+// ReplicaProgress is the replica count of a target while it is scaled.
+type ReplicaProgress struct {
+	Current int32
+	Target  int32
+}
+
+// Status is the outcome of a single target.
+type Status string
+
+const (
+	StatusScaled    Status = "scaled"
+	StatusUnchanged Status = "unchanged"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// Result is the outcome of scaling a single target.
+type Result struct {
+	Target           Target
+	PreviousReplicas int32
+	TargetReplicas   int32
+	Status           Status
+	// Duration is the time spent on the target, including hooks and the
+	// pausing of HPAs and GitOps controllers.
+	Duration time.Duration
+	// ScaleDuration is the time from the first scale command to the target
+	// reaching its replicas. It is zero if the target was not scaled.
+	ScaleDuration time.Duration
+	Err           error
+	// ResetBy names the field manager that raised the replicas back above
+	// the target after the scale down, see Options.WatchResets. It is
+	// "unknown" if no field manager owns the replicas.
+	ResetBy string
+
+	// changed is set once the target has been modified, even if it failed
+	// afterwards.
+	changed bool
+}
+
+// Report summarizes a run.
+type Report struct {
+	Mode    Mode
+	Results []Result
+	// Rollback holds the results of restoring the changed targets after a
+	// failed scale down with ErrorPolicyRollback, or an interrupted one with
+	// Options.RollbackOnInterrupt.
+	Rollback []Result
+	// Interrupted is set when the context was cancelled during the run.
+	Interrupted bool
+	// Cordoned lists the nodes of Options.Nodes cordoned by a scale down.
+	// Nodes that were already cordoned are not listed.
+	Cordoned []NodeItem
+}
+
+// Changed reports whether the target was modified, even if it failed
+// afterwards.
+func (r Result) Changed() bool {
+	return r.changed
+}
+
+// Changed returns the results of the targets that were modified.
+func (r Report) Changed() []Result {
+	var changed []Result
+	for _, res := range r.Results {
+		if res.changed {
+			changed = append(changed, res)
+		}
+	}
+	return changed
+}
+
+// Failed returns the results of the targets that failed.
+func (r Report) Failed() []Result {
+	return failedResults(r.Results)
+}
+
+// RollbackFailed returns the results of the targets that could not be
+// rolled back.
+func (r Report) RollbackFailed() []Result {
+	return failedResults(r.Rollback)
+}
+
+// Slowest returns the n targets that took the longest, slowest first.
+func (r Report) Slowest(n int) []Result {
+	results := append([]Result(nil), r.Results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Duration > results[j].Duration })
+	return results[:min(n, len(results))]
+}
+
+func failedResults(results []Result) []Result {
+	var failed []Result
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,111 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// resetsInterval is how often the replicas of the scaled targets are checked
+// while watching for resets.
+const resetsInterval = 5 * time.Second
+
+// watchResets checks the replicas of the targets scaled down by the run for
+// Options.WatchResets, and warns about the ones raised back above their
+// target by another controller, e.g. an HPA, an operator or a GitOps tool, or
+// lowered back below it for the targets scaled up.
+// The field manager that set the replicas is recorded in Result.ResetBy.
+func (e *execution) watchResets(ctx context.Context, results []Result) {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.WatchResets)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range results {
+		res := &results[i]
+		if res.Target.Kind == KindCronJob || res.Target.Kind == KindJob || res.Target.Kind == KindKnativeService || res.Err != nil || (res.Status != StatusScaled && res.Status != StatusUnchanged) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.watchReset(ctx, res)
+		}()
+	}
+	wg.Wait()
+}
+
+// watchReset checks the replicas of a single target until ctx is done or
+// they are reset.
+func (e *execution) watchReset(ctx context.Context, res *Result) {
+	t := res.Target
+	path := []string{"spec", "replicas"}
+	if t.Item.ReplicasPath != "" {
+		if parsed, err := parseFieldPath(t.Item.ReplicasPath); err == nil {
+			path = parsed
+		}
+	}
+	ticker := time.NewTicker(resetsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		w, err := e.getWorkload(ctx, t)
+		if err != nil || w.replicas == res.TargetReplicas || (w.replicas < res.TargetReplicas) != (res.TargetReplicas > res.PreviousReplicas) {
+			continue
+		}
+		res.ResetBy = fieldManager(w.managedFields, path)
+		if res.ResetBy == "" {
+			res.ResetBy = "unknown"
+		}
+		e.emit(EventWarning, t, "Replicas were reset to %d after the scale down by field manager %s. Pause it to keep the resource at its target.", w.replicas, res.ResetBy)
+		return
+	}
+}
+
+// fieldManager returns the manager that last set the field at path, from the
+// managed fields of an object, or an empty string if none owns it.
+func fieldManager(entries []metav1.ManagedFieldsEntry, path []string) string {
+	var manager string
+	var latest time.Time
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil || !ownsField(entry.FieldsV1.Raw, path) {
+			continue
+		}
+		var at time.Time
+		if entry.Time != nil {
+			at = entry.Time.Time
+		}
+		if manager == "" || !at.Before(latest) {
+			manager, latest = entry.Manager, at
+		}
+	}
+	return manager
+}
+
+// ownsField reports whether a FieldsV1 set, e.g. {"f:spec":{"f:replicas":{}}},
+// contains the field at path.
+func ownsField(raw []byte, path []string) bool {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	for i, name := range path {
+		value, ok := fields["f:"+name]
+		if !ok {
+			return false
+		}
+		if i == len(path)-1 {
+			return true
+		}
+		if fields, ok = value.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return false
+}
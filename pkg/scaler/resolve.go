@@ -0,0 +1,286 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Resolve expands selector items into one target per matching resource.
+// Deployments come first, then StatefulSets, CronJobs, custom resources and
+// the resources of whole namespaces. A resource matched by several items is
+// only scaled once, as configured by its first item. The wave of every target
+// is resolved from its wave and dependencies.
+func (s *Scaler) Resolve(ctx context.Context, cfg Config) ([]Target, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.Grouped()
+	var targets []Target
+	for _, group := range []struct {
+		kind  Kind
+		items []ResourceItem
+	}{
+		{KindDeployment, cfg.Deployments},
+		{KindStatefulSet, cfg.StatefulSets},
+		{KindCronJob, cfg.CronJobs},
+		{KindJob, cfg.Jobs},
+		{KindReplicationController, cfg.ReplicationControllers},
+		{KindDeploymentConfig, cfg.DeploymentConfigs},
+		{KindRollout, cfg.Rollouts},
+		{KindKnativeService, cfg.KnativeServices},
+		{KindCustom, cfg.Custom},
+	} {
+		if err := s.validateContexts(group.items, group.kind); err != nil {
+			return nil, err
+		}
+		items, err := s.resolveResources(ctx, group.items, group.kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			targets = append(targets, Target{Kind: group.kind, Item: item})
+		}
+	}
+	for _, ns := range cfg.Namespaces {
+		if ns.Context != "" && ns.Context != s.opts.Cluster {
+			return nil, fmt.Errorf("namespace %s belongs to context %q, split the config with SplitByContext", ns.Name, ns.Context)
+		}
+		nsTargets, err := s.resolveNamespace(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, nsTargets...)
+	}
+	for i := range targets {
+		targets[i].Cluster = s.opts.Cluster
+	}
+	targets = dedupeTargets(targets)
+	if err := assignWaves(targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (s *Scaler) resolveResources(ctx context.Context, items []ResourceItem, kind Kind) ([]ResourceItem, error) {
+	var result []ResourceItem
+	for _, item := range items {
+		pattern := isNamePattern(item.Name)
+		if item.Name != "" && !pattern {
+			result = append(result, item)
+			continue
+		}
+		if pattern || len(item.Labels) > 0 || item.Selector != "" {
+			selector, err := itemSelector(item)
+			if err != nil {
+				return nil, err
+			}
+			listOpts := metav1.ListOptions{LabelSelector: selector}
+
+			// An empty namespace lists matching resources across all namespaces.
+			matched := 0
+			add := func(name, namespace string) {
+				if pattern {
+					if ok, _ := path.Match(item.Name, name); !ok {
+						return
+					}
+				}
+				newItem := item
+				newItem.Name = name
+				newItem.Namespace = namespace
+				result = append(result, newItem)
+				matched++
+			}
+			if kind == KindDeployment {
+				list, err := s.client.AppsV1().Deployments(item.Namespace).List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list deployments matching %s: %w", matchDescription(item.Name, selector), err)
+				}
+				for _, d := range list.Items {
+					add(d.Name, d.Namespace)
+				}
+			} else if kind == KindStatefulSet {
+				list, err := s.client.AppsV1().StatefulSets(item.Namespace).List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list statefulsets matching %s: %w", matchDescription(item.Name, selector), err)
+				}
+				for _, sts := range list.Items {
+					add(sts.Name, sts.Namespace)
+				}
+			} else if kind == KindCronJob {
+				list, err := s.client.BatchV1().CronJobs(item.Namespace).List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list cronjobs matching %s: %w", matchDescription(item.Name, selector), err)
+				}
+				for _, c := range list.Items {
+					add(c.Name, c.Namespace)
+				}
+			} else if kind == KindJob {
+				list, err := s.client.BatchV1().Jobs(item.Namespace).List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list jobs matching %s: %w", matchDescription(item.Name, selector), err)
+				}
+				for _, j := range list.Items {
+					add(j.Name, j.Namespace)
+				}
+			} else if kind == KindReplicationController {
+				list, err := s.client.CoreV1().ReplicationControllers(item.Namespace).List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list replicationcontrollers matching %s: %w", matchDescription(item.Name, selector), err)
+				}
+				for _, rc := range list.Items {
+					add(rc.Name, rc.Namespace)
+				}
+			} else if _, ok := kindResources[kind]; ok {
+				resource, err := s.custom.kindResource(kind, item.Namespace)
+				if err != nil {
+					return nil, err
+				}
+				list, err := resource.List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list %s matching %s: %w", kindResources[kind].Resource, matchDescription(item.Name, selector), err)
+				}
+				for _, o := range list.Items {
+					add(o.GetName(), o.GetNamespace())
+				}
+			} else if kind == KindCustom {
+				resource, err := s.custom.resourceFor(item)
+				if err != nil {
+					return nil, err
+				}
+				list, err := resource.List(ctx, listOpts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list %s matching %s: %w", item.Kind, matchDescription(item.Name, selector), err)
+				}
+				for _, o := range list.Items {
+					add(o.GetName(), o.GetNamespace())
+				}
+			}
+			if matched == 0 {
+				t := Target{Kind: kind, Item: item}
+				s.emit(EventWarning, t, "no %s resources matched %s in %s", t.Label(), matchDescription(item.Name, selector), namespaceDescription(item.Namespace))
+			}
+		}
+	}
+	return result, nil
+}
+
+// validateContexts rejects items of another cluster than the one of the
+// Scaler.
+func (s *Scaler) validateContexts(items []ResourceItem, kind Kind) error {
+	for _, item := range items {
+		if item.Context != "" && item.Context != s.opts.Cluster {
+			return fmt.Errorf("%s %s belongs to context %q, split the config with SplitByContext", kind.Label(), itemDescription(item), item.Context)
+		}
+	}
+	return nil
+}
+
+// itemDescription names a config item for error messages.
+func itemDescription(item ResourceItem) string {
+	if isNamePattern(item.Name) {
+		selector, _ := itemSelector(item)
+		return fmt.Sprintf("%s/%s", item.Namespace, matchDescription(item.Name, selector))
+	}
+	if item.Name == "" {
+		selector, _ := itemSelector(item)
+		return fmt.Sprintf("with selector %q", selector)
+	}
+	return item.Namespace + "/" + item.Name
+}
+
+// resolveNamespace lists the Deployments and StatefulSets of a namespace.
+func (s *Scaler) resolveNamespace(ctx context.Context, ns NamespaceItem) ([]Target, error) {
+	if ns.Name == "" {
+		return nil, fmt.Errorf("namespaces entries require a name")
+	}
+	excluded := map[string]bool{}
+	for _, name := range ns.Exclude {
+		excluded[name] = true
+	}
+	newTarget := func(kind Kind, name string) Target {
+		return Target{Kind: kind, Item: ResourceItem{Name: name, Namespace: ns.Name, Replicas: ns.Replicas, Wave: ns.Wave, PauseAfter: ns.PauseAfter, Timeout: ns.Timeout, WaitFor: ns.WaitFor, Groups: ns.Groups}}
+	}
+
+	var targets []Target
+	deployments, err := s.client.AppsV1().Deployments(ns.Name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments in namespace %s: %w", ns.Name, err)
+	}
+	for _, d := range deployments.Items {
+		if !excluded[d.Name] {
+			targets = append(targets, newTarget(KindDeployment, d.Name))
+		}
+	}
+	statefulSets, err := s.client.AppsV1().StatefulSets(ns.Name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets in namespace %s: %w", ns.Name, err)
+	}
+	for _, sts := range statefulSets.Items {
+		if !excluded[sts.Name] {
+			targets = append(targets, newTarget(KindStatefulSet, sts.Name))
+		}
+	}
+	if len(targets) == 0 {
+		s.emit(EventWarning, Target{}, "no deployments or statefulsets to scale in namespace %s", ns.Name)
+	}
+	return targets, nil
+}
+
+// dedupeTargets drops targets referring to a resource already listed.
+func dedupeTargets(targets []Target) []Target {
+	seen := map[string]bool{}
+	var result []Target
+	for _, t := range targets {
+		key := targetKey(t)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, t)
+	}
+	return result
+}
+
+func itemSelector(item ResourceItem) (string, error) {
+	selector := labels.SelectorFromSet(item.Labels)
+	if item.Selector != "" {
+		parsed, err := labels.Parse(item.Selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid selector %q: %w", item.Selector, err)
+		}
+		requirements, _ := parsed.Requirements()
+		selector = selector.Add(requirements...)
+	}
+	return selector.String(), nil
+}
+
+// isNamePattern reports whether the name of an item is a glob pattern, as
+// matched by path.Match, e.g. "payments-*", resolved against the resources
+// of its namespace.
+func isNamePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// matchDescription describes what the resources resolved from an item with a
+// name pattern or a selector must match.
+func matchDescription(pattern, selector string) string {
+	switch {
+	case pattern == "":
+		return fmt.Sprintf("selector %q", selector)
+	case selector == "":
+		return fmt.Sprintf("name %q", pattern)
+	}
+	return fmt.Sprintf("name %q and selector %q", pattern, selector)
+}
+
+func namespaceDescription(namespace string) string {
+	if namespace == "" {
+		return "all namespaces"
+	}
+	return "namespace " + namespace
+}
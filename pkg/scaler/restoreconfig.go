@@ -0,0 +1,48 @@
+package scaler
+
+// RestoreConfig returns a config restoring the targets of a scale down to the
+// replica counts they had before it, so that a restore with it does not
+// depend on the state file or the annotations of the resources. A target
+// takes its original count from state, or the count the report found before
+// scaling it. Targets without a known count, e.g. skipped before being read,
+// are left out. CronJobs, Jobs and Knative Services are listed without
+// replicas, since their restore does not take a count.
+//
+// Every item keeps the wave the scale down ran it in, without dependencies,
+// which may name targets that were left out. The nodes cordoned by the scale
+// down are listed, so that the restore uncordons them.
+func (r Report) RestoreConfig(state *State) Config {
+	var c Config
+	for _, res := range r.Results {
+		t := res.Target
+		item := t.Item
+		item.Wave = t.Wave
+		item.DependsOn = nil
+		item.Replicas = nil
+		if item.Context == "" {
+			item.Context = t.Cluster
+		}
+		switch t.Kind {
+		case KindCronJob, KindJob, KindKnativeService:
+			if res.Status == StatusSkipped {
+				continue
+			}
+		default:
+			replicas, ok := int32(0), false
+			if state != nil {
+				replicas, ok = state.lookup(t)
+			}
+			if !ok && (res.Status == StatusScaled || res.Status == StatusUnchanged) {
+				replicas, ok = res.PreviousReplicas, true
+			}
+			if !ok {
+				continue
+			}
+			item.Replicas = ReplicaCount(replicas)
+		}
+		section := c.sectionRef(t.Kind)
+		*section = append(*section, item)
+	}
+	c.Nodes = append(c.Nodes, r.Cordoned...)
+	return c
+}
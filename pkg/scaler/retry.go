@@ -0,0 +1,73 @@
+package scaler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultRetry is the backoff used when Options.Retry is not set: five
+// attempts, 10ms apart at first and doubling after every attempt.
+var DefaultRetry = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// retriable reports whether an API error is worth another attempt: update
+// conflicts, client-side and server-side throttling, and transient server
+// failures.
+func retriable(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err)
+}
+
+// transient reports whether an error of a wait may go away by itself: the
+// errors of retriable, and a connection to the API server that was refused,
+// reset or closed, e.g. while it restarts.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return retriable(err) ||
+		errors.As(err, &netErr) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err) ||
+		utilnet.IsHTTP2ConnectionLost(err)
+}
+
+// withRetry runs fn until it succeeds, fails with an error that is not
+// retriable, or the backoff is exhausted, and then returns the last error of
+// fn. It stops waiting for the next attempt as soon as ctx is done, returning
+// the error of ctx.
+func withRetry(ctx context.Context, backoff wait.Backoff, fn func() error) error {
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(context.Context) (bool, error) {
+		err := fn()
+		switch {
+		case err == nil:
+			return true, nil
+		case retriable(err):
+			lastErr = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if wait.Interrupted(err) && ctx.Err() == nil {
+		err = lastErr
+	}
+	return err
+}
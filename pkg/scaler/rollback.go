@@ -0,0 +1,51 @@
+package scaler
+
+import (
+	"context"
+
+	"parallel-scale-down/pkg/tracing"
+)
+
+// rollback restores every changed target to the replica count it had before
+// the scale down. Waves are rolled back in reverse order, and a failing wave
+// does not stop the remaining ones.
+func (e *execution) rollback(ctx context.Context, results []Result) []Result {
+	var targets []Target
+	for _, res := range results {
+		if !res.changed {
+			continue
+		}
+		t := res.Target
+		if t.Kind != KindCronJob && t.Kind != KindJob {
+			t.Item.Replicas = ReplicaCount(res.PreviousReplicas)
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	// The rollback restores everything, so the checkpoint no longer applies.
+	if cp := e.opts.Checkpoint; cp != nil {
+		cp.mu.Lock()
+		cp.Completed = nil
+		cp.mu.Unlock()
+	}
+
+	s := *e.Scaler
+	s.opts.Checkpoint = nil
+	rb := &execution{Scaler: &s, mode: ModeRestore, watcher: e.watcher}
+
+	e.emit(EventWarning, Target{}, "Rolling back %d resources...", len(targets))
+	rollback := make([]Result, len(targets))
+	for i := range targets {
+		rollback[i].Target = targets[i]
+	}
+	ctx, span := tracing.Start(ctx, "rollback", tracing.Int("resources", len(targets)))
+	groups := waves(targets)
+	for i := len(groups) - 1; i >= 0; i-- {
+		rb.runWave(ctx, targets, groups[i], rollback)
+	}
+	span.End(nil)
+	return rollback
+}
@@ -0,0 +1,65 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// rolloutResource is the resource of Argo Rollouts.
+var rolloutResource = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+// rolloutStatus reads the replica counts of a Rollout. Its scale subresource
+// reports status.HPAReplicas, which lags behind the pods of the canary and
+// stable ReplicaSets, so the status is read instead.
+func rolloutStatus(obj *unstructured.Unstructured) replicaStatus {
+	count := func(field string) int32 {
+		value, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return int32(value)
+	}
+	// observedGeneration is a string in the status of Rollouts.
+	observed, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "status", "observedGeneration")
+	return replicaStatus{
+		replicas:  count("replicas"),
+		ready:     count("readyReplicas"),
+		available: count("availableReplicas"),
+		observed:  fmt.Sprint(observed) == fmt.Sprint(obj.GetGeneration()),
+	}
+}
+
+// waitForRollout polls a Rollout until its status reaches the target
+// replicas.
+func (e *execution) waitForRollout(ctx context.Context, t Target, targetReplicas int32) error {
+	resource, err := e.custom.kindResource(KindRollout, t.Item.Namespace)
+	if err != nil {
+		return err
+	}
+	poll := e.poller()
+	var lastProgress string
+	for {
+		obj, err := resource.Get(ctx, t.Item.Name, metav1.GetOptions{})
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		status := rolloutStatus(obj)
+		if status.reached(targetReplicas, e.waitFor(t)) {
+			return nil
+		}
+		progress := status.progress(t.Kind, targetReplicas, e.waitFor(t))
+		progressed := progress != lastProgress
+		if progressed {
+			e.emitReplicas(t, status.replicas, targetReplicas, "%s", progress)
+			lastProgress = progress
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
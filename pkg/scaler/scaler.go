@@ -0,0 +1,904 @@
+// Package scaler scales Kubernetes workloads down for a maintenance and
+// restores them afterwards, processing every resource in parallel.
+package scaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/tracing"
+)
+
+// Mode selects whether a run scales resources down or restores them.
+type Mode string
+
+const (
+	ModeScaleDown Mode = "scale down"
+	ModeRestore   Mode = "restore"
+)
+
+// Kind identifies the type of resource a config item refers to.
+type Kind string
+
+const (
+	KindDeployment  Kind = "deployment"
+	KindStatefulSet Kind = "statefulset"
+	KindCronJob     Kind = "cronjob"
+	KindCustom      Kind = "custom"
+	// KindJob is a batch Job, stopped on scale down with the JobPolicy of
+	// its item, see handleJob.
+	KindJob Kind = "job"
+	// KindReplicationController is the legacy predecessor of Deployments.
+	KindReplicationController Kind = "replicationcontroller"
+	// KindDeploymentConfig is an OpenShift DeploymentConfig, scaled through
+	// the scale subresource of apps.openshift.io/v1.
+	KindDeploymentConfig Kind = "deploymentconfig"
+	// KindRollout is an Argo Rollout, scaled through spec.replicas and
+	// waited for with the replica counts of its status.
+	KindRollout Kind = "rollout"
+	// KindKnativeService is a Knative Service, scaled through the
+	// min-scale and max-scale annotations of its revision template, see
+	// handleKnativeService.
+	KindKnativeService Kind = "knativeservice"
+)
+
+// Label returns the Kubernetes kind name, e.g. "Deployment".
+func (k Kind) Label() string {
+	switch k {
+	case KindDeployment:
+		return "Deployment"
+	case KindStatefulSet:
+		return "StatefulSet"
+	case KindCronJob:
+		return "CronJob"
+	case KindJob:
+		return "Job"
+	case KindReplicationController:
+		return "ReplicationController"
+	case KindDeploymentConfig:
+		return "DeploymentConfig"
+	case KindRollout:
+		return "Rollout"
+	case KindKnativeService:
+		return "KnativeService"
+	}
+	return string(k)
+}
+
+// Target is a single resolved resource to scale.
+type Target struct {
+	Kind Kind
+	Item ResourceItem
+	// Wave is the effective wave, taking dependencies into account.
+	Wave int
+	// Cluster is the kubeconfig context of the target in multi-cluster
+	// runs, see Options.Cluster.
+	Cluster string
+}
+
+// Ref names the resource of the target as "namespace/name", prefixed with
+// "cluster:" in multi-cluster runs.
+func (t Target) Ref() string {
+	ref := t.Item.Namespace + "/" + t.Item.Name
+	if t.Cluster != "" {
+		ref = t.Cluster + ":" + ref
+	}
+	return ref
+}
+
+// Label names the kind of the target for output, using the configured kind
+// for custom resources.
+func (t Target) Label() string {
+	if t.Kind == KindCustom && t.Item.Kind != "" {
+		return t.Item.Kind
+	}
+	return t.Kind.Label()
+}
+
+// targetKey identifies the resource of a target across kinds.
+func targetKey(t Target) string {
+	return string(t.Kind) + "/" + stateKey(t)
+}
+
+// ErrorPolicy selects how a run reacts to a failing target.
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyContinue lets the other targets of the wave finish and
+	// reports every failure at the end. Later waves are skipped.
+	ErrorPolicyContinue ErrorPolicy = "continue"
+	// ErrorPolicyFailFast cancels the run on the first failure. Targets that
+	// have not started yet are skipped.
+	ErrorPolicyFailFast ErrorPolicy = "fail-fast"
+	// ErrorPolicyRollback behaves like ErrorPolicyContinue, then restores
+	// every target that was already changed. It only applies to scale down.
+	ErrorPolicyRollback ErrorPolicy = "rollback"
+)
+
+// WaitFor selects when a resource is considered to have reached its target
+// replicas.
+type WaitFor string
+
+const (
+	// WaitForReplicas waits for the number of pods to match the target. It
+	// is the default.
+	WaitForReplicas WaitFor = "replicas"
+	// WaitForReady also waits for the ready and available replicas, and the
+	// updated replicas of StatefulSets, to match the target, so that a scale
+	// up only succeeds once its pods are serving. Custom resources only
+	// report their replicas through the scale subresource and fall back to
+	// WaitForReplicas.
+	WaitForReady WaitFor = "ready"
+	// WaitForEndpoints waits like WaitForReplicas, then, for the targets
+	// scaled down to zero, waits for the Services selecting their pods to
+	// have no ready endpoints left, so that no traffic is routed to them
+	// anymore when the maintenance begins.
+	WaitForEndpoints WaitFor = "endpoints"
+	// WaitForPodsDeleted waits like WaitForReplicas, then, on scale down,
+	// for the pods removed by the scale down to be deleted, including the
+	// ones still terminating, e.g. before a volume is detached.
+	WaitForPodsDeleted WaitFor = "podsDeleted"
+	// WaitForVolumesDetached waits like WaitForPodsDeleted, then, for
+	// StatefulSets, for the volumes of the claims of the removed pods to be
+	// detached from their nodes, for storage maintenances. Other kinds wait
+	// like WaitForPodsDeleted.
+	WaitForVolumesDetached WaitFor = "volumesDetached"
+	// WaitForCustom waits like WaitForReplicas, then for the
+	// ResourceItem.Condition of the mode to hold on the resource. It can
+	// only be set on items.
+	WaitForCustom WaitFor = "custom"
+
+	// WaitForReadyReplicas and WaitForEndpointsDrained are the long names
+	// of WaitForReady and WaitForEndpoints.
+	WaitForReadyReplicas    WaitFor = "readyReplicas"
+	WaitForEndpointsDrained WaitFor = "endpointsDrained"
+)
+
+// normalized returns the condition of the long names of WaitFor.
+func (w WaitFor) normalized() WaitFor {
+	switch w {
+	case WaitForReadyReplicas:
+		return WaitForReady
+	case WaitForEndpointsDrained:
+		return WaitForEndpoints
+	}
+	return w
+}
+
+// RestoreOrder selects the order of the waves of a restore.
+type RestoreOrder string
+
+const (
+	// RestoreOrderReverse runs the waves of a restore in the reverse order
+	// of the scale down, so that the resources scaled down last, such as
+	// databases, come back first. Every wave but the last is waited for
+	// until ready, so that the next wave finds its dependencies serving. It
+	// is the default.
+	RestoreOrderReverse RestoreOrder = "reverse"
+	// RestoreOrderForward runs the waves of a restore in the order of the
+	// scale down.
+	RestoreOrderForward RestoreOrder = "forward"
+)
+
+// Options configures a Scaler.
+type Options struct {
+	// Dynamic and Mapper are required to scale custom resources.
+	Dynamic dynamic.Interface
+	Mapper  meta.RESTMapper
+
+	// State holds original replica counts. It is filled on scale down and
+	// consulted on restore for items without explicit replicas.
+	State *State
+
+	// MaxConcurrency limits how many resources are processed at the same
+	// time. Zero means no limit.
+	MaxConcurrency int
+
+	// MaxPerNamespace limits how many resources of a namespace are processed
+	// at the same time, e.g. for namespaces whose webhooks or operators do
+	// not keep up with many simultaneous updates. The targets of other
+	// namespaces are not held up. Zero means no limit.
+	MaxPerNamespace int
+
+	// PauseHPA removes HorizontalPodAutoscalers targeting scaled resources
+	// instead of failing the run, and recreates them on restore.
+	PauseHPA bool
+
+	// Unavailable, if set, limits how many targets are scaled down at the
+	// same time, across every Scaler sharing it: a target takes a slot
+	// before it is scaled down and frees it once it has reached its target
+	// replicas. CronJobs and restores are not limited.
+	Unavailable *Budget
+
+	// Timeout limits how long each target may take to reach its target
+	// replicas. Zero means no limit. ResourceItem.Timeout overrides it.
+	Timeout time.Duration
+
+	// WaitFor selects when a target has reached its target replicas. The
+	// zero value is WaitForReplicas. ResourceItem.WaitFor overrides it.
+	WaitFor WaitFor
+
+	// OnError selects what happens when a target fails. The zero value is
+	// ErrorPolicyContinue.
+	OnError ErrorPolicy
+
+	// RestoreOrder selects the order of the waves of a restore. The zero
+	// value is RestoreOrderReverse.
+	RestoreOrder RestoreOrder
+
+	// Retry is the backoff of API requests failing with a conflict, a
+	// throttling or a transient server error. It defaults to DefaultRetry.
+	Retry wait.Backoff
+
+	// Retries is the number of times the targets that failed are retried
+	// once every target of their wave is done, after RetryDelay and twice as
+	// long before every next retry. Targets are not retried with
+	// ErrorPolicyFailFast.
+	Retries    int
+	RetryDelay time.Duration
+
+	// AdmissionRetries is the number of times a target whose change was
+	// denied by an admission webhook or policy is retried, AdmissionRetryDelay
+	// apart, within its timeout. See AdmissionError.
+	AdmissionRetries    int
+	AdmissionRetryDelay time.Duration
+
+	// RollbackOnInterrupt restores every target that was already changed
+	// when the context of a scale down is cancelled. ErrorPolicyRollback
+	// implies it.
+	RollbackOnInterrupt bool
+
+	// SuspendGitOps suspends the reconciliation of the Argo CD Applications
+	// and Flux Kustomizations and HelmReleases managing scaled resources, and
+	// resumes it on restore. Without it, such resources only raise a warning.
+	SuspendGitOps bool
+
+	// SuspendFlux is SuspendGitOps restricted to Flux Kustomizations and
+	// HelmReleases, leaving Argo CD Applications to their warning.
+	SuspendFlux bool
+
+	// ArgoCDNamespace is the namespace of Argo CD Applications. It defaults
+	// to DefaultArgoCDNamespace.
+	ArgoCDNamespace string
+
+	// Cluster names the kubeconfig context the clients talk to in
+	// multi-cluster runs. It is set on every target and event, and only
+	// items whose context is empty or Cluster are accepted.
+	Cluster string
+
+	// PreHook runs after the preflight, before anything is scaled. PostHook
+	// runs once every target has reached its target replicas. A failing
+	// hook fails the run.
+	PreHook  *Hook
+	PostHook *Hook
+
+	// Nodes are cordoned once every target of a scale down has reached its
+	// target replicas, and uncordoned before the targets of a restore are
+	// scaled, if a scale down cordoned them (see CordonedAnnotation).
+	Nodes []NodeItem
+
+	// PDBPolicy selects how a scale down treats PodDisruptionBudgets that
+	// its target replicas would violate. The zero value is PDBPolicyWarn.
+	PDBPolicy PDBPolicy
+
+	// ForceDeleteStuckAfter, if set, force deletes the pods of a target that
+	// are still terminating after this long while waiting for its target
+	// replicas, with a grace period of zero.
+	ForceDeleteStuckAfter time.Duration
+
+	// Evict scales down the targets without a strategy as StrategyEvict
+	// does: their pods are evicted, honoring PodDisruptionBudgets, before
+	// the target replicas are set.
+	Evict bool
+
+	// WatchResets, if set, keeps checking the replicas of the scaled down
+	// targets for this long once every wave is done, and warns when another
+	// controller raises them back, naming its field manager.
+	WatchResets time.Duration
+
+	// PollInterval is how often the targets that are not watched, such as
+	// custom resources, are polled while waiting for their target replicas.
+	// Polls are jittered and back off while nothing changes. Defaults to
+	// DefaultPollInterval.
+	PollInterval time.Duration
+	// MaxPollErrors fails a wait once more than this many of its polls
+	// failed in a row with transient errors, such as throttling, server
+	// errors or timeouts. They are retried with backoff until the timeout
+	// of the target when it is 0.
+	MaxPollErrors int
+
+	// Marks are set on every target on scale down, and removed on restore.
+	Marks Marks
+
+	// RecordEvents records a Kubernetes Event on every resource whose
+	// replicas are changed, naming Actor as the one who ran the
+	// maintenance and RunID as the run that changed them.
+	RecordEvents bool
+	Actor        string
+	RunID        string
+
+	// PodExec runs the commands of exec hooks. Exec hooks fail without it.
+	PodExec PodExecFunc
+
+	// SkipPreflight disables the check that every target exists and can be
+	// scaled by the caller before anything is changed.
+	SkipPreflight bool
+
+	// IgnoreMissing skips the targets that do not exist, as if every item
+	// was ResourceItem.Optional. They are reported as StatusSkipped.
+	IgnoreMissing bool
+
+	// Checkpoint, if set, lists targets completed by a previous run of the
+	// same mode. They are skipped, and targets completing during this run
+	// are added to it.
+	Checkpoint *Checkpoint
+
+	// Approve is called once every target of a wave with PauseAfter is
+	// done, before the next wave starts. It blocks until the next wave is
+	// approved, or returns an error to skip the remaining waves. Runs with
+	// PauseAfter fail before changing anything without it.
+	Approve func(ctx context.Context, next Target) error
+
+	// OnEvent receives progress events. It is called from multiple
+	// goroutines.
+	OnEvent func(Event)
+
+	// OnResult, if set, receives the result of every target as soon as it
+	// is done, and again after every retry. It is called from multiple
+	// goroutines.
+	OnResult func(Result)
+}
+
+// Scaler scales the resources of a Config in parallel.
+type Scaler struct {
+	client kubernetes.Interface
+	custom *customClient
+	state  *State
+	opts   Options
+}
+
+// New returns a Scaler using the given clientset.
+func New(client kubernetes.Interface, opts Options) *Scaler {
+	state := opts.State
+	if state == nil {
+		state = &State{}
+	}
+	if opts.Retry.Steps == 0 {
+		opts.Retry = DefaultRetry
+	}
+	return &Scaler{
+		client: client,
+		custom: &customClient{dynamic: opts.Dynamic, mapper: opts.Mapper},
+		state:  state,
+		opts:   opts,
+	}
+}
+
+func (s *Scaler) emit(eventType EventType, t Target, format string, args ...interface{}) {
+	s.send(Event{Type: eventType, Target: t, Message: fmt.Sprintf(format, args...)})
+}
+
+// emitReplicas emits a progress event carrying the current and target
+// replicas of t.
+func (s *Scaler) emitReplicas(t Target, current, target int32, format string, args ...interface{}) {
+	s.send(Event{
+		Type:     EventProgress,
+		Target:   t,
+		Message:  fmt.Sprintf(format, args...),
+		Replicas: &ReplicaProgress{Current: current, Target: target},
+	})
+}
+
+func (s *Scaler) send(ev Event) {
+	if s.opts.OnEvent == nil {
+		return
+	}
+	if ev.Target.Cluster == "" {
+		ev.Target.Cluster = s.opts.Cluster
+	}
+	s.opts.OnEvent(ev)
+}
+
+func (s *Scaler) result(res Result) {
+	if s.opts.OnResult != nil {
+		s.opts.OnResult(res)
+	}
+}
+
+// ScaleDown resolves and scales down every resource in the config.
+func (s *Scaler) ScaleDown(ctx context.Context, cfg Config) (Report, error) {
+	targets, err := s.Resolve(ctx, cfg)
+	if err != nil {
+		return Report{Mode: ModeScaleDown}, err
+	}
+	return s.Run(ctx, ModeScaleDown, targets)
+}
+
+// Restore resolves every resource in the config and scales it back to its
+// original replica count.
+func (s *Scaler) Restore(ctx context.Context, cfg Config) (Report, error) {
+	targets, err := s.Resolve(ctx, cfg)
+	if err != nil {
+		return Report{Mode: ModeRestore}, err
+	}
+	return s.Run(ctx, ModeRestore, targets)
+}
+
+// execution holds the state of a single Run.
+type execution struct {
+	*Scaler
+	mode    Mode
+	watcher *statusWatcher
+	hpas    hpaIndex
+	pdbs    pdbIndex
+	// nodes are the names of the nodes of Options.Nodes.
+	nodes []string
+
+	// abort cancels the run context with ErrorPolicyFailFast. aborted is set
+	// once it was called.
+	abort   context.CancelFunc
+	aborted atomic.Bool
+
+	// gitOpsDone records the GitOps objects already suspended or resumed.
+	gitOpsMu   sync.Mutex
+	gitOpsDone map[string]error
+
+	// readyForNextWave is set while a restore runs a wave in reverse order
+	// that is followed by another, see RestoreOrderReverse.
+	readyForNextWave bool
+
+	// group is the Group the run is a member of, if any. finished is set
+	// once the run told it whether it failed.
+	group    *Group
+	finished bool
+	// preflighted is set when Preflight already checked the targets.
+	preflighted bool
+}
+
+// cancelReason explains why a target did not run or finish after the run
+// context was cancelled.
+func (e *execution) cancelReason() string {
+	if e.aborted.Load() {
+		return "another resource failed"
+	}
+	return "the run was interrupted"
+}
+
+// fail cancels the run if the error policy is fail-fast.
+func (e *execution) fail() {
+	if e.abort == nil {
+		return
+	}
+	if e.group != nil {
+		e.group.abort()
+		return
+	}
+	e.aborted.Store(true)
+	e.abort()
+}
+
+// finish tells the group of the run, if any, whether the run failed. Only
+// the first call counts.
+func (e *execution) finish(failed bool) {
+	if e.group == nil || e.finished {
+		return
+	}
+	e.finished = true
+	e.group.finish(failed)
+}
+
+// Run scales the given targets in parallel and waits for them to reach their
+// target replicas. The returned error is non-nil if the run could not start
+// or if any target failed. When ctx is cancelled, in-flight targets stop
+// waiting, the remaining ones are skipped and the report is marked as
+// interrupted.
+func (s *Scaler) Run(ctx context.Context, mode Mode, targets []Target) (Report, error) {
+	e := &execution{Scaler: s, mode: mode}
+	return e.run(ctx, targets)
+}
+
+func (e *execution) run(ctx context.Context, targets []Target) (Report, error) {
+	s, mode := e.Scaler, e.mode
+	report := Report{Mode: mode}
+	// A run that stops early counts as failed for the other members of its
+	// group.
+	defer e.finish(true)
+
+	if s.opts.Checkpoint != nil {
+		s.opts.Checkpoint.Mode = mode
+	}
+	checkCtx, span := tracing.Start(ctx, "preflight", tracing.String("cluster", s.opts.Cluster), tracing.Int("resources", len(targets)))
+	err := e.check(checkCtx, targets)
+	span.End(err)
+	if err != nil {
+		return report, err
+	}
+
+	if err := e.runHook(ctx, Target{}, HookPre, s.opts.PreHook); err != nil {
+		return report, err
+	}
+
+	if mode == ModeRestore {
+		if err := e.uncordonNodes(ctx); err != nil {
+			return report, err
+		}
+	}
+
+	e.watcher = newStatusWatcher(s.client)
+	defer e.watcher.stop()
+
+	runCtx := ctx
+	if s.opts.OnError == ErrorPolicyFailFast {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		e.abort = cancel
+	}
+	if e.group != nil {
+		e.group.join(e)
+	}
+
+	report.Results = make([]Result, len(targets))
+	for i := range targets {
+		report.Results[i].Target = targets[i]
+	}
+
+	groups := waves(targets)
+	reversed := ReversesWaves(mode, s.opts.RestoreOrder)
+	if reversed {
+		slices.Reverse(groups)
+	}
+	for n, wave := range groups {
+		e.readyForNextWave = reversed && n < len(groups)-1
+		if len(groups) > 1 {
+			e.emit(EventWave, Target{Wave: targets[wave[0]].Wave}, "Starting wave %d (%d resources)...", targets[wave[0]].Wave, len(wave))
+		}
+		waveCtx, span := tracing.Start(runCtx, fmt.Sprintf("wave %d", targets[wave[0]].Wave),
+			tracing.String("cluster", s.opts.Cluster), tracing.Int("wave", targets[wave[0]].Wave), tracing.Int("resources", len(wave)))
+		var waveErr error
+		if !e.runWaveWithRetries(waveCtx, targets, wave, report.Results) {
+			waveErr = fmt.Errorf("wave %d failed", targets[wave[0]].Wave)
+		}
+		span.End(waveErr)
+		skipReason := ""
+		if waveErr != nil {
+			skipReason = waveErr.Error()
+		} else if n < len(groups)-1 && pausesAfter(targets, wave) {
+			if err := e.approve(runCtx, targets[wave[0]].Wave, targets[groups[n+1][0]].Wave); err != nil {
+				skipReason = fmt.Sprintf("wave %d was not approved: %v", targets[groups[n+1][0]].Wave, err)
+			}
+		}
+		if skipReason != "" {
+			for _, rest := range groups[n+1:] {
+				for _, idx := range rest {
+					report.Results[idx].Status = StatusSkipped
+					if runCtx.Err() != nil {
+						report.Results[idx].Err = fmt.Errorf("skipped because %s", e.cancelReason())
+					} else {
+						report.Results[idx].Err = fmt.Errorf("skipped because %s", skipReason)
+					}
+				}
+			}
+			break
+		}
+	}
+
+	e.readyForNextWave = false
+
+	// The members of a group wait for each other, so that a failure in
+	// any of them rolls back all of them.
+	othersFailed := false
+	if e.group != nil {
+		ownFailed := len(report.Failed()) > 0 || ctx.Err() != nil
+		e.finish(ownFailed)
+		othersFailed = e.group.anyFailed(ctx) && !ownFailed
+	}
+
+	var cordonErr error
+	if mode == ModeScaleDown && len(e.nodes) > 0 && ctx.Err() == nil {
+		if len(report.Failed()) == 0 && !othersFailed {
+			report.Cordoned, cordonErr = e.cordonNodes(ctx)
+		} else {
+			e.emit(EventWarning, Target{}, "Not cordoning the nodes, since not every resource reached its target.")
+		}
+	}
+
+	if mode == ModeScaleDown && s.opts.WatchResets > 0 && ctx.Err() == nil && !e.aborted.Load() &&
+		(len(report.Failed()) == 0 && !othersFailed || s.opts.OnError != ErrorPolicyRollback) {
+		resetsCtx, span := tracing.Start(ctx, "watch resets")
+		e.watchResets(resetsCtx, report.Results)
+		span.End(nil)
+	}
+
+	failed := report.Failed()
+	if ctx.Err() != nil {
+		report.Interrupted = true
+		if mode == ModeScaleDown && (s.opts.RollbackOnInterrupt || s.opts.OnError == ErrorPolicyRollback) {
+			// The rollback must run even though the run context is done.
+			report.Rollback = e.rollback(context.WithoutCancel(ctx), report.Results)
+			if rollbackFailed := len(report.RollbackFailed()); rollbackFailed > 0 {
+				return report, fmt.Errorf("interrupted, rollback failed for %d resources", rollbackFailed)
+			}
+			return report, fmt.Errorf("interrupted, rolled back %d resources", len(report.Rollback))
+		}
+		return report, fmt.Errorf("interrupted after changing %d resources", len(report.Changed()))
+	}
+	if othersFailed && mode == ModeScaleDown && s.opts.OnError == ErrorPolicyRollback {
+		report.Rollback = e.rollback(ctx, report.Results)
+		if rollbackFailed := len(report.RollbackFailed()); rollbackFailed > 0 {
+			return report, fmt.Errorf("another cluster failed, rollback failed for %d resources", rollbackFailed)
+		}
+		return report, fmt.Errorf("another cluster failed, rolled back %d resources", len(report.Rollback))
+	}
+	if len(failed) == 0 {
+		if cordonErr != nil {
+			return report, cordonErr
+		}
+		return report, e.runHook(ctx, Target{}, HookPost, s.opts.PostHook)
+	}
+	if e.aborted.Load() {
+		return report, fmt.Errorf("stopped after the first failure, %d resources failed or were skipped", len(failed))
+	}
+	if mode == ModeScaleDown && s.opts.OnError == ErrorPolicyRollback {
+		report.Rollback = e.rollback(ctx, report.Results)
+		if rollbackFailed := len(report.RollbackFailed()); rollbackFailed > 0 {
+			return report, fmt.Errorf("finished with %d errors, rollback failed for %d resources", len(failed), rollbackFailed)
+		}
+		return report, fmt.Errorf("finished with %d errors, rolled back %d resources", len(failed), len(report.Rollback))
+	}
+	return report, fmt.Errorf("finished with %d errors", len(failed))
+}
+
+// Preflight runs the checks done by Run before anything is changed, so that
+// several runs, e.g. against different clusters, can be checked before any
+// of them starts. It returns a *PreflightError, an *HPAConflictError or a
+// *PDBViolationError for targets that cannot be scaled.
+func (s *Scaler) Preflight(ctx context.Context, mode Mode, targets []Target) error {
+	e := &execution{Scaler: s, mode: mode}
+	return e.check(ctx, targets)
+}
+
+// approve waits for the approval of the next wave once wave is done.
+func (e *execution) approve(ctx context.Context, wave, next int) error {
+	e.emit(EventWave, Target{Wave: wave}, "Wave %d done, waiting for the approval to start wave %d...", wave, next)
+	if err := e.opts.Approve(ctx, Target{Cluster: e.opts.Cluster, Wave: next}); err != nil {
+		e.emit(EventWarning, Target{Wave: next}, "Wave %d was not approved: %v", next, err)
+		return err
+	}
+	e.emit(EventWave, Target{Wave: next}, "Wave %d approved.", next)
+	return nil
+}
+
+// check indexes the HPAs and PodDisruptionBudgets of the targets and runs
+// the preflight.
+func (e *execution) check(ctx context.Context, targets []Target) error {
+	if e.opts.Approve == nil {
+		for _, t := range targets {
+			if t.Item.PauseAfter {
+				return fmt.Errorf("%s %s/%s pauses after wave %d, which requires an approval", t.Label(), t.Item.Namespace, t.Item.Name, t.Wave)
+			}
+		}
+	}
+	if e.mode == ModeScaleDown {
+		hpas, err := indexHPAs(ctx, e.client, targets)
+		if err != nil {
+			return err
+		}
+		e.hpas = hpas
+	}
+	if e.mode == ModeScaleDown && e.opts.PDBPolicy != PDBPolicyIgnore {
+		pdbs, err := indexPDBs(ctx, e.client, targets)
+		if err != nil {
+			if e.opts.PDBPolicy == PDBPolicyRespect {
+				return err
+			}
+			e.emit(EventWarning, Target{}, "Not checking PodDisruptionBudgets: %v", err)
+		}
+		e.pdbs = pdbs
+	}
+	if len(e.opts.Nodes) > 0 {
+		nodes, err := e.resolveNodes(ctx)
+		if err != nil {
+			return err
+		}
+		e.nodes = nodes
+	}
+	if e.preflighted {
+		return nil
+	}
+	if !e.opts.SkipPreflight {
+		if err := e.preflight(ctx, targets); err != nil {
+			return err
+		}
+	}
+	if e.mode == ModeScaleDown && !e.opts.PauseHPA {
+		if err := checkHPAConflicts(targets, e.hpas); err != nil {
+			return err
+		}
+	}
+	if e.mode == ModeScaleDown && e.opts.PDBPolicy == PDBPolicyRespect {
+		return e.checkPDBs(ctx, targets)
+	}
+	return nil
+}
+
+// runWave scales the targets with the given indices in parallel and reports
+// whether all of them succeeded.
+func (e *execution) runWave(ctx context.Context, targets []Target, indices []int, results []Result) bool {
+	queue := newWaveQueue(targets, indices, e.opts.MaxPerNamespace)
+
+	workers := len(indices)
+	if e.opts.MaxConcurrency > 0 && e.opts.MaxConcurrency < workers {
+		workers = e.opts.MaxConcurrency
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				idx, ok := queue.next(ctx)
+				if !ok {
+					return
+				}
+				e.runTarget(ctx, targets[idx], &results[idx])
+				queue.done(targets[idx])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, idx := range indices {
+		if results[idx].Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// runTarget scales a target of a wave and records its result in res.
+func (e *execution) runTarget(ctx context.Context, t Target, res *Result) {
+	cp := e.opts.Checkpoint
+	if cp != nil && cp.completed(t) {
+		res.Status = StatusSkipped
+		e.emit(EventCompleted, t, "Completed by a previous run, skipping.")
+		e.result(*res)
+		return
+	}
+	if ctx.Err() != nil {
+		res.Status = StatusSkipped
+		res.Err = fmt.Errorf("skipped because %s", e.cancelReason())
+		e.result(*res)
+		return
+	}
+	if e.optional(t) {
+		if err := e.exists(ctx, t); apierrors.IsNotFound(err) {
+			res.Status = StatusSkipped
+			e.emit(EventWarning, t, "Not found, skipping it as optional.")
+			e.result(*res)
+			return
+		}
+	}
+	limited := e.opts.Unavailable != nil && e.mode == ModeScaleDown && t.Kind != KindCronJob && t.Kind != KindJob
+	if limited {
+		if err := e.opts.Unavailable.acquire(ctx); err != nil {
+			res.Status = StatusSkipped
+			res.Err = fmt.Errorf("skipped because %s", e.cancelReason())
+			e.result(*res)
+			return
+		}
+	}
+	start := time.Now()
+	targetCtx, span := tracing.Start(ctx, t.Label()+" "+t.Ref(),
+		tracing.String("cluster", t.Cluster), tracing.String("kind", t.Label()), tracing.String("namespace", t.Item.Namespace), tracing.String("name", t.Item.Name), tracing.Int("wave", t.Wave))
+	res.Err = e.scaleTargetWithTimeout(targetCtx, t, res)
+	res.Duration = time.Since(start)
+	span.SetAttributes(tracing.String("status", string(res.Status)), tracing.Int("replicas.previous", int(res.PreviousReplicas)), tracing.Int("replicas.target", int(res.TargetReplicas)))
+	span.End(res.Err)
+	if limited {
+		e.opts.Unavailable.release()
+	}
+	if res.Err != nil {
+		if ctx.Err() != nil {
+			res.Err = fmt.Errorf("cancelled because %s: %w", e.cancelReason(), res.Err)
+		}
+		res.Status = StatusFailed
+		e.emit(EventFailed, t, "Failed: %v", res.Err)
+		e.fail()
+	} else if cp != nil {
+		cp.markCompleted(t)
+	}
+	e.result(*res)
+}
+
+// runWaveWithRetries runs a wave, then retries its failed targets up to
+// Options.Retries times. It reports whether all of them succeeded in the end.
+func (e *execution) runWaveWithRetries(ctx context.Context, targets []Target, indices []int, results []Result) bool {
+	ok := e.runWave(ctx, targets, indices, results)
+	delay := e.opts.RetryDelay
+	for retry := 1; !ok && retry <= e.opts.Retries && !e.aborted.Load(); retry++ {
+		var failed []int
+		for _, idx := range indices {
+			if results[idx].Status == StatusFailed {
+				failed = append(failed, idx)
+			}
+		}
+		if len(failed) == 0 {
+			break
+		}
+		e.emit(EventWarning, Target{Wave: targets[indices[0]].Wave}, "%d resources failed, retrying them in %s (retry %d of %d)...", len(failed), delay, retry, e.opts.Retries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false
+		}
+		for _, idx := range failed {
+			// A target changed by a previous attempt must still be rolled
+			// back if the retry fails, to the replicas it had before the
+			// first attempt rather than those left by it.
+			prev := results[idx]
+			results[idx] = Result{Target: targets[idx], changed: prev.changed}
+			if prev.changed {
+				results[idx].PreviousReplicas = prev.PreviousReplicas
+			}
+		}
+		ok = e.runWave(ctx, targets, failed, results)
+		delay *= 2
+	}
+	return ok
+}
+
+// ErrTimeout is wrapped by the errors of the targets that exceeded
+// Options.Timeout.
+var ErrTimeout = errors.New("timed out")
+
+func (e *execution) scaleTargetWithTimeout(ctx context.Context, t Target, res *Result) error {
+	timeout := e.timeout(t)
+	if timeout <= 0 {
+		return e.scaleTargetAdmitted(ctx, t, res)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := e.scaleTargetAdmitted(timeoutCtx, t, res)
+	if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, err)
+	}
+	return err
+}
+
+func (e *execution) scaleTarget(ctx context.Context, t Target, res *Result) error {
+	e.emit(EventStarted, t, "Starting %s...", e.mode)
+
+	if err := e.runHook(ctx, t, HookPre, t.Item.PreHook); err != nil {
+		return err
+	}
+	var err error
+	switch t.Kind {
+	case KindDeployment, KindStatefulSet, KindReplicationController, KindDeploymentConfig, KindRollout, KindCustom:
+		err = e.scaleWorkload(ctx, t, res)
+	case KindKnativeService:
+		err = e.handleKnativeService(ctx, t, res)
+	case KindCronJob:
+		err = e.handleCronJob(ctx, t, res)
+	case KindJob:
+		err = e.handleJob(ctx, t, res)
+	default:
+		err = fmt.Errorf("unsupported kind: %s", t.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	return e.runHook(ctx, t, HookPost, t.Item.PostHook)
+}
@@ -0,0 +1,64 @@
+package scaler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+// TestRollbackAfterFailedRetry scales down a Deployment whose pods never go
+// away, so that the first attempt times out after lowering its replicas, and
+// the retry fails to read it. The rollback must restore the replicas it had before
+// the first attempt, not those left by it.
+func TestRollbackAfterFailedRetry(t *testing.T) {
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+		Status:     appsv1.DeploymentStatus{Replicas: 3},
+	})
+	deploymentScales(t, client)
+	retried := false
+	client.PrependReactor("get", "deployments", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		d, err := client.Tracker().Get(a.GetResource(), a.GetNamespace(), a.(k8stesting.GetAction).GetName())
+		if err != nil || a.GetSubresource() != "" || retried || *d.(*appsv1.Deployment).Spec.Replicas != 0 {
+			return false, nil, nil
+		}
+		retried = true
+		return true, nil, apierrors.NewForbidden(a.GetResource().GroupResource(), "web", errors.New("denied"))
+	})
+	s := New(client, Options{
+		SkipPreflight: true,
+		OnError:       ErrorPolicyRollback,
+		Timeout:       200 * time.Millisecond,
+		Retries:       1,
+		RetryDelay:    time.Millisecond,
+	})
+	targets := []Target{{Kind: KindDeployment, Item: ResourceItem{Name: "web", Namespace: "shop", Replicas: ReplicaCount(0)}}}
+
+	report, err := s.Run(context.Background(), ModeScaleDown, targets)
+	if err == nil {
+		t.Fatal("Run() succeeded, want the scale down to time out")
+	}
+	if got := report.Results[0].PreviousReplicas; got != 3 {
+		t.Errorf("PreviousReplicas after the retry = %d, want 3", got)
+	}
+	if len(report.Rollback) != 1 {
+		t.Fatalf("rolled back %d resources, want 1", len(report.Rollback))
+	}
+	d, err := client.AppsV1().Deployments("shop").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := specReplicas(d.Spec.Replicas); got != 3 {
+		t.Errorf("spec.replicas after the rollback = %d, want 3", got)
+	}
+}
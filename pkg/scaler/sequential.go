@@ -0,0 +1,135 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// sequential reports whether a target is scaled down one replica at a time.
+func (e *execution) sequential(t Target) bool {
+	return e.mode == ModeScaleDown && t.Kind == KindStatefulSet && t.Item.Strategy == StrategySequential
+}
+
+// scaleSequentially scales a StatefulSet down one replica at a time. After
+// every step it waits for the pod with the highest ordinal to be deleted, so
+// that members of a database cluster leave one after the other.
+func (e *execution) scaleSequentially(ctx context.Context, t Target, w *workload, targetReplicas int32, res *Result) error {
+	sts, err := e.client.AppsV1().StatefulSets(t.Item.Namespace).Get(ctx, t.Item.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	var start int32
+	if sts.Spec.Ordinals != nil {
+		start = sts.Spec.Ordinals.Start
+	}
+
+	for replicas := w.replicas - 1; replicas >= targetReplicas; replicas-- {
+		changed, err := updateScale(ctx, e.opts.Retry, w.scales, t, replicas)
+		if err != nil {
+			return err
+		}
+		res.changed = res.changed || changed
+
+		pod := fmt.Sprintf("%s-%d", t.Item.Name, start+replicas)
+		e.emitReplicas(t, replicas+1, targetReplicas, "Scaled to %d replicas. Waiting for pod %s to terminate...", replicas, pod)
+		if err := e.waitForPodDeleted(ctx, t.Item.Namespace, pod, ""); err != nil {
+			return fmt.Errorf("waiting for pod %s to terminate: %w", pod, err)
+		}
+	}
+	return e.waitForReplicas(ctx, t, w, targetReplicas)
+}
+
+// waitForPodDeleted watches a single pod until it is deleted. A pod that does
+// not exist counts as deleted, as does a pod replaced by one with another UID
+// when uid is set. A watch closed by the server resumes from the last resource
+// version it saw, bookmarks included, and transient errors, such as the
+// connection lost to a restarting API server, are retried with backoff.
+func (e *execution) waitForPodDeleted(ctx context.Context, namespace, name string, uid types.UID) error {
+	pods := e.client.CoreV1().Pods(namespace)
+	poll := e.poller()
+	var resourceVersion string
+	for {
+		if resourceVersion == "" {
+			pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				if !transient(err) {
+					return err
+				}
+				if err := poll.backoff(ctx, err); err != nil {
+					return err
+				}
+				continue
+			}
+			poll.errors = 0
+			if uid != "" && pod.UID != uid {
+				return nil
+			}
+			resourceVersion = pod.ResourceVersion
+		}
+
+		watcher, err := pods.Watch(ctx, metav1.ListOptions{
+			FieldSelector:       fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err == nil {
+			var deleted bool
+			deleted, err = podDeleted(ctx, watcher, &resourceVersion)
+			watcher.Stop()
+			if deleted {
+				return nil
+			}
+		}
+		switch {
+		case err == nil:
+			// The watch was closed by the server, resume it.
+			poll.errors = 0
+			continue
+		case apierrors.IsResourceExpired(err) || apierrors.IsGone(err):
+			// The resource version is too old to resume from, get the pod
+			// again.
+			resourceVersion = ""
+			continue
+		case !transient(err):
+			return err
+		}
+		if err := poll.backoff(ctx, err); err != nil {
+			return err
+		}
+	}
+}
+
+// podDeleted reads the events of a pod watch until the pod is deleted or the
+// watch ends, recording the resource version of every event in
+// resourceVersion.
+func podDeleted(ctx context.Context, watcher watch.Interface, resourceVersion *string) (bool, error) {
+	for {
+		select {
+		case ev, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			switch ev.Type {
+			case watch.Deleted:
+				return true, nil
+			case watch.Error:
+				return false, apierrors.FromObject(ev.Object)
+			}
+			if obj, err := meta.Accessor(ev.Object); err == nil && obj.GetResourceVersion() != "" {
+				*resourceVersion = obj.GetResourceVersion()
+			}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Snapshot lists the Deployments and StatefulSets in the given namespaces
+// and returns a config naming each of them with its current replica count.
+// An empty namespace lists across all namespaces.
+func (s *Scaler) Snapshot(ctx context.Context, namespaces []string, selector string) (*Config, error) {
+	listOpts := metav1.ListOptions{LabelSelector: selector}
+	cfg := &Config{}
+	for _, namespace := range namespaces {
+		deployments, err := s.client.AppsV1().Deployments(namespace).List(ctx, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments in %s: %w", namespaceDescription(namespace), err)
+		}
+		for _, d := range deployments.Items {
+			cfg.Deployments = append(cfg.Deployments, snapshotItem(d.ObjectMeta, d.Spec.Replicas))
+		}
+
+		statefulSets, err := s.client.AppsV1().StatefulSets(namespace).List(ctx, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statefulsets in %s: %w", namespaceDescription(namespace), err)
+		}
+		for _, sts := range statefulSets.Items {
+			cfg.StatefulSets = append(cfg.StatefulSets, snapshotItem(sts.ObjectMeta, sts.Spec.Replicas))
+		}
+	}
+	return cfg, nil
+}
+
+func snapshotItem(meta metav1.ObjectMeta, replicas *int32) ResourceItem {
+	// Resources that are scaled down already keep their original count.
+	current := originalReplicas(meta.Annotations, specReplicas(replicas))
+	return ResourceItem{Name: meta.Name, Namespace: meta.Namespace, Replicas: ReplicaCount(current)}
+}
@@ -0,0 +1,149 @@
+package scaler
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	OriginalReplicasAnnotation = "parallel-scale-down/original-replicas"
+	SuspendedAnnotation        = "parallel-scale-down/suspended"
+	PausedHPAsAnnotation       = "parallel-scale-down/paused-hpas"
+	AutomatedSyncAnnotation    = "parallel-scale-down/automated-sync"
+)
+
+// State records original replica counts keyed by "namespace/name", or by
+// "Kind.group/namespace/name" for custom resources, Rollouts and Knative
+// Services.
+type State struct {
+	mu sync.Mutex
+
+	Deployments            map[string]int32 `yaml:"deployments"`
+	StatefulSets           map[string]int32 `yaml:"statefulsets"`
+	ReplicationControllers map[string]int32 `yaml:"replicationcontrollers,omitempty"`
+	DeploymentConfigs      map[string]int32 `yaml:"deploymentconfigs,omitempty"`
+	Custom                 map[string]int32 `yaml:"custom"`
+}
+
+// LoadState reads a state file. An empty path or a missing file yields an
+// empty state.
+func LoadState(path string) (*State, error) {
+	state := &State{}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the state to path.
+func (s *State) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *State) entries(kind Kind) map[string]int32 {
+	switch kind {
+	case KindDeployment:
+		if s.Deployments == nil {
+			s.Deployments = map[string]int32{}
+		}
+		return s.Deployments
+	case KindStatefulSet:
+		if s.StatefulSets == nil {
+			s.StatefulSets = map[string]int32{}
+		}
+		return s.StatefulSets
+	case KindReplicationController:
+		if s.ReplicationControllers == nil {
+			s.ReplicationControllers = map[string]int32{}
+		}
+		return s.ReplicationControllers
+	case KindDeploymentConfig:
+		if s.DeploymentConfigs == nil {
+			s.DeploymentConfigs = map[string]int32{}
+		}
+		return s.DeploymentConfigs
+	case KindCustom, KindRollout, KindKnativeService:
+		// Rollouts and Knative Services are recorded with the custom
+		// resources, under their group and kind, see stateKey.
+		if s.Custom == nil {
+			s.Custom = map[string]int32{}
+		}
+		return s.Custom
+	}
+	return map[string]int32{}
+}
+
+// stateKey is "namespace/name", prefixed with the group and kind of custom
+// resources, and with "cluster:" in multi-cluster runs.
+func stateKey(t Target) string {
+	r := t.Item
+	key := r.Namespace + "/" + r.Name
+	switch t.Kind {
+	case KindCustom:
+		key = schema.GroupKind{Group: r.Group, Kind: r.Kind}.String() + "/" + key
+	case KindRollout:
+		key = schema.GroupKind{Group: rolloutResource.Group, Kind: "Rollout"}.String() + "/" + key
+	case KindKnativeService:
+		key = schema.GroupKind{Group: knativeServiceResource.Group, Kind: "Service"}.String() + "/" + key
+	}
+	if t.Cluster != "" {
+		key = t.Cluster + ":" + key
+	}
+	return key
+}
+
+func (s *State) recordIfMissing(t Target, replicas int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.entries(t.Kind)
+	key := stateKey(t)
+	if _, ok := entries[key]; !ok {
+		entries[key] = replicas
+	}
+}
+
+func (s *State) lookup(t Target) (int32, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replicas, ok := s.entries(t.Kind)[stateKey(t)]
+	return replicas, ok
+}
+
+func annotatedReplicas(annotations map[string]string) (int32, bool) {
+	value, ok := annotations[OriginalReplicasAnnotation]
+	if !ok {
+		return 0, false
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(replicas), true
+}
+
+func originalReplicas(annotations map[string]string, current int32) int32 {
+	if replicas, ok := annotatedReplicas(annotations); ok {
+		return replicas
+	}
+	return current
+}
@@ -0,0 +1,108 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validateSteps returns the problems of the steps of a config item.
+func validateSteps(item ResourceItem, kind Kind) []string {
+	if len(item.Steps) == 0 && item.StepSize == 0 && item.StepInterval == "" {
+		return nil
+	}
+	var problems []string
+	switch {
+	case kind == KindCronJob || kind == KindJob || kind == KindKnativeService:
+		return []string{fmt.Sprintf("steps are not supported for %ss", strings.ToLower(kind.Label()))}
+	case item.Strategy == StrategySequential || item.Strategy == StrategyEvict:
+		problems = append(problems, fmt.Sprintf("steps cannot be combined with strategy %q", item.Strategy))
+	}
+	switch {
+	case len(item.Steps) > 0 && item.StepSize != 0:
+		problems = append(problems, "steps and stepSize cannot be combined")
+	case item.StepSize < 0:
+		problems = append(problems, "stepSize must be positive")
+	case len(item.Steps) == 0 && item.StepSize == 0:
+		problems = append(problems, "stepInterval requires steps or stepSize")
+	}
+	for i, replicas := range item.Steps {
+		if replicas < 0 {
+			problems = append(problems, "steps must not be negative")
+			break
+		}
+		if i > 0 && replicas >= item.Steps[i-1] {
+			problems = append(problems, "steps must be decreasing")
+			break
+		}
+	}
+	if item.StepInterval != "" {
+		if interval, err := time.ParseDuration(item.StepInterval); err != nil || interval < 0 {
+			problems = append(problems, fmt.Sprintf("invalid stepInterval %q", item.StepInterval))
+		}
+	}
+	return problems
+}
+
+// stepped reports whether a target is scaled down gradually, see
+// ResourceItem.Steps and ResourceItem.StepSize.
+func (e *execution) stepped(t Target) bool {
+	return e.mode == ModeScaleDown && (len(t.Item.Steps) > 0 || t.Item.StepSize > 0)
+}
+
+// rampSteps returns the replica counts of a gradual scale down from current
+// to target, ending with target.
+func rampSteps(item ResourceItem, current, target int32) []int32 {
+	var steps []int32
+	if item.StepSize > 0 {
+		for replicas := current - item.StepSize; replicas > target; replicas -= item.StepSize {
+			steps = append(steps, replicas)
+		}
+	} else {
+		for _, replicas := range item.Steps {
+			if replicas < current && replicas > target {
+				steps = append(steps, replicas)
+			}
+		}
+	}
+	return append(steps, target)
+}
+
+// scaleInSteps scales a workload down through the replica counts of
+// rampSteps, waiting for every step to be reached and then for the step
+// interval, so that connection pools and load balancers adapt to the lower
+// capacity instead of losing every pod at once.
+func (e *execution) scaleInSteps(ctx context.Context, t Target, w *workload, targetReplicas int32, res *Result) error {
+	var interval time.Duration
+	if t.Item.StepInterval != "" {
+		interval, _ = time.ParseDuration(t.Item.StepInterval)
+	}
+	steps := rampSteps(t.Item, w.replicas, targetReplicas)
+	current := w.replicas
+	for i, replicas := range steps {
+		changed, err := updateScale(ctx, e.opts.Retry, w.scales, t, replicas)
+		if err != nil {
+			return err
+		}
+		res.changed = res.changed || changed
+
+		e.emitReplicas(t, current, targetReplicas, "Step %d/%d: scaled to %d replicas. Watching for %d replicas...", i+1, len(steps), replicas, replicas)
+		if i == len(steps)-1 {
+			return e.waitForReplicas(ctx, t, w, replicas)
+		}
+		if err := e.awaitReplicas(ctx, t, w, replicas); err != nil {
+			return err
+		}
+		current = replicas
+		if interval > 0 {
+			e.emitReplicas(t, current, targetReplicas, "Step %d/%d reached. Waiting %s before the next step...", i+1, len(steps), interval)
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
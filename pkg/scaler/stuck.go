@@ -0,0 +1,64 @@
+package scaler
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stuckPodsInterval is how often the pods of a target are checked while
+// force deleting stuck pods.
+const stuckPodsInterval = 15 * time.Second
+
+// forceDeleteStuckPods deletes the pods of a target that have been
+// terminating for longer than Options.ForceDeleteStuckAfter, with a grace
+// period of zero, until ctx is done. Pods on lost nodes or with hanging
+// preStop hooks otherwise block the wait for the target replicas forever.
+func (e *execution) forceDeleteStuckPods(ctx context.Context, t Target, w *workload) {
+	threshold := e.opts.ForceDeleteStuckAfter
+	if threshold <= 0 || w.selector == "" {
+		return
+	}
+	ticker := time.NewTicker(min(stuckPodsInterval, threshold))
+	defer ticker.Stop()
+	pods := e.client.CoreV1().Pods(t.Item.Namespace)
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: w.selector})
+		if err != nil {
+			if ctx.Err() == nil {
+				e.emit(EventWarning, t, "Could not list pods to find stuck terminating pods: %v", err)
+			}
+			continue
+		}
+		for _, pod := range list.Items {
+			if pod.DeletionTimestamp == nil {
+				continue
+			}
+			stuck := time.Since(pod.DeletionTimestamp.Time)
+			if stuck < threshold {
+				continue
+			}
+			zero := int64(0)
+			err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{
+				GracePeriodSeconds: &zero,
+				Preconditions:      &metav1.Preconditions{UID: &pod.UID},
+			})
+			if err != nil {
+				e.emit(EventWarning, t, "Could not force delete pod %s, terminating for %s: %v", pod.Name, stuck.Round(time.Second), err)
+				continue
+			}
+			if len(pod.Finalizers) > 0 {
+				e.emit(EventWarning, t, "Force deleted pod %s, terminating for %s. It is only removed once its finalizers %v are done.", pod.Name, stuck.Round(time.Second), pod.Finalizers)
+				continue
+			}
+			e.emit(EventWarning, t, "Force deleted pod %s, terminating for %s.", pod.Name, stuck.Round(time.Second))
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package scaler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConfigError lists every problem found in a config.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("config has %d problems:\n- %s", len(e.Problems), strings.Join(e.Problems, "\n- "))
+}
+
+// configSections are the lists of a config, by their YAML key.
+var configSections = []struct {
+	key  string
+	kind Kind
+}{
+	{"deployments", KindDeployment},
+	{"statefulsets", KindStatefulSet},
+	{"cronjobs", KindCronJob},
+	{"jobs", KindJob},
+	{"replicationcontrollers", KindReplicationController},
+	{"deploymentconfigs", KindDeploymentConfig},
+	{"rollouts", KindRollout},
+	{"knativeservices", KindKnativeService},
+	{"custom", KindCustom},
+}
+
+func (c Config) section(kind Kind) []ResourceItem {
+	return *c.sectionRef(kind)
+}
+
+func (c *Config) sectionRef(kind Kind) *[]ResourceItem {
+	switch kind {
+	case KindDeployment:
+		return &c.Deployments
+	case KindStatefulSet:
+		return &c.StatefulSets
+	case KindCronJob:
+		return &c.CronJobs
+	case KindJob:
+		return &c.Jobs
+	case KindReplicationController:
+		return &c.ReplicationControllers
+	case KindDeploymentConfig:
+		return &c.DeploymentConfigs
+	case KindRollout:
+		return &c.Rollouts
+	case KindKnativeService:
+		return &c.KnativeServices
+	}
+	return &c.Custom
+}
+
+// itemKey identifies a named item within its section.
+func itemKey(item ResourceItem) string {
+	return strings.Join([]string{item.Context, item.Group, item.Kind, item.Namespace, item.Name}, "/")
+}
+
+// Validate checks the config before anything is resolved: required fields,
+// strategies, replicas, hooks and items listed twice. It returns a
+// *ConfigError with every problem found.
+func (c Config) Validate() error {
+	return c.validate(nil)
+}
+
+// validate checks the config. lines, if set, returns the line of an entry of
+// a section of the YAML document, or 0 if unknown.
+func (c Config) validate(lines func(section string, i int) int) error {
+	var problems []string
+	add := func(section string, i int, format string, args ...interface{}) {
+		where := section
+		if i >= 0 {
+			where = fmt.Sprintf("%s[%d]", section, i)
+		}
+		if lines != nil {
+			if line := lines(section, i); line > 0 {
+				where = fmt.Sprintf("line %d: %s", line, where)
+			}
+		}
+		problems = append(problems, where+": "+fmt.Sprintf(format, args...))
+	}
+
+	for _, hook := range []struct {
+		key  string
+		hook *Hook
+	}{{"preHook", c.PreHook}, {"postHook", c.PostHook}} {
+		if hook.hook != nil {
+			if err := hook.hook.validate(); err != nil {
+				add(hook.key, -1, "%v", err)
+			}
+		}
+	}
+
+	if d := c.Defaults; d != nil {
+		if d.Replicas != nil && d.Replicas.IsAbsolute() && d.Replicas.value < 0 {
+			add("defaults", -1, "replicas must not be negative")
+		}
+		for _, problem := range append(validateTimeout(d.Timeout), validateWaitFor(d.WaitFor, nil)...) {
+			add("defaults", -1, "%s", problem)
+		}
+	}
+
+	if c.MaxUnavailable != "" {
+		if _, err := ParseMaxUnavailable(c.MaxUnavailable, 1); err != nil {
+			add("maxUnavailable", -1, "%v", err)
+		}
+	}
+
+	type listed struct {
+		section string
+		i       int
+	}
+	seenItems := map[string]listed{}
+	check := func(section string, i int, kind Kind, item ResourceItem) {
+		for _, problem := range validateItem(item, kind) {
+			add(section, i, "%s", problem)
+		}
+		if item.Name == "" {
+			return
+		}
+		key := string(kind) + "/" + itemKey(item)
+		if first, ok := seenItems[key]; ok {
+			add(section, i, "%s is already listed at %s[%d]", itemDescription(item), first.section, first.i)
+			return
+		}
+		seenItems[key] = listed{section, i}
+	}
+	for _, s := range configSections {
+		for i, item := range c.section(s.kind) {
+			check(s.key, i, s.kind, item)
+		}
+	}
+	for i, item := range c.Resources {
+		if item.Kind == "" {
+			add("resources", i, "a kind is required, e.g. Deployment")
+			continue
+		}
+		kind, item := resourceKind(item)
+		check("resources", i, kind, item)
+	}
+
+	seen := map[string]int{}
+	for i, ns := range c.Namespaces {
+		if ns.Name == "" {
+			add("namespaces", i, "a name is required")
+			continue
+		}
+		if ns.Replicas != nil && ns.Replicas.IsAbsolute() && ns.Replicas.value < 0 {
+			add("namespaces", i, "replicas must not be negative")
+		}
+		for _, problem := range append(validateTimeout(ns.Timeout), validateWaitFor(ns.WaitFor, nil)...) {
+			add("namespaces", i, "%s", problem)
+		}
+		key := ns.Context + "/" + ns.Name
+		if first, ok := seen[key]; ok {
+			add("namespaces", i, "namespace %s is already listed at namespaces[%d]", ns.Name, first)
+			continue
+		}
+		seen[key] = i
+	}
+
+	nodes := map[string]int{}
+	for i, node := range c.Nodes {
+		switch {
+		case node.Name == "" && node.Selector == "":
+			add("nodes", i, "a name or a selector is required")
+			continue
+		case node.Name != "" && node.Selector != "":
+			add("nodes", i, "name cannot be combined with a selector")
+			continue
+		case node.Selector != "":
+			if _, err := labels.Parse(node.Selector); err != nil {
+				add("nodes", i, "invalid selector %q: %v", node.Selector, err)
+			}
+			continue
+		}
+		key := node.Context + "/" + node.Name
+		if first, ok := nodes[key]; ok {
+			add("nodes", i, "node %s is already listed at nodes[%d]", node.Name, first)
+			continue
+		}
+		nodes[key] = i
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// validateItem returns the problems of a single config item.
+func validateItem(item ResourceItem, kind Kind) []string {
+	var problems []string
+	hasSelector := len(item.Labels) > 0 || item.Selector != ""
+	switch {
+	case item.Name == "" && !hasSelector:
+		problems = append(problems, "a name, labels or a selector is required")
+	case item.Name != "" && hasSelector && !isNamePattern(item.Name):
+		problems = append(problems, "name cannot be combined with labels or a selector")
+	}
+	if isNamePattern(item.Name) {
+		if _, err := path.Match(item.Name, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid name pattern %q", item.Name))
+		}
+	}
+	if _, err := itemSelector(item); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if kind == KindCustom {
+		if item.Kind == "" || item.Version == "" {
+			problems = append(problems, "custom resources require kind and version")
+		}
+	} else if item.Group != "" || item.Version != "" || item.Kind != "" || item.ReplicasPath != "" || item.StatusReplicasPath != "" {
+		problems = append(problems, "group, version, kind and replica paths are only supported for custom resources")
+	}
+	if item.StatusReplicasPath != "" && item.ReplicasPath == "" {
+		problems = append(problems, "statusReplicasPath requires replicasPath")
+	}
+	for _, path := range []string{item.ReplicasPath, item.StatusReplicasPath} {
+		if _, err := parseFieldPath(path); path != "" && err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if item.FieldManager != "" {
+		switch {
+		case kind == KindCronJob || kind == KindJob || kind == KindKnativeService:
+			problems = append(problems, fmt.Sprintf("fieldManager is not supported for %ss", strings.ToLower(kind.Label())))
+		case len(item.FieldManager) > maxFieldManagerLength:
+			problems = append(problems, fmt.Sprintf("invalid fieldManager %q, must have at most %d characters", item.FieldManager, maxFieldManagerLength))
+		}
+	}
+	if item.Replicas != nil && item.Replicas.IsAbsolute() && item.Replicas.value < 0 {
+		problems = append(problems, "replicas must not be negative")
+	}
+	switch item.JobPolicy {
+	case "":
+	case JobPolicyWait, JobPolicySuspend, JobPolicyDelete:
+		if kind != KindJob {
+			problems = append(problems, "policy is only supported for jobs")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported policy %q, must be one of: wait, suspend, delete", item.JobPolicy))
+	}
+	if kind == KindJob && (item.Replicas != nil || item.Strategy != "") {
+		problems = append(problems, "replicas and strategy are not supported for jobs, use policy")
+	}
+	switch item.Strategy {
+	case "", StrategyParallel:
+	case StrategySequential:
+		if kind != KindStatefulSet {
+			problems = append(problems, fmt.Sprintf("strategy %q is only supported for statefulsets", item.Strategy))
+		}
+	case StrategyEvict:
+		if kind == KindCronJob || kind == KindKnativeService {
+			problems = append(problems, fmt.Sprintf("strategy %q is not supported for %ss", item.Strategy, strings.ToLower(kind.Label())))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported strategy %q, must be one of: parallel, sequential, evict", item.Strategy))
+	}
+	problems = append(problems, validateSteps(item, kind)...)
+	problems = append(problems, validateTimeout(item.Timeout)...)
+	problems = append(problems, validateWaitFor(item.WaitFor, item.Condition)...)
+	if item.WaitFor == WaitForCustom && (kind == KindCronJob || kind == KindJob || kind == KindKnativeService) {
+		problems = append(problems, fmt.Sprintf("waitFor custom is not supported for %ss", strings.ToLower(kind.Label())))
+	}
+	for _, hook := range []*Hook{item.PreHook, item.PostHook} {
+		if hook != nil {
+			if err := hook.validate(); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	if item.Quiesce != nil {
+		if kind == KindCronJob || kind == KindJob || kind == KindKnativeService {
+			problems = append(problems, fmt.Sprintf("quiesce is not supported for %ss", strings.ToLower(kind.Label())))
+		}
+		problems = append(problems, item.Quiesce.validate()...)
+	}
+	if item.MaintenancePage != nil {
+		if kind == KindCronJob || kind == KindJob || kind == KindKnativeService {
+			problems = append(problems, fmt.Sprintf("maintenancePage is not supported for %ss", strings.ToLower(kind.Label())))
+		}
+		problems = append(problems, item.MaintenancePage.validate()...)
+	}
+	if item.HealthCheck != nil {
+		problems = append(problems, item.HealthCheck.validate()...)
+	}
+	return problems
+}
+
+// entryLines returns the line of every entry of the sections of a YAML
+// config document, by section key. Top-level keys such as preHook are
+// recorded at index -1.
+func entryLines(doc *yaml.Node) map[string][]int {
+	lines := map[string][]int{}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return lines
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		if value.Kind != yaml.SequenceNode {
+			lines[key.Value] = []int{key.Line}
+			continue
+		}
+		for _, entry := range value.Content {
+			lines[key.Value] = append(lines[key.Value], entry.Line)
+		}
+	}
+	return lines
+}
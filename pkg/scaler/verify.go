@@ -0,0 +1,144 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultHealthCheckTimeout limits health checks that do not set a timeout.
+const DefaultHealthCheckTimeout = 10 * time.Second
+
+// HealthCheck is a GET request sent by Verify once a resource is restored,
+// expecting a 2xx response, e.g. to the health endpoint of its Service.
+type HealthCheck struct {
+	URL string `json:"url" yaml:"url"`
+	// Headers values expand environment variables, e.g. "Bearer ${TOKEN}".
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Timeout is a duration such as "5s". It defaults to
+	// DefaultHealthCheckTimeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+func (h *HealthCheck) validate() []string {
+	var problems []string
+	if u, err := url.Parse(h.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("invalid healthCheck url %q, must be an http(s) URL", h.URL))
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("invalid healthCheck timeout %q", h.Timeout))
+		}
+	}
+	return problems
+}
+
+// VerifyEntry is the outcome of Verify for a target: its replicas compared
+// with its restore target, its ready pods and its health check.
+type VerifyEntry struct {
+	PlanEntry
+	// ReadyPods counts the ready pods of the target. It is -1 when the pods
+	// of the target are not known, e.g. for CronJobs or custom resources
+	// without a selector.
+	ReadyPods int32
+	// HealthErr is the error of the HealthCheck of the target, if any.
+	HealthErr error
+}
+
+// Passed reports whether the target is back in service: at its restore
+// target, with as many ready pods, and healthy. Optional targets that do not
+// exist pass.
+func (v VerifyEntry) Passed() bool {
+	if v.Missing {
+		return true
+	}
+	return v.Err == nil &&
+		v.CurrentReplicas == v.TargetReplicas &&
+		(v.ReadyPods < 0 || v.ReadyPods >= v.TargetReplicas) &&
+		v.HealthErr == nil
+}
+
+// Verify checks that every target is back in service after a restore, see
+// VerifyEntry. The targets are checked in parallel.
+func (s *Scaler) Verify(ctx context.Context, targets []Target) []VerifyEntry {
+	plan := s.Plan(ctx, ModeRestore, targets)
+	entries := make([]VerifyEntry, len(plan))
+	var wg sync.WaitGroup
+	for i, p := range plan {
+		entries[i] = VerifyEntry{PlanEntry: p, ReadyPods: -1}
+		if p.Missing || p.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries[i].ReadyPods, entries[i].Err = s.readyPods(ctx, p.Target)
+			if check := p.Target.Item.HealthCheck; check != nil {
+				entries[i].HealthErr = checkHealth(ctx, check)
+			}
+		}()
+	}
+	wg.Wait()
+	return entries
+}
+
+// readyPods counts the ready pods of a workload that are not terminating, or
+// returns -1 if its pods are not known.
+func (s *Scaler) readyPods(ctx context.Context, t Target) (int32, error) {
+	if t.Kind == KindCronJob || t.Kind == KindJob || t.Kind == KindKnativeService {
+		return -1, nil
+	}
+	w, err := s.getWorkload(ctx, t)
+	if err != nil {
+		return -1, err
+	}
+	if w.selector == "" {
+		return -1, nil
+	}
+	pods, err := s.client.CoreV1().Pods(t.Item.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.selector})
+	if err != nil {
+		return -1, fmt.Errorf("failed to list pods: %w", err)
+	}
+	var ready int32
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil && podReady(pod) {
+			ready++
+		}
+	}
+	return ready, nil
+}
+
+// checkHealth sends the request of a health check and expects a 2xx
+// response.
+func checkHealth(ctx context.Context, h *HealthCheck) error {
+	timeout := DefaultHealthCheckTimeout
+	if h.Timeout != "" {
+		timeout, _ = time.ParseDuration(h.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range h.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		output, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return withOutput(fmt.Errorf("unexpected status %s", resp.Status), output)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// waitForVolumesDetached polls the VolumeAttachments of the cluster until
+// none is left for the volumes bound to the claims of the StatefulSet pods
+// with an ordinal at or above targetReplicas, i.e. the pods removed by the
+// scale down. The claims are found by name rather than from the removed
+// pods, so that a run retried after the pods are gone still waits for them.
+func (e *execution) waitForVolumesDetached(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	obj, err := w.object(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get statefulset: %w", err)
+	}
+	sts, ok := obj.(*appsv1.StatefulSet)
+	if !ok || len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return nil
+	}
+	volumes, err := e.removedVolumes(ctx, sts, targetReplicas)
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	poll := e.poller()
+	last := -1
+	for {
+		attachments, err := e.client.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if err := e.retry(ctx, t, poll, err); err != nil {
+				return err
+			}
+			continue
+		}
+		attached := 0
+		for _, a := range attachments.Items {
+			if pv := a.Spec.Source.PersistentVolumeName; pv != nil && volumes[*pv] {
+				attached++
+			}
+		}
+		if attached == 0 {
+			e.emit(EventProgress, t, "Volumes of the removed pods detached.")
+			return nil
+		}
+		progressed := attached != last
+		if progressed {
+			e.emit(EventProgress, t, "Waiting for volumes to be detached... Volumes attached: %d", attached)
+			last = attached
+		}
+
+		if err := poll.wait(ctx, progressed); err != nil {
+			return err
+		}
+	}
+}
+
+// removedVolumes returns the PersistentVolumes bound to the claims of the
+// pods of a StatefulSet with an ordinal at or above targetReplicas. The
+// claims of a pod are named <template>-<statefulset>-<ordinal>.
+func (e *execution) removedVolumes(ctx context.Context, sts *appsv1.StatefulSet, targetReplicas int32) (map[string]bool, error) {
+	var claims *corev1.PersistentVolumeClaimList
+	if err := withRetry(ctx, e.opts.Retry, func() error {
+		var err error
+		claims, err = e.client.CoreV1().PersistentVolumeClaims(sts.Namespace).List(ctx, metav1.ListOptions{})
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list persistentvolumeclaims: %w", err)
+	}
+	first := targetReplicas
+	if sts.Spec.Ordinals != nil {
+		first += sts.Spec.Ordinals.Start
+	}
+	volumes := map[string]bool{}
+	for _, claim := range claims.Items {
+		if claim.Spec.VolumeName == "" {
+			continue
+		}
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			suffix, ok := strings.CutPrefix(claim.Name, template.Name+"-"+sts.Name+"-")
+			if !ok {
+				continue
+			}
+			if ordinal, err := strconv.Atoi(suffix); err == nil && int32(ordinal) >= first {
+				volumes[claim.Spec.VolumeName] = true
+			}
+		}
+	}
+	return volumes, nil
+}
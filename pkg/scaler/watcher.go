@@ -0,0 +1,201 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSyncTimeout limits the initial list of an informer, which never
+// completes without the permission to list the resources.
+const cacheSyncTimeout = time.Minute
+
+// statusWatcher waits for resources to reach their target replicas using one
+// shared informer per namespace and kind instead of polling every resource.
+type statusWatcher struct {
+	client kubernetes.Interface
+	stopCh chan struct{}
+
+	mu        sync.Mutex
+	factories map[string]informers.SharedInformerFactory
+}
+
+func newStatusWatcher(client kubernetes.Interface) *statusWatcher {
+	return &statusWatcher{
+		client:    client,
+		stopCh:    make(chan struct{}),
+		factories: map[string]informers.SharedInformerFactory{},
+	}
+}
+
+func (w *statusWatcher) stop() {
+	close(w.stopCh)
+}
+
+func (w *statusWatcher) informerFor(kind Kind, namespace string) (cache.SharedIndexInformer, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	factory, ok := w.factories[namespace]
+	if !ok {
+		factory = informers.NewSharedInformerFactoryWithOptions(w.client, 0, informers.WithNamespace(namespace))
+		w.factories[namespace] = factory
+	}
+
+	var informer cache.SharedIndexInformer
+	switch kind {
+	case KindDeployment:
+		informer = factory.Apps().V1().Deployments().Informer()
+	case KindStatefulSet:
+		informer = factory.Apps().V1().StatefulSets().Informer()
+	case KindReplicationController:
+		informer = factory.Core().V1().ReplicationControllers().Informer()
+	default:
+		return nil, fmt.Errorf("unsupported kind: %s", kind)
+	}
+
+	// Start is a no-op for informers that are already running.
+	factory.Start(w.stopCh)
+	return informer, nil
+}
+
+// replicaStatus is the status of a Deployment, StatefulSet or
+// ReplicationController. updated is
+// only compared for StatefulSets.
+type replicaStatus struct {
+	replicas, ready, available, updated int32
+	statefulSet                         bool
+	// observed is false until the controller has seen the latest spec.
+	observed bool
+}
+
+func statusOf(obj interface{}) (replicaStatus, bool) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return replicaStatus{
+			replicas:  o.Status.Replicas,
+			ready:     o.Status.ReadyReplicas,
+			available: o.Status.AvailableReplicas,
+			observed:  o.Status.ObservedGeneration >= o.Generation,
+		}, true
+	case *corev1.ReplicationController:
+		return replicaStatus{
+			replicas:  o.Status.Replicas,
+			ready:     o.Status.ReadyReplicas,
+			available: o.Status.AvailableReplicas,
+			observed:  o.Status.ObservedGeneration >= o.Generation,
+		}, true
+	case *appsv1.StatefulSet:
+		return replicaStatus{
+			replicas:    o.Status.Replicas,
+			ready:       o.Status.ReadyReplicas,
+			available:   o.Status.AvailableReplicas,
+			updated:     o.Status.UpdatedReplicas,
+			statefulSet: true,
+			observed:    o.Status.ObservedGeneration >= o.Generation,
+		}, true
+	}
+	return replicaStatus{}, false
+}
+
+// reached reports whether the status matches the target replicas.
+func (s replicaStatus) reached(target int32, waitFor WaitFor) bool {
+	if s.replicas != target {
+		return false
+	}
+	if waitFor != WaitForReady {
+		return true
+	}
+	return s.observed && s.ready == target && s.available == target &&
+		(!s.statefulSet || s.updated == target)
+}
+
+// progress describes the status while waiting for the target replicas.
+func (s replicaStatus) progress(kind Kind, target int32, waitFor WaitFor) string {
+	if waitFor != WaitForReady {
+		return fmt.Sprintf("Waiting for %s scale... Current replicas: %d", kind, s.replicas)
+	}
+	msg := fmt.Sprintf("Waiting for %s to be ready... Replicas: %d, ready: %d/%d, available: %d/%d", kind, s.replicas, s.ready, target, s.available, target)
+	if s.statefulSet {
+		msg += fmt.Sprintf(", updated: %d/%d", s.updated, target)
+	}
+	return msg
+}
+
+func (e *execution) waitForInformer(ctx context.Context, t Target, targetReplicas int32) error {
+	r := t.Item
+	informer, err := e.watcher.informerFor(t.Kind, r.Namespace)
+	if err != nil {
+		return err
+	}
+
+	updates := make(chan struct{}, 1)
+	notify := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if o, ok := obj.(metav1.Object); ok && o.GetName() == r.Name {
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, obj interface{}) { notify(obj) },
+		DeleteFunc: notify,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = informer.RemoveEventHandler(registration) }()
+
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	synced := cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced)
+	cancel()
+	if !synced {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s cache did not sync within %s, check the permissions to list and watch %ss", t.Kind, cacheSyncTimeout, strings.ToLower(t.Kind.Label()))
+	}
+
+	var lastProgress string
+	for {
+		obj, exists, err := informer.GetStore().GetByKey(r.Namespace + "/" + r.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%s was deleted while waiting for scale", t.Kind)
+		}
+
+		status, ok := statusOf(obj)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T", obj)
+		}
+		if status.reached(targetReplicas, e.waitFor(t)) {
+			return nil
+		}
+		if progress := status.progress(t.Kind, targetReplicas, e.waitFor(t)); progress != lastProgress {
+			e.emitReplicas(t, status.replicas, targetReplicas, "%s", progress)
+			lastProgress = progress
+		}
+
+		select {
+		case <-updates:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package scaler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// matchesRef reports whether a dependsOn reference ("name" or
+// "namespace/name") refers to the target.
+func matchesRef(t Target, ref string) bool {
+	if cluster, rest, ok := strings.Cut(ref, ":"); ok {
+		if t.Cluster != cluster {
+			return false
+		}
+		ref = rest
+	}
+	if namespace, name, ok := strings.Cut(ref, "/"); ok {
+		return t.Item.Namespace == namespace && t.Item.Name == name
+	}
+	return t.Item.Name == ref
+}
+
+// assignWaves sets the effective wave of every target. A target runs in its
+// configured wave, but never before every target that depends on it.
+func assignWaves(targets []Target) error {
+	// before[i] lists the targets that must be done before target i.
+	before := make([][]int, len(targets))
+	for i, t := range targets {
+		for _, ref := range t.Item.DependsOn {
+			found := false
+			for j, dep := range targets {
+				if j == i || !matchesRef(dep, ref) {
+					continue
+				}
+				before[j] = append(before[j], i)
+				found = true
+			}
+			if !found {
+				return fmt.Errorf("%s %s/%s depends on %q, which does not match any resource", t.Label(), t.Item.Namespace, t.Item.Name, ref)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	visited := make([]int, len(targets))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch visited[i] {
+		case done:
+			return nil
+		case visiting:
+			t := targets[i]
+			return fmt.Errorf("dependency cycle involving %s %s/%s", t.Label(), t.Item.Namespace, t.Item.Name)
+		}
+		visited[i] = visiting
+		wave := targets[i].Item.Wave
+		for _, j := range before[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+			if targets[j].Wave+1 > wave {
+				wave = targets[j].Wave + 1
+			}
+		}
+		targets[i].Wave = wave
+		visited[i] = done
+		return nil
+	}
+	for i := range targets {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waves groups target indices by wave, in the order the waves run.
+func waves(targets []Target) [][]int {
+	byWave := map[int][]int{}
+	var numbers []int
+	for i, t := range targets {
+		if _, ok := byWave[t.Wave]; !ok {
+			numbers = append(numbers, t.Wave)
+		}
+		byWave[t.Wave] = append(byWave[t.Wave], i)
+	}
+	sort.Ints(numbers)
+
+	var result [][]int
+	for _, n := range numbers {
+		result = append(result, byWave[n])
+	}
+	return result
+}
+
+// ReversesWaves reports whether the waves of a run in the given mode and
+// restore order run in descending order, see Options.RestoreOrder.
+func ReversesWaves(mode Mode, order RestoreOrder) bool {
+	return mode == ModeRestore && order != RestoreOrderForward
+}
+
+// pausesAfter reports whether a wave waits for an approval once done.
+func pausesAfter(targets []Target, wave []int) bool {
+	for _, i := range wave {
+		if targets[i].Item.PauseAfter {
+			return true
+		}
+	}
+	return false
+}
+
+// HasWaves reports whether the targets run in more than one wave.
+func HasWaves(targets []Target) bool {
+	return len(waves(targets)) > 1
+}
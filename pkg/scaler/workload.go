@@ -0,0 +1,534 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	autoscalingv1apply "k8s.io/client-go/applyconfigurations/autoscaling/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// scaleClient reads the scale subresource of a workload, and sets its
+// replicas with server-side apply so that the field manager only takes
+// ownership of the replicas, see updateScale.
+type scaleClient interface {
+	GetScale(ctx context.Context, name string, options metav1.GetOptions) (*autoscalingv1.Scale, error)
+	ApplyScale(ctx context.Context, name string, scale *autoscalingv1apply.ScaleApplyConfiguration, opts metav1.ApplyOptions) (*autoscalingv1.Scale, error)
+}
+
+// replicationControllerScales adapts a ReplicationController client to
+// scaleClient: ReplicationControllers have a scale subresource but no
+// ApplyScale, so their replicas are applied on the resource itself.
+type replicationControllerScales struct {
+	corev1client.ReplicationControllerInterface
+	namespace string
+}
+
+func (c replicationControllerScales) ApplyScale(ctx context.Context, name string, scale *autoscalingv1apply.ScaleApplyConfiguration, opts metav1.ApplyOptions) (*autoscalingv1.Scale, error) {
+	rc := corev1apply.ReplicationController(name, c.namespace).WithSpec(corev1apply.ReplicationControllerSpec())
+	if scale.Spec != nil && scale.Spec.Replicas != nil {
+		rc.Spec.WithReplicas(*scale.Spec.Replicas)
+	}
+	applied, err := c.Apply(ctx, rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Name: applied.Name, Namespace: applied.Namespace, ResourceVersion: applied.ResourceVersion},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: specReplicas(applied.Spec.Replicas)},
+		Status:     autoscalingv1.ScaleStatus{Replicas: applied.Status.Replicas},
+	}, nil
+}
+
+// workload is the view of a scalable resource shared by Deployments,
+// StatefulSets and custom resources.
+type workload struct {
+	labels      map[string]string
+	annotations map[string]string
+	replicas    int32
+	scales      scaleClient
+	patch       func(ctx context.Context, data []byte) error
+	// ref references the resource in Kubernetes Events.
+	ref corev1.ObjectReference
+	// selector selects the pods of the resource. It may be empty for
+	// custom resources.
+	selector string
+	// podLabels are the labels of the pod template, or nil if unknown.
+	podLabels map[string]string
+	// managedFields tell which field managers set the fields of the
+	// resource.
+	managedFields []metav1.ManagedFieldsEntry
+	// object reads the resource again, for WaitForCustom.
+	object func(ctx context.Context) (runtime.Object, error)
+}
+
+func podSelector(selector *metav1.LabelSelector) string {
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+func objectRef(apiVersion, kind string, meta metav1.Object) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion:      apiVersion,
+		Kind:            kind,
+		Namespace:       meta.GetNamespace(),
+		Name:            meta.GetName(),
+		UID:             meta.GetUID(),
+		ResourceVersion: meta.GetResourceVersion(),
+	}
+}
+
+// specReplicas returns the replicas of a workload spec. They are only unset
+// if the API server did not default them, in which case the controller runs
+// the default of 1.
+func specReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func (s *Scaler) getWorkload(ctx context.Context, t Target) (*workload, error) {
+	r := t.Item
+	switch t.Kind {
+	case KindDeployment:
+		client := s.client.AppsV1().Deployments(r.Namespace)
+		d, err := client.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload{
+			labels:        d.Labels,
+			annotations:   d.Annotations,
+			replicas:      specReplicas(d.Spec.Replicas),
+			scales:        client,
+			ref:           objectRef("apps/v1", "Deployment", d),
+			selector:      podSelector(d.Spec.Selector),
+			podLabels:     d.Spec.Template.Labels,
+			managedFields: d.ManagedFields,
+			object: func(ctx context.Context) (runtime.Object, error) {
+				return client.Get(ctx, r.Name, metav1.GetOptions{})
+			},
+			patch: func(ctx context.Context, data []byte) error {
+				_, err := client.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+				return err
+			},
+		}, nil
+	case KindStatefulSet:
+		client := s.client.AppsV1().StatefulSets(r.Namespace)
+		sts, err := client.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload{
+			labels:        sts.Labels,
+			annotations:   sts.Annotations,
+			replicas:      specReplicas(sts.Spec.Replicas),
+			scales:        client,
+			ref:           objectRef("apps/v1", "StatefulSet", sts),
+			selector:      podSelector(sts.Spec.Selector),
+			podLabels:     sts.Spec.Template.Labels,
+			managedFields: sts.ManagedFields,
+			object: func(ctx context.Context) (runtime.Object, error) {
+				return client.Get(ctx, r.Name, metav1.GetOptions{})
+			},
+			patch: func(ctx context.Context, data []byte) error {
+				_, err := client.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+				return err
+			},
+		}, nil
+	case KindReplicationController:
+		client := s.client.CoreV1().ReplicationControllers(r.Namespace)
+		rc, err := client.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var podLabels map[string]string
+		if rc.Spec.Template != nil {
+			podLabels = rc.Spec.Template.Labels
+		}
+		return &workload{
+			labels:        rc.Labels,
+			annotations:   rc.Annotations,
+			replicas:      specReplicas(rc.Spec.Replicas),
+			scales:        replicationControllerScales{client, r.Namespace},
+			ref:           objectRef("v1", "ReplicationController", rc),
+			selector:      labels.SelectorFromSet(rc.Spec.Selector).String(),
+			podLabels:     podLabels,
+			managedFields: rc.ManagedFields,
+			object: func(ctx context.Context) (runtime.Object, error) {
+				return client.Get(ctx, r.Name, metav1.GetOptions{})
+			},
+			patch: func(ctx context.Context, data []byte) error {
+				_, err := client.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+				return err
+			},
+		}, nil
+	case KindDeploymentConfig, KindRollout, KindCustom:
+		resource, err := s.custom.resourceFor(r)
+		if t.Kind != KindCustom {
+			resource, err = s.custom.kindResource(t.Kind, r.Namespace)
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj, err := resource.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var scales scaleClient = dynamicScaleClient{resource: resource}
+		if r.ReplicasPath != "" {
+			if scales, err = newPathScaleClient(resource, obj, r); err != nil {
+				return nil, err
+			}
+		}
+		scale, err := scales.GetScale(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		// DeploymentConfigs, Rollouts and most scalable custom resources
+		// embed a pod template.
+		podLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		return &workload{
+			labels:        obj.GetLabels(),
+			annotations:   obj.GetAnnotations(),
+			replicas:      scale.Spec.Replicas,
+			scales:        scales,
+			ref:           objectRef(obj.GetAPIVersion(), obj.GetKind(), obj),
+			selector:      scale.Status.Selector,
+			podLabels:     podLabels,
+			managedFields: obj.GetManagedFields(),
+			object: func(ctx context.Context) (runtime.Object, error) {
+				return resource.Get(ctx, r.Name, metav1.GetOptions{})
+			},
+			patch: func(ctx context.Context, data []byte) error {
+				_, err := resource.Patch(ctx, r.Name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+				return err
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported kind: %s", t.Kind)
+}
+
+func (s *Scaler) targetReplicas(t Target, mode Mode, current int32, annotations map[string]string) (int32, error) {
+	if t.Item.Replicas != nil {
+		// Relative targets use the count from before the maintenance, so
+		// that a resumed or repeated run does not scale down further.
+		return t.Item.Replicas.Resolve(originalReplicas(annotations, current)), nil
+	}
+	if mode == ModeScaleDown {
+		return 0, nil
+	}
+	if replicas, ok := s.state.lookup(t); ok {
+		return replicas, nil
+	}
+	if replicas, ok := annotatedReplicas(annotations); ok {
+		return replicas, nil
+	}
+	return 0, fmt.Errorf("replicas not set and no saved original replica count found")
+}
+
+func annotationPatch(key string, value interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{key: value},
+		},
+	})
+}
+
+// originalReplicasPatch builds a metadata-only merge patch that records the
+// pre-maintenance replica count on scale down and clears it on restore. It
+// returns nil when the annotation does not need to change.
+func originalReplicasPatch(annotations map[string]string, mode Mode, current, target int32) ([]byte, error) {
+	_, exists := annotations[OriginalReplicasAnnotation]
+
+	var value interface{}
+	switch {
+	case mode == ModeRestore && exists:
+		value = nil
+	case mode == ModeScaleDown && !exists && current != target:
+		value = strconv.Itoa(int(current))
+	default:
+		return nil, nil
+	}
+
+	return annotationPatch(OriginalReplicasAnnotation, value)
+}
+
+// updateScale applies the replica count through the scale subresource with
+// the field manager of the item, see ResourceItem.FieldManager, and reports
+// whether a change was sent. Only the replicas are applied, so changes of
+// other controllers to the rest of the resource never conflict, and the
+// ownership of the replicas is forced from their previous manager, e.g. an
+// HPA or kubectl apply.
+func updateScale(ctx context.Context, backoff wait.Backoff, client scaleClient, t Target, targetReplicas int32) (bool, error) {
+	var changed bool
+	err := withRetry(ctx, backoff, func() error {
+		scale, err := client.GetScale(ctx, t.Item.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if scale.Spec.Replicas == targetReplicas {
+			changed = false
+			return nil
+		}
+
+		_, err = client.ApplyScale(ctx, t.Item.Name, scaleApply(targetReplicas), applyOptions(t.Item))
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
+
+// scaleApply is the apply configuration of a scale subresource setting its
+// replicas.
+func scaleApply(replicas int32) *autoscalingv1apply.ScaleApplyConfiguration {
+	return autoscalingv1apply.Scale().WithSpec(autoscalingv1apply.ScaleSpec().WithReplicas(replicas))
+}
+
+// applyOptions forces the replicas of an item to its field manager.
+func applyOptions(item ResourceItem) metav1.ApplyOptions {
+	return metav1.ApplyOptions{FieldManager: item.replicasManager(), Force: true}
+}
+
+func (e *execution) scaleWorkload(ctx context.Context, t Target, res *Result) error {
+	w, err := e.getWorkload(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	targetReplicas, err := e.targetReplicas(t, e.mode, w.replicas, w.annotations)
+	if err != nil {
+		return err
+	}
+	// A retry keeps the replicas read before its first attempt changed them.
+	if !res.changed {
+		res.PreviousReplicas = w.replicas
+	}
+	res.TargetReplicas = targetReplicas
+
+	scaleUp := e.mode == ModeScaleDown && targetReplicas > w.replicas
+	if scaleUp {
+		// A capacity bump before the maintenance is only useful once its
+		// new pods serve, so it is waited for until ready unless the item
+		// says otherwise.
+		e.emitReplicas(t, w.replicas, targetReplicas, "Scaling up from %d to %d replicas.", w.replicas, targetReplicas)
+		if t.Item.WaitFor == "" {
+			t.Item.WaitFor = WaitForReady
+		}
+	}
+
+	if e.mode == ModeScaleDown {
+		e.state.recordIfMissing(t, originalReplicas(w.annotations, w.replicas))
+		if e.opts.PDBPolicy != PDBPolicyIgnore {
+			for _, v := range e.pdbs.violations(t, w, targetReplicas) {
+				e.emit(EventWarning, t, "%s.", v)
+			}
+		}
+	}
+
+	patch, err := originalReplicasPatch(w.annotations, e.mode, w.replicas, targetReplicas)
+	if err != nil {
+		return err
+	}
+	patchAnnotations := func() error {
+		if patch == nil {
+			return nil
+		}
+		return withRetry(ctx, e.opts.Retry, func() error { return w.patch(ctx, patch) })
+	}
+
+	if e.mode == ModeScaleDown {
+		if err := e.pauseGitOps(ctx, t, w.labels, w.annotations); err != nil {
+			return err
+		}
+		res.changed = patch != nil || len(e.hpas.forTarget(t)) > 0
+		if err := e.pauseHPAs(ctx, t, w, e.hpas.forTarget(t)); err != nil {
+			return err
+		}
+		// The original replicas of a scale up are recorded once it is
+		// sent, since the maintenance webhook denies raising the replicas
+		// of an annotated resource.
+		if !scaleUp {
+			if err := patchAnnotations(); err != nil {
+				return err
+			}
+		}
+		marked, err := e.applyMarks(ctx, w.annotations, w.patch)
+		if err != nil {
+			return err
+		}
+		res.changed = res.changed || marked
+		// Clients get the maintenance page before the pods go away.
+		if err := e.showMaintenancePage(ctx, t, targetReplicas); err != nil {
+			return err
+		}
+	}
+
+	if err := e.quiesce(ctx, t, w, targetReplicas); err != nil {
+		return err
+	}
+
+	sent := time.Now()
+	if e.stepped(t) && w.replicas > targetReplicas {
+		res.Status = StatusScaled
+		e.recordEvent(ctx, t, w, w.replicas, targetReplicas)
+		err := e.whileForceDeleting(ctx, t, w, func(ctx context.Context) error {
+			return e.scaleInSteps(ctx, t, w, targetReplicas, res)
+		})
+		if err == nil {
+			err = e.awaitCompletion(ctx, t, w, targetReplicas)
+		}
+		res.ScaleDuration = time.Since(sent)
+		return err
+	}
+
+	if e.sequential(t) && w.replicas > targetReplicas {
+		res.Status = StatusScaled
+		e.recordEvent(ctx, t, w, w.replicas, targetReplicas)
+		err := e.whileForceDeleting(ctx, t, w, func(ctx context.Context) error {
+			return e.scaleSequentially(ctx, t, w, targetReplicas, res)
+		})
+		if err == nil {
+			err = e.awaitCompletion(ctx, t, w, targetReplicas)
+		}
+		res.ScaleDuration = time.Since(sent)
+		return err
+	}
+
+	if e.evicting(t) && w.replicas > targetReplicas {
+		res.Status = StatusScaled
+		e.recordEvent(ctx, t, w, w.replicas, targetReplicas)
+		err := e.whileForceDeleting(ctx, t, w, func(ctx context.Context) error {
+			return e.scaleByEviction(ctx, t, w, targetReplicas, res)
+		})
+		if err == nil {
+			err = e.awaitCompletion(ctx, t, w, targetReplicas)
+		}
+		res.ScaleDuration = time.Since(sent)
+		return err
+	}
+
+	changed, err := updateScale(ctx, e.opts.Retry, w.scales, t, targetReplicas)
+	if err != nil {
+		return err
+	}
+	res.changed = res.changed || changed
+
+	if scaleUp {
+		if err := patchAnnotations(); err != nil {
+			return err
+		}
+	}
+	if e.mode == ModeRestore {
+		if err := patchAnnotations(); err != nil {
+			return err
+		}
+		if _, err := e.applyMarks(ctx, w.annotations, w.patch); err != nil {
+			return err
+		}
+	}
+
+	res.Status = StatusUnchanged
+	if changed {
+		res.Status = StatusScaled
+		e.recordEvent(ctx, t, w, w.replicas, targetReplicas)
+		e.emitReplicas(t, w.replicas, targetReplicas, "Scale command sent. Watching for %d replicas...", targetReplicas)
+		err := e.whileForceDeleting(ctx, t, w, func(ctx context.Context) error {
+			return e.waitForReplicas(ctx, t, w, targetReplicas)
+		})
+		if err == nil {
+			err = e.awaitCompletion(ctx, t, w, targetReplicas)
+		}
+		res.ScaleDuration = time.Since(sent)
+		if err != nil {
+			return err
+		}
+	} else if e.waitFor(t) == WaitForReady && (!polled(t.Kind) || t.Kind == KindRollout) {
+		// The resource may be at its target without its pods serving yet,
+		// e.g. when a previous run was interrupted.
+		if err := e.waitForReplicas(ctx, t, w, targetReplicas); err != nil {
+			return err
+		}
+	} else {
+		e.emit(EventCompleted, t, "Already at %d replicas.", targetReplicas)
+		// Endpoints may still be ready, or pods terminating, if a previous
+		// run was interrupted right after its scale down.
+		if err := e.awaitCompletion(ctx, t, w, targetReplicas); err != nil {
+			return err
+		}
+	}
+
+	if e.mode == ModeRestore {
+		if err := e.hideMaintenancePage(ctx, t); err != nil {
+			return err
+		}
+		if err := e.resumeHPAs(ctx, t, w); err != nil {
+			return err
+		}
+		return e.resumeGitOps(ctx, t, w.labels, w.annotations)
+	}
+	return nil
+}
+
+// whileForceDeleting runs wait while force deleting the stuck terminating
+// pods of the target, see Options.ForceDeleteStuckAfter.
+func (e *execution) whileForceDeleting(ctx context.Context, t Target, w *workload, wait func(context.Context) error) error {
+	if e.opts.ForceDeleteStuckAfter <= 0 {
+		return wait(ctx)
+	}
+	reapCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.forceDeleteStuckPods(reapCtx, t, w)
+	}()
+	err := wait(ctx)
+	cancel()
+	<-done
+	return err
+}
+
+// polled reports whether a kind is waited for through its scale subresource
+// instead of an informer.
+func polled(kind Kind) bool {
+	return kind == KindCustom || kind == KindDeploymentConfig || kind == KindRollout
+}
+
+// waitForReplicas waits for a workload to reach its target replicas and
+// reports it complete.
+func (e *execution) waitForReplicas(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	if err := e.awaitReplicas(ctx, t, w, targetReplicas); err != nil {
+		return err
+	}
+	e.emit(EventCompleted, t, "Scale complete.")
+	return nil
+}
+
+// awaitReplicas waits for a workload to reach a replica count, either its
+// target or an intermediate step.
+func (e *execution) awaitReplicas(ctx context.Context, t Target, w *workload, targetReplicas int32) error {
+	if t.Kind == KindRollout {
+		return e.waitForRollout(ctx, t, targetReplicas)
+	}
+	if polled(t.Kind) {
+		return e.waitForScaleSubresource(ctx, t, w.scales, targetReplicas)
+	}
+	return e.waitForInformer(ctx, t, targetReplicas)
+}
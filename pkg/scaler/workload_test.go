@@ -0,0 +1,173 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestSpecReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas *int32
+		want     int32
+	}{
+		{name: "unset", replicas: nil, want: 1},
+		{name: "zero", replicas: ptr.To[int32](0), want: 0},
+		{name: "set", replicas: ptr.To[int32](3), want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := specReplicas(tt.replicas); got != tt.want {
+				t.Errorf("specReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// deploymentScales serves the scale subresource of the Deployments of a fake
+// clientset, which does not implement it, from their spec.replicas.
+func deploymentScales(t *testing.T, client *fake.Clientset) {
+	t.Helper()
+	gvr := appsv1.SchemeGroupVersion.WithResource("deployments")
+	get := func(namespace, name string) (*appsv1.Deployment, error) {
+		obj, err := client.Tracker().Get(gvr, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.(*appsv1.Deployment), nil
+	}
+	client.PrependReactor("get", "deployments", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		if a.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		d, err := get(a.GetNamespace(), a.(k8stesting.GetAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		// The API server reports the defaulted replicas, never an unset field.
+		return true, &autoscalingv1.Scale{
+			ObjectMeta: metav1.ObjectMeta{Name: d.Name, Namespace: d.Namespace},
+			Spec:       autoscalingv1.ScaleSpec{Replicas: specReplicas(d.Spec.Replicas)},
+		}, nil
+	})
+	client.PrependReactor("patch", "deployments", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		if a.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		patch := a.(k8stesting.PatchAction)
+		var scale autoscalingv1.Scale
+		if err := json.Unmarshal(patch.GetPatch(), &scale); err != nil {
+			return true, nil, err
+		}
+		d, err := get(a.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		d = d.DeepCopy()
+		d.Spec.Replicas = ptr.To(scale.Spec.Replicas)
+		if err := client.Tracker().Update(gvr, d, d.Namespace); err != nil {
+			return true, nil, err
+		}
+		return true, &scale, nil
+	})
+}
+
+func TestUnsetDeploymentReplicas(t *testing.T) {
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+	})
+	deploymentScales(t, client)
+	s := New(client, Options{})
+	target := Target{Kind: KindDeployment, Item: ResourceItem{Name: "web", Namespace: "shop"}}
+	ctx := context.Background()
+
+	w, err := s.getWorkload(ctx, target)
+	if err != nil {
+		t.Fatalf("getWorkload() error = %v", err)
+	}
+	if w.replicas != 1 {
+		t.Errorf("getWorkload() replicas = %d, want the default of 1", w.replicas)
+	}
+
+	changed, err := updateScale(ctx, s.opts.Retry, w.scales, target, 1)
+	if err != nil || changed {
+		t.Errorf("updateScale() to 1 = %v, %v, want no change", changed, err)
+	}
+	changed, err = updateScale(ctx, s.opts.Retry, w.scales, target, 0)
+	if err != nil || !changed {
+		t.Fatalf("updateScale() to 0 = %v, %v, want a change", changed, err)
+	}
+	d, err := client.AppsV1().Deployments("shop").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Replicas == nil || *d.Spec.Replicas != 0 {
+		t.Errorf("spec.replicas = %v, want 0", d.Spec.Replicas)
+	}
+}
+
+// TestScaleUpBeforeOriginalReplicasAnnotation scales a Deployment up during
+// a scale down, against a cluster that denies raising the replicas of a
+// resource annotated with its original replicas, like the maintenance
+// webhook. The annotation must only be written once the scale up is sent.
+func TestScaleUpBeforeOriginalReplicasAnnotation(t *testing.T) {
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+		Status:     appsv1.DeploymentStatus{Replicas: 3},
+	})
+	deploymentScales(t, client)
+	gvr := appsv1.SchemeGroupVersion.WithResource("deployments")
+	client.PrependReactor("patch", "deployments", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		if a.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		obj, err := client.Tracker().Get(gvr, a.GetNamespace(), "web")
+		if err != nil {
+			return true, nil, err
+		}
+		d := obj.(*appsv1.Deployment).DeepCopy()
+		var scale autoscalingv1.Scale
+		if err := json.Unmarshal(a.(k8stesting.PatchAction).GetPatch(), &scale); err != nil {
+			return true, nil, err
+		}
+		if _, ok := d.Annotations[OriginalReplicasAnnotation]; ok && scale.Spec.Replicas > specReplicas(d.Spec.Replicas) {
+			return true, nil, apierrors.NewForbidden(gvr.GroupResource(), "web", errors.New("scaled down for a maintenance"))
+		}
+		// The new pods start at once.
+		d.Spec.Replicas = ptr.To(scale.Spec.Replicas)
+		d.Status.Replicas = scale.Spec.Replicas
+		if err := client.Tracker().Update(gvr, d, d.Namespace); err != nil {
+			return true, nil, err
+		}
+		return true, &scale, nil
+	})
+	s := New(client, Options{SkipPreflight: true, Timeout: 5 * time.Second})
+	targets := []Target{{Kind: KindDeployment, Item: ResourceItem{Name: "web", Namespace: "shop", Replicas: ReplicaCount(5), WaitFor: WaitForReplicas}}}
+
+	if _, err := s.Run(context.Background(), ModeScaleDown, targets); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	d, err := client.AppsV1().Deployments("shop").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := specReplicas(d.Spec.Replicas); got != 5 {
+		t.Errorf("spec.replicas = %d, want 5", got)
+	}
+	if got := d.Annotations[OriginalReplicasAnnotation]; got != "3" {
+		t.Errorf("%s = %q, want 3", OriginalReplicasAnnotation, got)
+	}
+}
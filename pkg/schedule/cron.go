@@ -0,0 +1,38 @@
+// Package schedule parses the cron expressions of scheduled maintenances.
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Cron is a standard five-field cron expression: minute, hour, day of month,
+// month and day of week, or a descriptor such as "@daily" or "@every 6h".
+// As in cron, a day matches if either the day of month or the day of week
+// matches when both are restricted.
+type Cron struct {
+	expr     string
+	schedule cron.Schedule
+}
+
+// Parse parses a five-field cron expression or a descriptor.
+func Parse(expr string) (*Cron, error) {
+	s, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return &Cron{expr: expr, schedule: s}, nil
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first time after t matching the expression, in the
+// location of t. It returns the zero time if nothing matches within five
+// years, e.g. for "0 0 30 2 *".
+func (c *Cron) Next(t time.Time) time.Time {
+	return c.schedule.Next(t)
+}
@@ -0,0 +1,110 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "every minute",
+			expr: "* * * * *",
+			from: time.Date(2026, 3, 2, 10, 15, 30, 0, time.UTC),
+			want: time.Date(2026, 3, 2, 10, 16, 0, 0, time.UTC),
+		},
+		{
+			name: "day names",
+			expr: "0 2 * * SAT",
+			from: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day of month or day of week, day of week first",
+			expr: "0 0 15 * MON",
+			from: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day of month or day of week, day of month first",
+			expr: "0 0 4 * MON",
+			from: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "unrestricted day of week keeps the day of month",
+			expr: "0 0 15 * *",
+			from: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "steps and ranges",
+			expr: "*/20 9-17 * * MON-FRI",
+			from: time.Date(2026, 3, 6, 17, 45, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "every",
+			expr: "@every 90m",
+			from: time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 2, 11, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "daily",
+			expr: "@daily",
+			from: time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+			want: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "skipped hour of the spring forward",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 28, 12, 0, 0, 0, paris),
+			want: time.Date(2026, 3, 30, 2, 30, 0, 0, paris),
+		},
+		{
+			name: "hour after the spring forward",
+			expr: "0 3 * * *",
+			from: time.Date(2026, 3, 28, 12, 0, 0, 0, paris),
+			want: time.Date(2026, 3, 29, 3, 0, 0, 0, paris),
+		},
+		{
+			name: "repeated hour of the fall back runs once",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 10, 25, 2, 45, 0, 0, paris),
+			want: time.Date(2026, 10, 26, 2, 30, 0, 0, paris),
+		},
+		{
+			name: "never",
+			expr: "0 0 30 2 *",
+			from: time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := c.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "@every"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+// Package server exposes scale downs and restores over an HTTP API, so that
+// maintenance orchestration tools can trigger and follow runs without
+// shelling out to the CLI.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Addr is the address to listen on, e.g. ":8080".
+	Addr string
+
+	// Token, if set, must be sent as "Authorization: Bearer <token>" with
+	// every request.
+	Token string
+
+	// Dynamic and Mapper are required to scale custom resources.
+	Dynamic dynamic.Interface
+	Mapper  meta.RESTMapper
+
+	// Namespace is the namespace of config items that omit it. Explicit
+	// also makes selector items default to it, see
+	// scaler.Config.ApplyDefaultNamespace.
+	Namespace string
+	Explicit  bool
+
+	// Store keeps the records of runs. It defaults to NewMemoryStore.
+	Store Store
+
+	// LockNamespace is the namespace of the cluster lock held by every run.
+	// Empty runs without the lock.
+	LockNamespace string
+
+	// Retry is the backoff of failed API requests, see scaler.Options.
+	Retry wait.Backoff
+
+	// PodExec runs the commands of exec hooks.
+	PodExec scaler.PodExecFunc
+
+	// Logger receives the progress of every run. Nil discards it.
+	Logger *slog.Logger
+}
+
+// Server runs the scale downs and restores requested over HTTP in the
+// background, and reports their progress.
+type Server struct {
+	client kubernetes.Interface
+	opts   Options
+	log    *slog.Logger
+
+	// ctx is the context of the runs, cancelled when Run returns.
+	ctx  context.Context
+	runs sync.WaitGroup
+}
+
+// New returns a Server using the given clientset.
+func New(client kubernetes.Interface, opts Options) *Server {
+	log := opts.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	return &Server{client: client, opts: opts, log: log}
+}
+
+// Run serves the API until ctx is cancelled. Runs still in progress are
+// then interrupted and recorded as such before it returns.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+	s.log.Info("Serving the API", "addr", listener.Addr().String())
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+	s.runs.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /scale-down", func(w http.ResponseWriter, r *http.Request) {
+		s.start(w, r, scaler.ModeScaleDown)
+	})
+	mux.HandleFunc("POST /restore", func(w http.ResponseWriter, r *http.Request) {
+		s.start(w, r, scaler.ModeRestore)
+	})
+	mux.HandleFunc("GET /runs", s.list)
+	mux.HandleFunc("GET /runs/{id}", s.get)
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorBody is the body of every error response. Problems lists the
+// problems of an invalid config.
+type errorBody struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := errorBody{Error: err.Error()}
+	var configErr *scaler.ConfigError
+	if errors.As(err, &configErr) {
+		body.Error = "invalid config"
+		body.Problems = configErr.Problems
+	}
+	writeJSON(w, status, body)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.opts.Store.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if runs == nil {
+		runs = []*Run{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	run, err := s.opts.Store.Get(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+// start validates a request and starts its run in the background. It
+// responds with the record of the run, to be followed at its Location.
+func (s *Server) start(w http.ResponseWriter, r *http.Request, mode scaler.Mode) {
+	var req Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+
+	config, state, err := s.prepare(r.Context(), mode, req)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts, err := s.scalerOptions(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run := &tracked{run: Run{
+		ID:        scaler.NewRunID(),
+		Mode:      mode,
+		Phase:     PhaseRunning,
+		Message:   fmt.Sprintf("Starting %s", mode),
+		StartedAt: time.Now().UTC(),
+		Config:    config,
+	}}
+	opts.RunID = run.run.ID
+	if err := s.opts.Store.Save(r.Context(), &run.run); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to save the run: %v", err))
+		return
+	}
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.execute(run, state, opts)
+	}()
+
+	w.Header().Set("Location", "/runs/"+run.run.ID)
+	writeJSON(w, http.StatusAccepted, run.snapshot())
+}
+
+// prepare returns the config of a request with its default namespace, and
+// the original replica counts to restore.
+func (s *Server) prepare(ctx context.Context, mode scaler.Mode, req Request) (scaler.Config, *scaler.State, error) {
+	state := &scaler.State{}
+	config := req.Config
+	if req.Run != "" {
+		if mode != scaler.ModeRestore {
+			return config, nil, errors.New("run is only supported on restore")
+		}
+		if !reflect.ValueOf(req.Config).IsZero() {
+			return config, nil, errors.New("config cannot be combined with run, the config of the run is restored")
+		}
+		previous, err := s.opts.Store.Get(ctx, req.Run)
+		if err != nil {
+			return config, nil, fmt.Errorf("run %s: %w", req.Run, err)
+		}
+		if previous.Mode != scaler.ModeScaleDown || previous.Phase == PhaseRunning {
+			return config, nil, fmt.Errorf("run %s is not a finished scale down", req.Run)
+		}
+		config = previous.Config.WithoutReplicas()
+		if counts := previous.OriginalReplicas; counts != nil {
+			state.Deployments, state.StatefulSets, state.Custom = counts.Deployments, counts.StatefulSets, counts.Custom
+			state.ReplicationControllers, state.DeploymentConfigs = counts.ReplicationControllers, counts.DeploymentConfigs
+		}
+	}
+
+	if config.MultiCluster() {
+		return config, nil, errors.New("contexts are not supported, the server scales the cluster it runs against")
+	}
+	if err := rejectHooks(config); err != nil {
+		return config, nil, err
+	}
+	config = config.WithDefaults()
+	config.ApplyDefaultNamespace(s.opts.Namespace, s.opts.Explicit)
+	if err := config.Validate(); err != nil {
+		return config, nil, err
+	}
+	return config, state, nil
+}
+
+// rejectHooks refuses hooks and quiesce commands: command hooks would run
+// anything on the server, exec hooks and quiesce commands anything in the
+// pods it can reach, and http hooks would send requests from the server on
+// behalf of API clients.
+func rejectHooks(config scaler.Config) error {
+	hooks := []*scaler.Hook{config.PreHook, config.PostHook}
+	for _, items := range [][]scaler.ResourceItem{config.Deployments, config.StatefulSets, config.CronJobs, config.Jobs, config.ReplicationControllers, config.DeploymentConfigs, config.Rollouts, config.KnativeServices, config.Custom, config.Resources} {
+		for _, item := range items {
+			if item.Quiesce != nil {
+				return errors.New("quiesce is not supported by the server")
+			}
+			hooks = append(hooks, item.PreHook, item.PostHook)
+		}
+	}
+	for _, hook := range hooks {
+		if hook != nil {
+			return errors.New("hooks are not supported by the server")
+		}
+	}
+	return nil
+}
+
+func (s *Server) scalerOptions(req Request) (scaler.Options, error) {
+	var timeout time.Duration
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout < 0 {
+			return scaler.Options{}, fmt.Errorf("invalid timeout %q", req.Timeout)
+		}
+	}
+	switch req.OnError {
+	case "", scaler.ErrorPolicyContinue, scaler.ErrorPolicyFailFast, scaler.ErrorPolicyRollback:
+	default:
+		return scaler.Options{}, fmt.Errorf("unsupported onError %q, must be one of: continue, fail-fast, rollback", req.OnError)
+	}
+	switch req.WaitFor {
+	case "", scaler.WaitForReplicas, scaler.WaitForReady, scaler.WaitForReadyReplicas, scaler.WaitForEndpoints, scaler.WaitForEndpointsDrained, scaler.WaitForPodsDeleted, scaler.WaitForVolumesDetached:
+	default:
+		return scaler.Options{}, fmt.Errorf("unsupported waitFor %q, must be one of: replicas, ready, readyReplicas, endpoints, endpointsDrained, podsDeleted, volumesDetached", req.WaitFor)
+	}
+	return scaler.Options{
+		Dynamic:         s.opts.Dynamic,
+		Mapper:          s.opts.Mapper,
+		MaxConcurrency:  req.MaxConcurrency,
+		MaxPerNamespace: req.MaxPerNamespace,
+		PauseHPA:        req.PauseHPA,
+		Timeout:         timeout,
+		OnError:         req.OnError,
+		WaitFor:         req.WaitFor,
+		Retry:           s.opts.Retry,
+		PodExec:         s.opts.PodExec,
+		RecordEvents:    true,
+	}, nil
+}
+
+// tracked is a run in progress. Its record is updated from the goroutines
+// of the scaler.
+type tracked struct {
+	mu  sync.Mutex
+	run Run
+}
+
+func (t *tracked) snapshot() Run {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	run := t.run
+	run.Resources = append([]Resource(nil), t.run.Resources...)
+	return run
+}
+
+// update changes the record of a run and saves it. Saving goes on after
+// the server is stopped, so that interrupted runs are recorded.
+func (s *Server) update(t *tracked, change func(*Run)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	change(&t.run)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(s.ctx), 10*time.Second)
+	defer cancel()
+	if err := s.opts.Store.Save(ctx, &t.run); err != nil {
+		s.log.Warn("Failed to save the run", "run", t.run.ID, "error", err)
+	}
+}
+
+func (s *Server) finish(t *tracked, phase Phase, message string) {
+	s.update(t, func(run *Run) {
+		now := time.Now().UTC()
+		run.Phase = phase
+		run.Message = message
+		run.FinishedAt = &now
+	})
+	if phase == PhaseSucceeded {
+		s.log.Info(message, "run", t.run.ID)
+	} else {
+		s.log.Error("Run failed", "run", t.run.ID, "phase", string(phase), "error", message)
+	}
+}
+
+// execute scales the resources of a run, recording the result of every
+// resource as soon as it is done.
+func (s *Server) execute(t *tracked, state *scaler.State, opts scaler.Options) {
+	ctx := s.ctx
+	id, mode, config := t.run.ID, t.run.Mode, t.run.Config
+	log := s.log.With("run", id)
+
+	if s.opts.LockNamespace != "" {
+		lock, err := scaler.AcquireLock(ctx, s.client, scaler.LockOptions{
+			Namespace: s.opts.LockNamespace,
+			Holder:    "parallel-scale-down serve, run " + id,
+			Mode:      mode,
+			OnLost: func(holder string) {
+				log.Warn("The cluster lock was taken over by another run", "holder", holder)
+			},
+		})
+		if err != nil {
+			s.finish(t, PhaseFailed, fmt.Sprintf("failed to acquire the cluster lock: %v", err))
+			return
+		}
+		defer func() {
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			if err := lock.Release(releaseCtx); err != nil {
+				log.Warn("Failed to release the cluster lock", "error", err)
+			}
+		}()
+	}
+
+	opts.State = state
+	opts.Actor = "parallel-scale-down serve, run " + id
+	opts.PreHook = config.PreHook
+	opts.PostHook = config.PostHook
+	opts.Nodes = config.Nodes
+	opts.OnEvent = func(ev scaler.Event) {
+		log.Info(ev.Message, "event", string(ev.Type), "kind", ev.Target.Label(), "namespace", ev.Target.Item.Namespace, "name", ev.Target.Item.Name)
+	}
+	opts.OnResult = func(res scaler.Result) {
+		s.update(t, func(run *Run) { setResult(run, res) })
+	}
+	var budget *scaler.Budget
+	if mode == scaler.ModeScaleDown && config.MaxUnavailable != "" {
+		budget = &scaler.Budget{}
+		opts.Unavailable = budget
+	}
+	sc := scaler.New(s.client, opts)
+
+	targets, err := sc.Resolve(ctx, config)
+	if err != nil {
+		s.finish(t, PhaseFailed, err.Error())
+		return
+	}
+	if budget != nil {
+		if _, _, err := budget.SetMaxUnavailable(config.MaxUnavailable, targets); err != nil {
+			s.finish(t, PhaseFailed, err.Error())
+			return
+		}
+	}
+	s.update(t, func(run *Run) {
+		run.Message = fmt.Sprintf("Running %s of %d resources", mode, len(targets))
+		for _, target := range targets {
+			run.Resources = append(run.Resources, Resource{
+				Kind:      target.Label(),
+				Namespace: target.Item.Namespace,
+				Name:      target.Item.Name,
+				Wave:      target.Wave,
+				Status:    "pending",
+			})
+		}
+	})
+
+	report, runErr := sc.Run(ctx, mode, targets)
+	s.update(t, func(run *Run) {
+		for _, res := range report.Results {
+			setResult(run, res)
+		}
+		if mode == scaler.ModeScaleDown {
+			run.OriginalReplicas = &ReplicaCounts{
+				Deployments:            state.Deployments,
+				StatefulSets:           state.StatefulSets,
+				ReplicationControllers: state.ReplicationControllers,
+				DeploymentConfigs:      state.DeploymentConfigs,
+				Custom:                 state.Custom,
+			}
+		}
+	})
+	switch {
+	case report.Interrupted:
+		s.finish(t, PhaseInterrupted, errString(runErr))
+	case runErr != nil:
+		s.finish(t, PhaseFailed, runErr.Error())
+	case mode == scaler.ModeScaleDown:
+		s.finish(t, PhaseSucceeded, fmt.Sprintf("Scaled down %d resources", len(targets)))
+	default:
+		s.finish(t, PhaseSucceeded, fmt.Sprintf("Restored %d resources", len(targets)))
+	}
+}
+
+func setResult(run *Run, res scaler.Result) {
+	for i := range run.Resources {
+		r := &run.Resources[i]
+		if r.Kind != res.Target.Label() || r.Namespace != res.Target.Item.Namespace || r.Name != res.Target.Item.Name {
+			continue
+		}
+		r.PreviousReplicas = res.PreviousReplicas
+		r.TargetReplicas = res.TargetReplicas
+		if res.Status != "" {
+			r.Status = string(res.Status)
+		}
+		r.Error = errString(res.Err)
+		return
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
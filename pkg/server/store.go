@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrNotFound is returned by a Store for an unknown run.
+var ErrNotFound = errors.New("run not found")
+
+// Store keeps the records of runs.
+type Store interface {
+	Save(ctx context.Context, run *Run) error
+	// Get returns ErrNotFound for an unknown run.
+	Get(ctx context.Context, id string) (*Run, error)
+	// List returns every run, most recent first.
+	List(ctx context.Context) ([]*Run, error)
+}
+
+// NewMemoryStore returns a Store losing its runs when the server stops.
+func NewMemoryStore() Store {
+	return &memoryStore{runs: map[string][]byte{}}
+}
+
+type memoryStore struct {
+	mu sync.Mutex
+	// runs holds the JSON of every run, so that callers never share a
+	// record with a run in progress.
+	runs map[string][]byte
+}
+
+func (m *memoryStore) Save(_ context.Context, run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[run.ID] = data
+	return nil
+}
+
+func (m *memoryStore) Get(_ context.Context, id string) (*Run, error) {
+	m.mu.Lock()
+	data, ok := m.runs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return decodeRun(data)
+}
+
+func (m *memoryStore) List(_ context.Context) ([]*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []*Run
+	for _, data := range m.runs {
+		run, err := decodeRun(data)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+const (
+	// RunLabel is set on the ConfigMaps of a ConfigMap store to the ID of
+	// their run.
+	RunLabel = "parallel-scale-down/run"
+	runKey   = "run.json"
+)
+
+// NewConfigMapStore returns a Store keeping every run in a ConfigMap named
+// "parallel-scale-down-run-<id>" in the given namespace, so that runs
+// survive a restart of the server and can be read by other replicas.
+func NewConfigMapStore(client kubernetes.Interface, namespace string) Store {
+	return &configMapStore{client: client, namespace: namespace}
+}
+
+type configMapStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+func configMapName(id string) string {
+	return "parallel-scale-down-run-" + id
+}
+
+func (c *configMapStore) Save(ctx context.Context, run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+	cm, err := configMaps.Get(ctx, configMapName(run.ID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapName(run.ID),
+				Namespace: c.namespace,
+				Labels:    map[string]string{RunLabel: run.ID},
+			},
+			Data: map[string]string{runKey: string(data)},
+		}
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	cm.Data = map[string]string{runKey: string(data)}
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *configMapStore) Get(ctx context.Context, id string) (*Run, error) {
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, configMapName(id), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRun([]byte(cm.Data[runKey]))
+}
+
+func (c *configMapStore) List(ctx context.Context) ([]*Run, error) {
+	list, err := c.client.CoreV1().ConfigMaps(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: RunLabel})
+	if err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	for _, cm := range list.Items {
+		run, err := decodeRun([]byte(cm.Data[runKey]))
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s: %w", cm.Name, err)
+		}
+		runs = append(runs, run)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+func decodeRun(data []byte) (*Run, error) {
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("invalid run record: %w", err)
+	}
+	return &run, nil
+}
+
+func sortRuns(runs []*Run) {
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Request is the body of POST /scale-down and POST /restore.
+type Request struct {
+	// Config lists the resources of the run, as in a config file.
+	Config scaler.Config `json:"config"`
+	// Run, on restore, names a previous scale down run whose resources are
+	// restored to the replica counts it recorded. Config must be empty.
+	Run string `json:"run,omitempty"`
+
+	MaxConcurrency  int                `json:"maxConcurrency,omitempty"`
+	MaxPerNamespace int                `json:"maxPerNamespace,omitempty"`
+	PauseHPA        bool               `json:"pauseHPA,omitempty"`
+	OnError         scaler.ErrorPolicy `json:"onError,omitempty"`
+	WaitFor         scaler.WaitFor     `json:"waitFor,omitempty"`
+	// Timeout limits how long each resource may take, e.g. "5m".
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Phase is the progress of a run.
+type Phase string
+
+const (
+	PhaseRunning   Phase = "running"
+	PhaseSucceeded Phase = "succeeded"
+	PhaseFailed    Phase = "failed"
+	// PhaseInterrupted is set on the runs still in progress when the server
+	// stops.
+	PhaseInterrupted Phase = "interrupted"
+)
+
+// Run is the record of a run, returned by GET /runs/{id}.
+type Run struct {
+	ID         string      `json:"id"`
+	Mode       scaler.Mode `json:"mode"`
+	Phase      Phase       `json:"phase"`
+	Message    string      `json:"message,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Resources  []Resource  `json:"resources,omitempty"`
+
+	// Config and OriginalReplicas let a later restore name the run.
+	Config           scaler.Config  `json:"config"`
+	OriginalReplicas *ReplicaCounts `json:"originalReplicas,omitempty"`
+}
+
+// Resource is the progress of a single resource of a run.
+type Resource struct {
+	Kind             string `json:"kind"`
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	Wave             int    `json:"wave,omitempty"`
+	PreviousReplicas int32  `json:"previousReplicas"`
+	TargetReplicas   int32  `json:"targetReplicas"`
+	Status           string `json:"status"`
+	Error            string `json:"error,omitempty"`
+}
+
+// ReplicaCounts are the original replica counts recorded by a scale down,
+// keyed as in scaler.State.
+type ReplicaCounts struct {
+	Deployments            map[string]int32 `json:"deployments,omitempty"`
+	StatefulSets           map[string]int32 `json:"statefulsets,omitempty"`
+	ReplicationControllers map[string]int32 `json:"replicationcontrollers,omitempty"`
+	DeploymentConfigs      map[string]int32 `json:"deploymentconfigs,omitempty"`
+	Custom                 map[string]int32 `json:"custom,omitempty"`
+}
@@ -0,0 +1,140 @@
+// Package tracing records the spans of a run with the OpenTelemetry SDK and
+// exports them over OTLP/HTTP to a collector, or any backend accepting OTLP
+// such as Jaeger or Tempo.
+//
+// Spans are started from a context: Start returns a no-op span unless the
+// context carries a span of a Tracer, so that instrumented code runs
+// unchanged when tracing is disabled.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope is the instrumentation scope of the spans.
+const scope = "parallel-scale-down"
+
+// Attribute is a key and value recorded on a span.
+type Attribute = attribute.KeyValue
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+// Tracer batches the ended spans of its traces and exports them to an OTLP
+// endpoint.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewTracer returns a Tracer exporting to endpoint, the base URL of an OTLP
+// HTTP receiver such as http://localhost:4318, under the service name.
+// Without an endpoint, the exporter is configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables, and the resource and batching
+// by OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and OTEL_BSP_*. Export
+// errors are passed to onError. Call Shutdown to export the last spans.
+func NewTracer(ctx context.Context, endpoint, service string, onError func(error), attrs ...Attribute) (*Tracer, error) {
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// The environment comes last, so that OTEL_SERVICE_NAME overrides the
+	// service name.
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(append([]Attribute{semconv.ServiceName(service)}, attrs...)...),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if onError != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(onError))
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	return &Tracer{provider: provider, tracer: provider.Tracer(scope)}, nil
+}
+
+// Shutdown exports the spans ended since the last export and stops the
+// exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// Start starts a root span, beginning a new trace.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithNewRoot(), trace.WithAttributes(attrs...))
+	return ctx, &Span{span: span}
+}
+
+// Start starts a child of the span of ctx. Without a span in ctx, the
+// returned span records nothing.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return start(ctx, name, trace.SpanKindInternal, attrs)
+}
+
+func start(ctx context.Context, name string, kind trace.SpanKind, attrs []Attribute) (context.Context, *Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, nil
+	}
+	ctx, span := parent.TracerProvider().Tracer(scope).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	return ctx, &Span{span: span}
+}
+
+// Span is an operation of a trace. The methods of a nil Span do nothing.
+type Span struct {
+	span trace.Span
+}
+
+// TraceID returns the ID of the trace of the span, as shown by Jaeger and
+// Tempo.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.span.SpanContext().TraceID().String()
+}
+
+// SetAttributes records attributes on the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+// End ends the span, with an error status if err is not nil, and queues it
+// for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.span.SetStatus(codes.Error, err.Error())
+	} else {
+		s.span.SetStatus(codes.Ok, "")
+	}
+	s.span.End()
+}
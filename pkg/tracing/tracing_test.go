@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTracerExportsSpans(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies [][]byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("export to %s, want /v1/traces", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	tracer, err := NewTracer(ctx, server.URL+"/", "test-service", func(err error) { t.Errorf("export error: %v", err) })
+	if err != nil {
+		t.Fatalf("NewTracer: %v", err)
+	}
+	runCtx, run := tracer.Start(ctx, "run", String("mode", "scale down"))
+	if run.TraceID() == "" {
+		t.Error("root span has no trace ID")
+	}
+	_, wave := Start(runCtx, "wave 1", Int("wave", 1))
+	wave.End(errors.New("failed"))
+	run.End(nil)
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	all := bytes.Join(bodies, nil)
+	for _, want := range []string{"test-service", "run", "wave 1", "scale down", "failed"} {
+		if !bytes.Contains(all, []byte(want)) {
+			t.Errorf("exported spans do not contain %q", want)
+		}
+	}
+}
+
+func TestStartWithoutSpan(t *testing.T) {
+	ctx, span := Start(context.Background(), "orphan")
+	if span != nil {
+		t.Fatalf("Start without a span in the context returned %v, want nil", span)
+	}
+	if ctx != context.Background() {
+		t.Error("Start without a span in the context changed the context")
+	}
+	// The methods of a nil span do nothing.
+	span.SetAttributes(String("key", "value"))
+	span.End(nil)
+	if id := span.TraceID(); id != "" {
+		t.Errorf("TraceID() = %q, want empty", id)
+	}
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport wraps a RoundTripper so that every request made with the context
+// of a span is recorded as a client span, e.g. the API calls made while
+// scaling a resource.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return transport{next: rt}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := start(req.Context(), req.Method+" "+req.URL.Path, trace.SpanKindClient, []Attribute{
+		String("http.request.method", req.Method),
+		String("url.path", req.URL.Path),
+		String("server.address", req.URL.Host),
+	})
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return resp, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.End(httpError(resp.Status))
+	} else {
+		span.End(nil)
+	}
+	return resp, nil
+}
+
+// httpError is the error status of a span whose request failed on the
+// server.
+type httpError string
+
+func (e httpError) Error() string {
+	return string(e)
+}
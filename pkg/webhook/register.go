@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// ConfigurationName is the name of the ValidatingWebhookConfiguration of the
+// webhook.
+const ConfigurationName = "parallel-scale-down"
+
+// rules are the updates sent to the webhook: the workloads scaled down by a
+// maintenance and their scale subresources.
+var rules = []admissionregistrationv1.RuleWithOperations{
+	rule("apps", "deployments", "statefulsets"),
+	rule("", "replicationcontrollers"),
+	rule("apps.openshift.io", "deploymentconfigs"),
+	rule("argoproj.io", "rollouts"),
+}
+
+func rule(group string, resources ...string) admissionregistrationv1.RuleWithOperations {
+	var withScale []string
+	for _, resource := range resources {
+		withScale = append(withScale, resource, resource+"/scale")
+	}
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{group},
+			APIVersions: []string{"*"},
+			Resources:   withScale,
+			Scope:       ptr.To(admissionregistrationv1.NamespacedScope),
+		},
+	}
+}
+
+// Configuration returns the ValidatingWebhookConfiguration calling the
+// webhook through a Service, trusting caBundle. It fails open, so that an
+// unavailable webhook does not block changes to the cluster.
+func Configuration(service admissionregistrationv1.ServiceReference, caBundle []byte) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	service.Path = ptr.To(Path)
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigurationName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:                    "maintenance.parallel-scale-down.io",
+			ClientConfig:            admissionregistrationv1.WebhookClientConfig{Service: &service, CABundle: caBundle},
+			Rules:                   rules,
+			FailurePolicy:           ptr.To(admissionregistrationv1.Ignore),
+			SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+			TimeoutSeconds:          ptr.To[int32](5),
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+}
+
+// Register creates the ValidatingWebhookConfiguration of the webhook, or
+// updates it, e.g. with a renewed CA bundle.
+func Register(ctx context.Context, client kubernetes.Interface, config *admissionregistrationv1.ValidatingWebhookConfiguration) error {
+	configs := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	existing, err := configs.Get(ctx, config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := configs.Create(ctx, config, metav1.CreateOptions{FieldManager: scaler.FieldManager}); err != nil {
+			return fmt.Errorf("creating ValidatingWebhookConfiguration %s: %w", config.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading ValidatingWebhookConfiguration %s: %w", config.Name, err)
+	}
+	existing.Webhooks = config.Webhooks
+	if _, err := configs.Update(ctx, existing, metav1.UpdateOptions{FieldManager: scaler.FieldManager}); err != nil {
+		return fmt.Errorf("updating ValidatingWebhookConfiguration %s: %w", config.Name, err)
+	}
+	return nil
+}
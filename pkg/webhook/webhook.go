@@ -0,0 +1,223 @@
+// Package webhook serves a validating admission webhook denying replica
+// increases on the resources scaled down for a maintenance, so that neither
+// a person nor a controller brings them back before the restore.
+//
+// A resource is under maintenance while it carries
+// scaler.OriginalReplicasAnnotation. Changes made by the users and groups of
+// Options.AllowedUsers and AllowedGroups, i.e. by the identity running the
+// restores, are always allowed.
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// Path is the path the webhook is served on.
+const Path = "/validate"
+
+// Options configures a Server.
+type Options struct {
+	// Addr is the address to listen on, e.g. ":8443".
+	Addr string
+
+	// CertFile and KeyFile hold the TLS certificate the API server
+	// connects with.
+	CertFile, KeyFile string
+
+	// Dynamic reads the resource of a scale subresource, whose Scale object
+	// does not carry the annotations of the resource.
+	Dynamic dynamic.Interface
+
+	// AllowedUsers and AllowedGroups are the users, e.g.
+	// "system:serviceaccount:scale-down:scale-down-operator", and groups
+	// whose changes are always allowed, so that their restores go through.
+	// They are matched against the user authenticated by the API server,
+	// unlike the field manager, which is chosen by the client.
+	AllowedUsers  []string
+	AllowedGroups []string
+
+	// Logger receives the denied changes. Nil discards them.
+	Logger *slog.Logger
+}
+
+// Server serves the webhook.
+type Server struct {
+	opts Options
+	log  *slog.Logger
+}
+
+// New returns a Server.
+func New(opts Options) *Server {
+	log := opts.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+	return &Server{opts: opts, log: log}
+}
+
+// Run serves the webhook over TLS until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	cert, err := tls.LoadX509KeyPair(s.opts.CertFile, s.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	listener, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ServeTLS(listener, "", "")
+	}()
+	s.log.Info("Serving the admission webhook", "addr", listener.Addr().String(), "path", Path)
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the handler of the webhook.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+Path, s.validate)
+	return mux
+}
+
+func (s *Server) validate(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "expected an AdmissionReview request", http.StatusBadRequest)
+		return
+	}
+	response := s.review(r.Context(), review.Request)
+	response.UID = review.Request.UID
+	review.Response = response
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(review)
+}
+
+// review allows every request but the replica increases of resources under
+// maintenance.
+func (s *Server) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Operation != admissionv1.Update || s.allowedUser(req.UserInfo) {
+		return allowed
+	}
+	scale := req.SubResource == "scale"
+	oldReplicas, ok := replicasOf(req.OldObject.Raw, scale)
+	if !ok {
+		return allowed
+	}
+	newReplicas, ok := replicasOf(req.Object.Raw, scale)
+	if !ok || newReplicas <= oldReplicas {
+		return allowed
+	}
+
+	annotations, err := s.annotations(ctx, req)
+	if err != nil {
+		// Failing open, like the failurePolicy of the registration: an
+		// unreadable resource must not block changes to the cluster.
+		s.log.Warn("Could not read the resource of a scale change, allowing it", "namespace", req.Namespace, "name", req.Name, "error", err)
+		return allowed
+	}
+	original, ok := annotations[scaler.OriginalReplicasAnnotation]
+	if !ok {
+		return allowed
+	}
+
+	message := fmt.Sprintf("%s %s/%s is scaled down for a maintenance (originally %s replicas), its replicas cannot be raised from %d to %d until it is restored",
+		req.Kind.Kind, req.Namespace, req.Name, original, oldReplicas, newReplicas)
+	s.log.Info("Denied a scale up during the maintenance", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name, "user", req.UserInfo.Username, "replicas", newReplicas)
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonForbidden, Code: http.StatusForbidden, Message: message},
+	}
+}
+
+// annotations returns the annotations of the resource of a request: those
+// of its old object, or of the resource itself for a scale subresource.
+func (s *Server) annotations(ctx context.Context, req *admissionv1.AdmissionRequest) (map[string]string, error) {
+	if req.SubResource == "" {
+		var obj metav1.PartialObjectMetadata
+		if err := json.Unmarshal(req.OldObject.Raw, &obj); err != nil {
+			return nil, err
+		}
+		return obj.Annotations, nil
+	}
+	if s.opts.Dynamic == nil {
+		return nil, fmt.Errorf("no client to read %s %s/%s", req.Resource.Resource, req.Namespace, req.Name)
+	}
+	resource := schema.GroupVersionResource{Group: req.Resource.Group, Version: req.Resource.Version, Resource: req.Resource.Resource}
+	obj, err := s.opts.Dynamic.Resource(resource).Namespace(req.Namespace).Get(ctx, req.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return obj.GetAnnotations(), nil
+}
+
+// replicasOf returns the spec.replicas of an object, a workload or a Scale.
+// An unset field is 0 in a Scale, which omits it when empty, and the API
+// default of 1 in a workload.
+func replicasOf(raw []byte, scale bool) (int64, bool) {
+	var obj struct {
+		Spec struct {
+			Replicas *int64 `json:"replicas"`
+		} `json:"spec"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &obj) != nil {
+		return 0, false
+	}
+	switch {
+	case obj.Spec.Replicas != nil:
+		return *obj.Spec.Replicas, true
+	case scale:
+		return 0, true
+	}
+	return 1, true
+}
+
+// allowedUser reports whether the changes of a user are always allowed, see
+// Options.AllowedUsers and AllowedGroups.
+func (s *Server) allowedUser(user authenticationv1.UserInfo) bool {
+	if slices.Contains(s.opts.AllowedUsers, user.Username) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(s.opts.AllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+// deployment is the JSON of a Deployment under maintenance with replicas.
+func deployment(t *testing.T, replicas int) runtime.RawExtension {
+	t.Helper()
+	raw, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "backend",
+			"namespace":   "shop",
+			"annotations": map[string]string{scaler.OriginalReplicasAnnotation: "3"},
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestReviewAllowedUsers(t *testing.T) {
+	s := New(Options{
+		AllowedUsers:  []string{"system:serviceaccount:scale-down:scale-down-operator"},
+		AllowedGroups: []string{"system:serviceaccounts:scale-down"},
+	})
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		options string
+		allowed bool
+	}{
+		{
+			name:    "allowed user",
+			user:    authenticationv1.UserInfo{Username: "system:serviceaccount:scale-down:scale-down-operator"},
+			allowed: true,
+		},
+		{
+			name:    "member of an allowed group",
+			user:    authenticationv1.UserInfo{Username: "system:serviceaccount:scale-down:job", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:scale-down"}},
+			allowed: true,
+		},
+		{
+			name: "other user",
+			user: authenticationv1.UserInfo{Username: "alice", Groups: []string{"system:authenticated"}},
+		},
+		{
+			name:    "other user with the field manager of the plugin",
+			user:    authenticationv1.UserInfo{Username: "alice"},
+			options: `{"fieldManager":"parallel-scale-down"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Namespace: "shop",
+				Name:      "backend",
+				UserInfo:  tt.user,
+				OldObject: deployment(t, 0),
+				Object:    deployment(t, 3),
+			}
+			if tt.options != "" {
+				req.Options = runtime.RawExtension{Raw: []byte(tt.options)}
+			}
+			if got := s.review(context.Background(), req); got.Allowed != tt.allowed {
+				t.Errorf("review() allowed = %v, want %v", got.Allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestReplicasOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		scale bool
+		want  int64
+		ok    bool
+	}{
+		{name: "workload", raw: `{"kind":"Deployment","spec":{"replicas":3}}`, want: 3, ok: true},
+		{name: "workload scaled to zero", raw: `{"kind":"Deployment","spec":{"replicas":0}}`, want: 0, ok: true},
+		{name: "workload without replicas", raw: `{"kind":"Deployment","spec":{}}`, want: 1, ok: true},
+		{name: "scale", raw: `{"kind":"Scale","spec":{"replicas":2}}`, scale: true, want: 2, ok: true},
+		{name: "scale without replicas", raw: `{"kind":"Scale","spec":{}}`, scale: true, want: 0, ok: true},
+		{name: "empty", raw: "", ok: false},
+		{name: "invalid", raw: `{"spec":`, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := replicasOf([]byte(tt.raw), tt.scale)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("replicasOf() = %d, %v, want %d, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
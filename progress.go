@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const (
+	progressModeAuto   = "auto"
+	progressModeTable  = "table"
+	progressModeLines  = "lines"
+	progressModeStatus = "status"
+)
+
+var (
+	progressMode   string
+	statusInterval time.Duration
+)
+
+// liveTable shows the progress of the run as a table redrawn in place, or
+// as status blocks printed periodically. It is nil when progress is logged
+// line by line.
+var liveTable *progressTable
+
+// setupProgress enables the live table or the status blocks for
+// --progress. In auto mode, the table is shown on a terminal and the status
+// blocks otherwise, with text output and logs.
+func setupProgress() error {
+	var interval time.Duration
+	switch progressMode {
+	case progressModeLines:
+		return nil
+	case progressModeAuto:
+		if outputFormat != outputText || logFormat != logFormatText || quiet {
+			return nil
+		}
+		if !isTerminal(os.Stdout) {
+			interval = statusInterval
+		}
+	case progressModeTable, progressModeStatus:
+		if outputFormat != outputText || logFormat != logFormatText {
+			return fmt.Errorf("--progress=%s requires --output=text and --log-format=text", progressMode)
+		}
+		if progressMode == progressModeStatus {
+			interval = statusInterval
+		}
+	default:
+		return fmt.Errorf("unsupported --progress mode %q, must be one of: auto, table, status, lines", progressMode)
+	}
+	if progressMode != progressModeTable && statusInterval <= 0 {
+		return fmt.Errorf("--status-interval must be positive")
+	}
+	liveTable = newProgressTable(textOut, interval)
+	textOut = liveTable
+	return nil
+}
+
+// progressTable renders a row per target with its replicas, state, elapsed
+// time and last message. While the run is in progress, other output written
+// to it is printed above the table, which is then redrawn. With an interval,
+// the table is not redrawn: the rows in progress are printed as a status
+// block at every interval instead, e.g. for CI logs.
+type progressTable struct {
+	out      io.Writer
+	interval time.Duration
+
+	mu        sync.Mutex
+	rows      []*progressRow
+	index     map[string]*progressRow
+	withWaves bool
+	active    bool
+	dirty     bool
+	// lines is the number of lines of the table currently on screen.
+	lines  int
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+type progressRow struct {
+	target   scaler.Target
+	state    string
+	replicas *scaler.ReplicaProgress
+	started  time.Time
+	finished time.Time
+	message  string
+}
+
+const (
+	rowPending = "pending"
+	rowRunning = "running"
+	rowFailed  = "failed"
+)
+
+func newProgressTable(out io.Writer, interval time.Duration) *progressTable {
+	return &progressTable{out: out, interval: interval}
+}
+
+func rowKey(t scaler.Target) string {
+	return t.Label() + " " + t.Ref()
+}
+
+// Write prints data above the table.
+func (p *progressTable) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active || p.interval > 0 {
+		return p.out.Write(data)
+	}
+	p.clear()
+	n, err := p.out.Write(data)
+	p.draw(true)
+	return n, err
+}
+
+// start shows the table for the targets of a run and redraws it until stop.
+func (p *progressTable) start(targets []scaler.Target) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rows = nil
+	p.index = map[string]*progressRow{}
+	for _, t := range targets {
+		row := &progressRow{target: t, state: rowPending}
+		p.rows = append(p.rows, row)
+		p.index[rowKey(t)] = row
+	}
+	p.withWaves = scaler.HasWaves(targets)
+	p.active = true
+	p.lines = 0
+	if p.interval == 0 {
+		p.draw(true)
+	}
+
+	p.stopCh = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.refresh()
+}
+
+// refresh redraws the table when it changed, and every second for the
+// elapsed times.
+func (p *progressTable) refresh() {
+	defer close(p.done)
+	if p.interval > 0 {
+		p.printStatus()
+		return
+	}
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stopCh:
+			return
+		}
+		p.mu.Lock()
+		if p.dirty || time.Since(last) >= time.Second {
+			p.clear()
+			p.draw(true)
+			last = time.Now()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// printStatus prints the status block at every interval until stop.
+func (p *progressTable) printStatus() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stopCh:
+			return
+		}
+		p.mu.Lock()
+		var rows []*progressRow
+		for _, row := range p.rows {
+			if row.state == rowRunning || row.state == rowFailed {
+				rows = append(rows, row)
+			}
+		}
+		fmt.Fprintln(p.out, "Status:")
+		p.render(rows, 0)
+		p.lines = 0
+		p.mu.Unlock()
+	}
+}
+
+// stop prints the final table with every row and returns to line by line
+// output.
+func (p *progressTable) stop() {
+	close(p.stopCh)
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.draw(false)
+	p.active = false
+	p.lines = 0
+}
+
+// event updates the row of the target of ev. It reports whether ev is shown
+// by the table instead of being logged: every event of a row of the live
+// table, and the progress events of the status blocks.
+func (p *progressTable) event(ev scaler.Event) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active || ev.Target.Item.Name == "" {
+		return false
+	}
+	row, ok := p.index[rowKey(ev.Target)]
+	if !ok {
+		return false
+	}
+	switch ev.Type {
+	case scaler.EventStarted:
+		row.state = rowRunning
+		row.started = time.Now()
+		row.finished = time.Time{}
+	case scaler.EventFailed:
+		row.state = rowFailed
+	}
+	if ev.Replicas != nil {
+		row.replicas = ev.Replicas
+	}
+	row.message = oneLine(ev.Message)
+	p.dirty = true
+	return p.interval == 0 || ev.Type == scaler.EventProgress
+}
+
+// result records the outcome of a target.
+func (p *progressTable) result(res scaler.Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	row, ok := p.index[rowKey(res.Target)]
+	if !ok {
+		return
+	}
+	row.state = string(res.Status)
+	if row.started.IsZero() {
+		row.started = time.Now().Add(-res.Duration)
+	}
+	row.finished = row.started.Add(res.Duration)
+	if res.Status == scaler.StatusScaled || res.Status == scaler.StatusUnchanged {
+		row.replicas = &scaler.ReplicaProgress{Current: res.TargetReplicas, Target: res.TargetReplicas}
+	}
+	if res.Err != nil {
+		row.message = oneLine(res.Err.Error())
+	}
+	p.dirty = true
+}
+
+// clear erases the table from the screen, leaving the cursor where it
+// started.
+func (p *progressTable) clear() {
+	if p.lines > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dF\x1b[J", p.lines)
+	}
+	p.lines = 0
+}
+
+// draw prints the table. A live table is cut to the size of the terminal,
+// showing failed and running rows first, since a table taller than the
+// screen cannot be redrawn in place.
+func (p *progressTable) draw(live bool) {
+	width, height := 0, 0
+	if f, ok := p.out.(*os.File); ok {
+		width, height, _ = term.GetSize(int(f.Fd()))
+	}
+
+	rows := p.rows
+	if live && height > 0 && len(rows) > height-3 {
+		rows = p.visibleRows(max(height-3, 1))
+	}
+	if !live {
+		width = 0
+	}
+	p.render(rows, width)
+}
+
+// render prints the rows and the summary, cutting lines to width unless it
+// is zero. Colored states are wrapped in codes of the same length, so that
+// the columns stay aligned.
+func (p *progressTable) render(rows []*progressRow, width int) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	if p.withWaves {
+		fmt.Fprint(w, "WAVE\t")
+	}
+	fmt.Fprintf(w, "KIND\tRESOURCE\tREPLICAS\t%s\tELAPSED\tMESSAGE\n", colorize(colorDefault, "STATE"))
+	for _, row := range rows {
+		if p.withWaves {
+			fmt.Fprintf(w, "%d\t", row.target.Wave)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", row.target.Label(), row.target.Ref(), row.replicaString(), colorize(stateColor(row.state), row.state), row.elapsed(), row.message)
+	}
+	_ = w.Flush()
+	fmt.Fprintln(&buf, p.summary())
+
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		line = strings.TrimRight(line, " \n")
+		if width > 0 {
+			if runes := []rune(line); len(runes) >= width {
+				line = string(runes[:width-1])
+				if colorOutput {
+					line += colorReset
+				}
+			}
+		}
+		fmt.Fprintln(p.out, line)
+		p.lines++
+	}
+	p.dirty = false
+}
+
+// visibleRows picks n rows, preferring failed, then running, then pending
+// ones, in the order of the run.
+func (p *progressTable) visibleRows(n int) []*progressRow {
+	priority := func(row *progressRow) int {
+		switch row.state {
+		case rowFailed:
+			return 0
+		case rowRunning:
+			return 1
+		case rowPending:
+			return 2
+		}
+		return 3
+	}
+	shown := map[*progressRow]bool{}
+	for level := 0; level <= 3 && len(shown) < n; level++ {
+		for _, row := range p.rows {
+			if priority(row) == level && len(shown) < n {
+				shown[row] = true
+			}
+		}
+	}
+	var rows []*progressRow
+	for _, row := range p.rows {
+		if shown[row] {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (p *progressTable) summary() string {
+	counts := map[string]int{}
+	for _, row := range p.rows {
+		counts[row.state]++
+	}
+	done := len(p.rows) - counts[rowPending] - counts[rowRunning]
+	return fmt.Sprintf("%d/%d done, %d running, %d failed, %d pending", done, len(p.rows), counts[rowRunning], counts[rowFailed], counts[rowPending])
+}
+
+func (row *progressRow) replicaString() string {
+	if row.replicas == nil {
+		return "-"
+	}
+	if row.target.Kind == scaler.KindCronJob {
+		return cronJobState(row.replicas.Target)
+	}
+	return strconv.Itoa(int(row.replicas.Current)) + "/" + strconv.Itoa(int(row.replicas.Target))
+}
+
+func (row *progressRow) elapsed() string {
+	switch {
+	case row.started.IsZero():
+		return ""
+	case row.finished.IsZero():
+		return time.Since(row.started).Round(time.Second).String()
+	default:
+		return row.finished.Sub(row.started).Round(time.Second).String()
+	}
+}
+
+// oneLine keeps multi-line messages, such as hook output, from breaking the
+// layout of the table.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
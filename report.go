@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var reportPath string
+
+// reportFile is the audit record written with --report. The original replica
+// counts use the layout of the state file, so that the report of a scale down
+// can be passed to restore with --state-file.
+type reportFile struct {
+	RunID       string       `json:"runId,omitempty"`
+	Mode        string       `json:"mode"`
+	Context     string       `json:"context"`
+	StartTime   time.Time    `json:"startTime"`
+	EndTime     time.Time    `json:"endTime"`
+	Success     bool         `json:"success"`
+	Interrupted bool         `json:"interrupted,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Results     []jsonResult `json:"results"`
+	Rollback    []jsonResult `json:"rollback,omitempty"`
+
+	Deployments            map[string]int32 `json:"deployments,omitempty"`
+	StatefulSets           map[string]int32 `json:"statefulsets,omitempty"`
+	ReplicationControllers map[string]int32 `json:"replicationcontrollers,omitempty"`
+	DeploymentConfigs      map[string]int32 `json:"deploymentconfigs,omitempty"`
+	Custom                 map[string]int32 `json:"custom,omitempty"`
+}
+
+// writeReportFile writes the report of a run to --report, as YAML if the
+// file name ends in .yaml or .yml and as JSON otherwise.
+func writeReportFile(mode scaler.Mode, context string, state *scaler.State, report scaler.Report, runErr error, start, end time.Time) error {
+	data, err := json.MarshalIndent(newReportFile(mode, context, state, report, runErr, start, end), "", "  ")
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(reportPath)) {
+	case ".yaml", ".yml":
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+	default:
+		data = append(data, '\n')
+	}
+	return os.WriteFile(reportPath, data, 0o644)
+}
+
+// newReportFile returns the report of a run, see reportFile.
+func newReportFile(mode scaler.Mode, context string, state *scaler.State, report scaler.Report, runErr error, start, end time.Time) reportFile {
+	file := reportFile{
+		RunID:       currentRunID,
+		Mode:        string(mode),
+		Context:     context,
+		StartTime:   start.UTC(),
+		EndTime:     end.UTC(),
+		Success:     runErr == nil,
+		Interrupted: report.Interrupted,
+		Error:       errString(runErr),
+		Results:     []jsonResult{},
+	}
+	for _, res := range report.Results {
+		file.Results = append(file.Results, toJSONResult(res))
+	}
+	for _, res := range report.Rollback {
+		file.Rollback = append(file.Rollback, toJSONResult(res))
+	}
+	if mode == scaler.ModeScaleDown {
+		file.Deployments = state.Deployments
+		file.StatefulSets = state.StatefulSets
+		file.ReplicationControllers = state.ReplicationControllers
+		file.DeploymentConfigs = state.DeploymentConfigs
+		file.Custom = state.Custom
+	}
+	return file
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var restoreConfigDir string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&restoreConfigDir, "restore-config-dir", ".", "Directory a restore-<timestamp>.yaml config with the original replica counts is written to after every scale down (empty disables it)")
+}
+
+// writeRestoreConfig writes the config restoring the resources of a scale
+// down to their original replica counts to --restore-config-dir, so that the
+// restore works even without the state file or the annotations. It returns
+// the path of the file.
+func writeRestoreConfig(clusters []*cluster, state *scaler.State, report scaler.Report, end time.Time) (string, error) {
+	config := report.RestoreConfig(state)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by parallel-scale-down from context %s on %s.\n", describeClusters(clusters), end.Format(time.RFC3339))
+	fmt.Fprintln(&buf, "# Restores the resources of the scale down to their original replica counts with: kubectl scale-down restore --file <this file>")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return "", err
+	}
+	path := filepath.Join(restoreConfigDir, "restore-"+end.Format("20060102-150405")+".yaml")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
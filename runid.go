@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	runID string
+	// currentRunID identifies the run in progress in the logs, annotations,
+	// Events, state ConfigMaps, notifications and reports. The restore at
+	// the end of a --window shares the ID of its scale down.
+	currentRunID string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&runID, "run-id", "", "ID of the run in logs, annotations, Events, state ConfigMaps, notifications and reports, to correlate them with a change request (default: generated, e.g. 20240601-020000-1a2b3c)")
+}
+
+// validateRunID checks that --run-id can be used in label values and
+// object names.
+func validateRunID() error {
+	if runID == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Label(runID); len(errs) > 0 {
+		return fmt.Errorf("invalid --run-id %q: %s", runID, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// startRun sets currentRunID to --run-id, or to a new ID without it.
+func startRun() {
+	currentRunID = runID
+	if currentRunID == "" {
+		currentRunID = scaler.NewRunID()
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+	"parallel-scale-down/pkg/schedule"
+)
+
+var (
+	startAt      string
+	window       time.Duration
+	cronSchedule string
+)
+
+// runScheduled executes the run right away, at --at, or at every tick of
+// --schedule until interrupted. With --window, a scale down is followed by a
+// restore at the end of the window.
+func runScheduled(ctx context.Context, r *runner, mode scaler.Mode) error {
+	if err := validateSchedule(mode); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	if cronSchedule != "" {
+		cron, err := schedule.Parse(cronSchedule)
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+		for {
+			start := cron.Next(time.Now())
+			if start.IsZero() {
+				return withExitCode(exitConfig, fmt.Errorf("schedule %q never matches", cronSchedule))
+			}
+			logger.Info("Waiting for the next maintenance window", "start", start.Format(time.RFC3339), "schedule", cronSchedule)
+			if err := sleepUntil(ctx, start); err != nil {
+				return nil
+			}
+			// Every window records the original replica counts afresh.
+			if err := runWindow(ctx, r, mode, &scaler.State{}, start); err != nil {
+				logger.Error("Scheduled run failed", "error", err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+
+	var state *scaler.State
+	if r.record != nil {
+		state = r.record.State
+	} else {
+		var err error
+		if state, err = scaler.LoadState(stateFilePath); err != nil {
+			return fmt.Errorf("error reading state file: %v", err)
+		}
+	}
+	start := time.Now()
+	var err error
+	if startAt != "" {
+		if start, err = time.Parse(time.RFC3339, startAt); err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("invalid --at time %q, must be RFC 3339 such as 2024-06-01T02:00:00Z", startAt))
+		}
+		if time.Until(start) > 0 {
+			logger.Info(fmt.Sprintf("Waiting to start the %s", mode), "start", start.Format(time.RFC3339))
+			if err := sleepUntil(ctx, start); err != nil {
+				return withExitCode(exitInterrupted, fmt.Errorf("interrupted before the start of the %s, nothing was changed", mode))
+			}
+		}
+	}
+	return runWindow(ctx, r, mode, state, start)
+}
+
+// runWindow executes the run, and restores the resources at the end of
+// --window after a successful scale down.
+func runWindow(ctx context.Context, r *runner, mode scaler.Mode, state *scaler.State, start time.Time) error {
+	startRun()
+	if err := r.execute(ctx, mode, state, false); err != nil {
+		if window > 0 {
+			logger.Warn("The scale down failed, the resources will not be restored at the end of the window")
+		}
+		return err
+	}
+	if window == 0 {
+		return nil
+	}
+
+	end := start.Add(window)
+	logger.Info("Resources will be restored at the end of the window", "end", end.Format(time.RFC3339))
+	if err := sleepUntil(ctx, end); err != nil {
+		return withExitCode(exitInterrupted, fmt.Errorf("interrupted before the end of the window, the resources are still scaled down: run restore to bring them back"))
+	}
+	return r.execute(ctx, scaler.ModeRestore, state, true)
+}
+
+func validateSchedule(mode scaler.Mode) error {
+	scheduled := startAt != "" || cronSchedule != "" || window > 0
+	switch {
+	case !scheduled:
+		return nil
+	case startAt != "" && cronSchedule != "":
+		return fmt.Errorf("--at and --schedule cannot be combined")
+	case window < 0:
+		return fmt.Errorf("--window must be positive")
+	case window > 0 && mode != scaler.ModeScaleDown:
+		return fmt.Errorf("--window is only supported when scaling down")
+	case dryRun:
+		return fmt.Errorf("--dry-run cannot be combined with --at, --schedule or --window")
+	case cronSchedule != "" && resume:
+		return fmt.Errorf("--resume cannot be combined with --schedule")
+	case cronSchedule != "" && runID != "":
+		return fmt.Errorf("--run-id cannot be combined with --schedule, every scheduled run gets its own ID")
+	case !assumeYes:
+		return fmt.Errorf("scheduled runs cannot be confirmed when they start: review the plan with --dry-run and pass --yes")
+	}
+	return nil
+}
+
+// sleepUntil waits until t, or returns the error of ctx if it is cancelled
+// first.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/server"
+)
+
+const (
+	serveStoreMemory    = "memory"
+	serveStoreConfigMap = "configmap"
+)
+
+var (
+	serveAddr      string
+	serveTokenFile string
+	serveStore     string
+	serveStoreNS   string
+	serveCmd       = &cobra.Command{
+		Use:          "serve",
+		Short:        "Serve an HTTP API to trigger scale downs and restores and follow their runs, until interrupted",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd)
+		},
+	}
+)
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to serve the API on")
+	serveCmd.Flags().StringVar(&serveTokenFile, "token-file", "", "File holding the bearer token required by every request. Required unless --addr is a loopback address, e.g. 127.0.0.1:8080")
+	serveCmd.Flags().StringVar(&serveStore, "store", serveStoreMemory, "Where runs are kept: memory, or configmap to keep them in ConfigMaps of --store-namespace")
+	serveCmd.Flags().StringVar(&serveStoreNS, "store-namespace", "", "Namespace of the ConfigMaps of --store=configmap (defaults to --lock-namespace)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// runServe serves the API against the current context until interrupted.
+func runServe(cmd *cobra.Command) error {
+	if err := setupLogging(os.Stdout); err != nil {
+		return err
+	}
+
+	backoff, err := retryBackoffFlags()
+	if err != nil {
+		return err
+	}
+
+	var token string
+	if serveTokenFile != "" {
+		data, err := os.ReadFile(serveTokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading token file: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	} else if !isLoopback(serveAddr) {
+		return fmt.Errorf("--token-file is required to serve the API on %s, or use a loopback --addr such as 127.0.0.1:8080", serveAddr)
+	}
+
+	kubeConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+
+	namespace, explicit, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("error resolving namespace: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	mapper, err := configFlags.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("error creating rest mapper: %v", err)
+	}
+
+	var store server.Store
+	switch serveStore {
+	case serveStoreMemory:
+		store = server.NewMemoryStore()
+	case serveStoreConfigMap:
+		storeNamespace := serveStoreNS
+		if storeNamespace == "" {
+			storeNamespace = lockNamespace
+		}
+		store = server.NewConfigMapStore(clientset, storeNamespace)
+	default:
+		return fmt.Errorf("unsupported --store %q, must be one of: memory, configmap", serveStore)
+	}
+
+	opts := server.Options{
+		Addr:          serveAddr,
+		Token:         token,
+		Dynamic:       dynamicClient,
+		Mapper:        mapper,
+		Namespace:     namespace,
+		Explicit:      explicit,
+		Store:         store,
+		LockNamespace: lockNamespace,
+		Retry:         backoff,
+		PodExec:       kubectlExec(configFlags),
+		Logger:        logger,
+	}
+	if noLock {
+		opts.LockNamespace = ""
+	}
+	return server.New(clientset, opts).Run(cmd.Context())
+}
+
+// isLoopback reports whether a listen address only accepts local
+// connections, so that serving on it without a token is safe enough.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	snapshotNamespaces    []string
+	snapshotAllNamespaces bool
+	snapshotSelector      string
+	snapshotNodes         []string
+	snapshotCmd           = &cobra.Command{
+		Use:          "snapshot",
+		Short:        "Write a config listing the deployments and statefulsets of the cluster with their current replica counts to --file",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSnapshot(cmd)
+		},
+	}
+)
+
+func init() {
+	snapshotCmd.Flags().StringSliceVar(&snapshotNamespaces, "namespaces", nil, "Namespaces to snapshot (defaults to the current namespace)")
+	snapshotCmd.Flags().BoolVarP(&snapshotAllNamespaces, "all-namespaces", "A", false, "Snapshot all namespaces")
+	snapshotCmd.Flags().StringVarP(&snapshotSelector, "selector", "l", "", "Only snapshot resources matching this label selector")
+	snapshotCmd.Flags().StringSliceVar(&snapshotNodes, "nodes", nil, "Snapshot the deployments and statefulsets with pods on these nodes instead of whole namespaces")
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+func runSnapshot(cmd *cobra.Command) error {
+	if len(inputFilePaths) == 0 {
+		return fmt.Errorf(`required flag "file" not set`)
+	}
+	if len(inputFilePaths) > 1 {
+		return fmt.Errorf("snapshot writes a single --file")
+	}
+	if len(snapshotNodes) > 0 && (snapshotAllNamespaces || len(snapshotNamespaces) > 0 || snapshotSelector != "") {
+		return fmt.Errorf("--nodes cannot be combined with --namespaces, --all-namespaces or --selector")
+	}
+	inputFilePath := inputFilePaths[0]
+	logOut := io.Writer(os.Stdout)
+	if inputFilePath == "-" {
+		logOut = os.Stderr
+	}
+	if err := setupLogging(logOut); err != nil {
+		return err
+	}
+
+	kubeConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+
+	namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("error resolving namespace: %v", err)
+	}
+	namespaces := []string{namespace}
+	if snapshotAllNamespaces {
+		namespaces = []string{""}
+	} else if len(snapshotNamespaces) > 0 {
+		namespaces = snapshotNamespaces
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+
+	s := scaler.New(clientset, scaler.Options{})
+	var config *scaler.Config
+	if len(snapshotNodes) > 0 {
+		config, err = s.SnapshotNodes(cmd.Context(), snapshotNodes)
+	} else {
+		config, err = s.Snapshot(cmd.Context(), namespaces, snapshotSelector)
+	}
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by parallel-scale-down snapshot from context %s on %s.\n", clusterDescription(), time.Now().Format(time.RFC3339))
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	if inputFilePath == "-" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(inputFilePath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing snapshot: %v", err)
+	}
+	logger.Info("Snapshot written", "path", inputFilePath, "deployments", len(config.Deployments), "statefulsets", len(config.StatefulSets))
+	return nil
+}
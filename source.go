@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const configFormatAuto = "auto"
+
+func fetch(location string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// readSource reads a config from a file, from stdin with "-", or from an
+// http(s)://, s3:// or gs:// URL.
+func readSource(source string) ([]byte, error) {
+	if source == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	if u, err := url.Parse(source); err == nil {
+		switch u.Scheme {
+		case "http", "https":
+			return fetch(source)
+		case "s3", "gs":
+			return readObject(u)
+		}
+	}
+	return os.ReadFile(source)
+}
+
+// configFormat is the format of the --file sources selected with --format.
+var configFormat string
+
+func loadConfig(source string) (*scaler.Config, error) {
+	data, err := readSource(source)
+	if err != nil {
+		return nil, err
+	}
+	format := scaler.ConfigFormat(configFormat)
+	if configFormat == configFormatAuto {
+		// URLs may carry a query after the extension.
+		path := source
+		if u, err := url.Parse(source); err == nil && u.Scheme != "" {
+			path = u.Path
+		}
+		format = scaler.FormatOf(path)
+	}
+	return scaler.ParseConfigAs(data, format)
+}
+
+// expandDirectories replaces the directories among the sources with the
+// .yaml, .yml and .json files they hold, in lexical order.
+func expandDirectories(sources []string) ([]string, error) {
+	var expanded []string
+	for _, source := range sources {
+		info, err := os.Stat(source)
+		if err != nil || !info.IsDir() {
+			expanded = append(expanded, source)
+			continue
+		}
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config directory: %v", err)
+		}
+		var files []string
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(source, entry.Name()))
+				}
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("config directory %s holds no .yaml, .yml or .json file", source)
+		}
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// loadConfigs reads the config of every --file and merges them, in order.
+// Directories stand for the config files they hold. Without any, the config
+// is empty.
+func loadConfigs(sources []string) (*scaler.Config, error) {
+	sources, err := expandDirectories(sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 1 {
+		config, err := loadConfig(sources[0])
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %v", err)
+		}
+		return config, nil
+	}
+
+	var configs []*scaler.Config
+	stdin := false
+	for _, source := range sources {
+		if source == "-" {
+			if stdin {
+				return nil, fmt.Errorf("--file - can only be given once")
+			}
+			stdin = true
+		}
+		config, err := loadConfig(source)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file %s: %v", source, err)
+		}
+		configs = append(configs, config)
+	}
+	config, err := scaler.MergeConfigs(sources, configs)
+	if err != nil {
+		return nil, fmt.Errorf("error merging config files: %v", err)
+	}
+	return config, nil
+}
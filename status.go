@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var (
+	statusRestored bool
+	statusCmd      = &cobra.Command{
+		Use:          "status",
+		Short:        "Report the current and target replicas of every resource of the config, and whether they match, without changing anything",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd)
+		},
+	}
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusRestored, "restored", false, "Compare the resources with their restore targets instead of their scale down targets, to verify a restore")
+	rootCmd.AddCommand(statusCmd)
+}
+
+// jsonStatusEntry is a resource of the status subcommand in JSON output.
+type jsonStatusEntry struct {
+	jsonPlanEntry
+	Matches bool `json:"matches"`
+}
+
+// runStatus compares the current replicas of every resource of the config
+// with its scale down target, or its restore target with --restored, and
+// fails with exitPartial if any of them does not match.
+func runStatus(cmd *cobra.Command) error {
+	if err := setOutputFormat(outputFormat); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	setupColor(textOut)
+	if err := setupLogging(textOut); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if len(inputFilePaths) == 0 && !allInNamespace {
+		return withExitCode(exitConfig, fmt.Errorf(`required flag "file" not set`))
+	}
+	mode := scaler.ModeScaleDown
+	if statusRestored {
+		mode = scaler.ModeRestore
+	}
+
+	var state *scaler.State
+	if statusRestored && stateFilePath != "" {
+		var err error
+		if state, err = scaler.LoadState(stateFilePath); err != nil {
+			return fmt.Errorf("error reading state file: %v", err)
+		}
+	}
+	config, err := loadConfigs(inputFilePaths)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	clusters, err := newClusters(config)
+	if err != nil {
+		return withExitCode(exitConnection, err)
+	}
+	for _, c := range clusters {
+		c.scaler = scaler.New(c.clientset, scaler.Options{
+			Dynamic:       c.dynamic,
+			Mapper:        c.mapper,
+			State:         state,
+			Cluster:       c.name,
+			PDBPolicy:     scaler.PDBPolicyIgnore,
+			IgnoreMissing: ignoreMissing,
+			OnEvent:       printEvent,
+		})
+	}
+	if _, err := resolveClusters(cmd.Context(), clusters); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	var plan []scaler.PlanEntry
+	for _, c := range clusters {
+		plan = append(plan, c.scaler.Plan(cmd.Context(), mode, c.targets)...)
+	}
+
+	mismatched := 0
+	for _, p := range plan {
+		if !statusMatches(p) {
+			mismatched++
+		}
+	}
+	if outputFormat == outputText {
+		printStatus(mode, plan)
+	} else {
+		writeStatus(mode, plan, mismatched == 0)
+	}
+	if mismatched > 0 {
+		return withExitCode(exitPartial, fmt.Errorf("%d of %d resources do not match their %s target", mismatched, len(plan), mode))
+	}
+	return nil
+}
+
+// statusMatches reports whether a resource has reached its target. Optional
+// resources that do not exist have nothing to reach.
+func statusMatches(p scaler.PlanEntry) bool {
+	return p.Missing || p.Err == nil && p.CurrentReplicas == p.TargetReplicas
+}
+
+// printStatus prints a table of the resources with their current and target
+// replicas.
+func printStatus(mode scaler.Mode, plan []scaler.PlanEntry) {
+	fmt.Fprintf(textOut, "\nStatus against the %s targets\n\n", mode)
+	w := tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tRESOURCE\tCURRENT\tTARGET\tSTATUS")
+	for _, p := range plan {
+		current, target := strconv.Itoa(int(p.CurrentReplicas)), strconv.Itoa(int(p.TargetReplicas))
+		if p.Target.Kind == scaler.KindCronJob {
+			current, target = cronJobState(p.CurrentReplicas), cronJobState(p.TargetReplicas)
+		}
+		status := "ok"
+		switch {
+		case p.Missing:
+			current, target, status = "-", "-", "skipped (not found)"
+		case p.Err != nil:
+			current, target, status = "-", "-", fmt.Sprintf("error: %v", p.Err)
+		case !statusMatches(p):
+			status = "differs"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Target.Label(), p.Target.Ref(), current, target, status)
+	}
+	_ = w.Flush()
+	fmt.Fprintln(textOut)
+}
+
+// writeStatus writes the resources in the selected JSON format. In ndjson
+// mode every resource is a line of type "status".
+func writeStatus(mode scaler.Mode, plan []scaler.PlanEntry, success bool) {
+	entries := []jsonStatusEntry{}
+	for i, p := range toJSONPlan(plan) {
+		entries = append(entries, jsonStatusEntry{jsonPlanEntry: p, Matches: statusMatches(plan[i])})
+	}
+	if outputFormat == outputNDJSON {
+		for _, e := range entries {
+			writeJSON(struct {
+				Type string `json:"type"`
+				jsonStatusEntry
+			}{"status", e})
+		}
+		return
+	}
+	writeJSON(struct {
+		Mode      string            `json:"mode"`
+		Success   bool              `json:"success"`
+		Resources []jsonStatusEntry `json:"resources"`
+	}{string(mode), success, entries})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+const summaryGitHub = "github"
+
+var (
+	summaryFormat string
+	summaryFile   string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&summaryFormat, "summary-format", "", "Format of a final summary of the run for CI systems: github, for GitHub Actions annotations and a step summary table")
+	rootCmd.PersistentFlags().StringVar(&summaryFile, "summary-file", "", "File the step summary table is appended to, e.g. /dev/fd/3 (defaults to $GITHUB_STEP_SUMMARY)")
+}
+
+// validateSummaryFormat checks --summary-format.
+func validateSummaryFormat() error {
+	switch summaryFormat {
+	case "", summaryGitHub:
+		return nil
+	}
+	return fmt.Errorf("unsupported --summary-format %q, must be: github", summaryFormat)
+}
+
+// writeGitHubSummary emits a GitHub Actions annotation per failed or skipped
+// resource and one for the run, so that they show up in the job UI, and
+// appends a table of the results to the step summary.
+func writeGitHubSummary(mode scaler.Mode, report scaler.Report, runErr error, elapsed time.Duration) {
+	title := fmt.Sprintf("Parallel %s", mode)
+	for _, res := range report.Results {
+		switch res.Status {
+		case scaler.StatusFailed:
+			githubCommand("error", res.Target.Label()+" "+res.Target.Ref(), errString(res.Err))
+		case scaler.StatusSkipped:
+			githubCommand("warning", res.Target.Label()+" "+res.Target.Ref(), "Skipped: "+errString(res.Err))
+		}
+	}
+	if runErr != nil {
+		githubCommand("error", title, fmt.Sprintf("%s failed: %v", title, runErr))
+	} else {
+		githubCommand("notice", title, fmt.Sprintf("All %d resources reached their target in %s.", len(report.Results), elapsed.Round(time.Second)))
+	}
+
+	path := summaryFile
+	if path == "" {
+		path = os.Getenv("GITHUB_STEP_SUMMARY")
+	}
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		logger.Error("Failed to write the step summary", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+	writeSummaryTable(f, title, report, runErr, elapsed)
+}
+
+// writeSummaryTable writes the results of a run as a Markdown table.
+func writeSummaryTable(w io.Writer, title string, report scaler.Report, runErr error, elapsed time.Duration) {
+	outcome := "succeeded"
+	if runErr != nil {
+		outcome = "failed"
+	}
+	fmt.Fprintf(w, "### %s %s\n\n", title, outcome)
+	fmt.Fprintf(w, "%d resources in %s.", len(report.Results), elapsed.Round(time.Second))
+	if runErr != nil {
+		fmt.Fprintf(w, " %s", markdownCell(runErr.Error()))
+	}
+	fmt.Fprint(w, "\n\n")
+	if len(report.Results) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "| Kind | Resource | Wave | Replicas | Status | Duration | Error |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- | --- |")
+	for _, res := range report.Results {
+		fmt.Fprintf(w, "| %s | %s | %d | %d → %d | %s | %s | %s |\n",
+			res.Target.Label(), markdownCell(res.Target.Ref()), res.Target.Wave,
+			res.PreviousReplicas, res.TargetReplicas, res.Status,
+			res.Duration.Round(time.Millisecond), markdownCell(errString(res.Err)))
+	}
+	fmt.Fprintln(w)
+}
+
+// markdownCell keeps text on one line of a Markdown table.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", "", "\n", " ").Replace(s)
+}
+
+// githubCommand writes a GitHub Actions workflow command, escaping its
+// property and message as the runner expects.
+func githubCommand(command, title, message string) {
+	data := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	property := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	fmt.Fprintf(textOut, "::%s title=%s::%s\n", command, property.Replace(title), data.Replace(message))
+}
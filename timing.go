@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var slowest int
+
+// printTimings prints the wall-clock time of the run and its slowest
+// resources, to size maintenance windows on real durations.
+func printTimings(mode scaler.Mode, report scaler.Report, elapsed time.Duration) {
+	var total time.Duration
+	for _, res := range report.Results {
+		total += res.Duration
+	}
+	if total == 0 {
+		return
+	}
+
+	fmt.Fprintf(textOut, "\nThe %s of %d resources took %s.\n", mode, len(report.Results), elapsed.Round(time.Millisecond))
+	if slowest <= 0 {
+		return
+	}
+	fmt.Fprintln(textOut, "Slowest resources:")
+	w := tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  DURATION\tSCALE\tKIND\tRESOURCE\tSTATUS")
+	for _, res := range report.Slowest(slowest) {
+		if res.Duration == 0 {
+			break
+		}
+		scale := "-"
+		if res.ScaleDuration > 0 {
+			scale = res.ScaleDuration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", res.Duration.Round(time.Millisecond), scale, res.Target.Label(), res.Target.Ref(), res.Status)
+	}
+	_ = w.Flush()
+}
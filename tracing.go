@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"parallel-scale-down/pkg/tracing"
+)
+
+var (
+	otlpEndpoint string
+	// tracer exports the spans of the runs to --otlp-endpoint, or is nil.
+	tracer *tracing.Tracer
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export OpenTelemetry traces of the run, its waves, resources and API calls to this OTLP HTTP endpoint, e.g. http://localhost:4318 (defaults to $OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or $OTEL_EXPORTER_OTLP_ENDPOINT)")
+}
+
+// tracingEnabled reports whether an endpoint is set by --otlp-endpoint or
+// the environment, unless OTEL_SDK_DISABLED turns the SDK off.
+func tracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return otlpEndpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""
+}
+
+// startTracing creates the tracer of --otlp-endpoint. The returned function
+// exports the last spans and must be called once the runs are done.
+func startTracing(ctx context.Context) (func(), error) {
+	if !tracingEnabled() || dryRun {
+		return func() {}, nil
+	}
+	var err error
+	tracer, err = tracing.NewTracer(ctx, otlpEndpoint, "parallel-scale-down", func(err error) {
+		logger.Warn("Could not export traces", "error", err)
+	})
+	if err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("error starting tracing: %w", err))
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = tracer.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"parallel-scale-down/pkg/scaler"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:          "verify",
+	Short:        "Check that every resource of the config is back at its replicas with ready pods and passes its health check, to close out a maintenance",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerify(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// jsonVerifyEntry is a resource of the verify subcommand in JSON output.
+type jsonVerifyEntry struct {
+	jsonPlanEntry
+	// ReadyPods is left out when the pods of the resource are not known.
+	ReadyPods *int32 `json:"readyPods,omitempty"`
+	// Health is "ok" or the error of the health check of the resource.
+	Health string `json:"health,omitempty"`
+	Passed bool   `json:"passed"`
+}
+
+// runVerify checks every resource of the config after a restore, see
+// scaler.Scaler.Verify, and fails with exitPartial if any of them did not
+// pass.
+func runVerify(cmd *cobra.Command) error {
+	if err := setOutputFormat(outputFormat); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	setupColor(textOut)
+	if err := setupLogging(textOut); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if len(inputFilePaths) == 0 && !allInNamespace {
+		return withExitCode(exitConfig, fmt.Errorf(`required flag "file" not set`))
+	}
+
+	var state *scaler.State
+	if stateFilePath != "" {
+		var err error
+		if state, err = scaler.LoadState(stateFilePath); err != nil {
+			return fmt.Errorf("error reading state file: %v", err)
+		}
+	}
+	config, err := loadConfigs(inputFilePaths)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	clusters, err := newClusters(config)
+	if err != nil {
+		return withExitCode(exitConnection, err)
+	}
+	for _, c := range clusters {
+		c.scaler = scaler.New(c.clientset, scaler.Options{
+			Dynamic:       c.dynamic,
+			Mapper:        c.mapper,
+			State:         state,
+			Cluster:       c.name,
+			PDBPolicy:     scaler.PDBPolicyIgnore,
+			IgnoreMissing: ignoreMissing,
+			OnEvent:       printEvent,
+		})
+	}
+	if _, err := resolveClusters(cmd.Context(), clusters); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	var entries []scaler.VerifyEntry
+	for _, c := range clusters {
+		entries = append(entries, c.scaler.Verify(cmd.Context(), c.targets)...)
+	}
+
+	failed := 0
+	for _, v := range entries {
+		if !v.Passed() {
+			failed++
+		}
+	}
+	if outputFormat == outputText {
+		printVerify(entries, failed)
+	} else {
+		writeVerify(entries, failed == 0)
+	}
+	if failed > 0 {
+		return withExitCode(exitPartial, fmt.Errorf("%d of %d resources failed the verification", failed, len(entries)))
+	}
+	return nil
+}
+
+// printVerify prints a table of the resources with their replicas, ready
+// pods and health, and whether the maintenance can be closed.
+func printVerify(entries []scaler.VerifyEntry, failed int) {
+	fmt.Fprintf(textOut, "\nVerification against the restore targets\n\n")
+	w := tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tRESOURCE\tCURRENT\tTARGET\tREADY\tHEALTH\tRESULT")
+	for _, v := range entries {
+		current, target := strconv.Itoa(int(v.CurrentReplicas)), strconv.Itoa(int(v.TargetReplicas))
+		if v.Target.Kind == scaler.KindCronJob {
+			current, target = cronJobState(v.CurrentReplicas), cronJobState(v.TargetReplicas)
+		}
+		ready, health := "-", verifyHealth(v)
+		if v.ReadyPods >= 0 {
+			ready = strconv.Itoa(int(v.ReadyPods))
+		}
+		if health == "" {
+			health = "-"
+		}
+		result := "pass"
+		switch {
+		case v.Missing:
+			current, target, result = "-", "-", "skipped (not found)"
+		case v.Err != nil:
+			current, target, result = "-", "-", fmt.Sprintf("error: %v", v.Err)
+		case !v.Passed():
+			result = "fail"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", v.Target.Label(), v.Target.Ref(), current, target, ready, health, result)
+	}
+	_ = w.Flush()
+	if failed > 0 {
+		fmt.Fprintf(textOut, "\n%s\n\n", colorize(colorRed, fmt.Sprintf("Verification failed: %d of %d resources are not back in service.", failed, len(entries))))
+		return
+	}
+	fmt.Fprintf(textOut, "\n%s\n\n", colorize(colorGreen, fmt.Sprintf("Verification passed: all %d resources are back in service.", len(entries))))
+}
+
+// verifyHealth is "ok" or the error of the health check of a resource, or
+// empty without one.
+func verifyHealth(v scaler.VerifyEntry) string {
+	switch {
+	case v.Target.Item.HealthCheck == nil || v.Missing || v.Err != nil:
+		return ""
+	case v.HealthErr != nil:
+		return v.HealthErr.Error()
+	}
+	return "ok"
+}
+
+// writeVerify writes the resources in the selected JSON format. In ndjson
+// mode every resource is a line of type "verify".
+func writeVerify(entries []scaler.VerifyEntry, passed bool) {
+	plan := make([]scaler.PlanEntry, len(entries))
+	for i, v := range entries {
+		plan[i] = v.PlanEntry
+	}
+	results := []jsonVerifyEntry{}
+	for i, p := range toJSONPlan(plan) {
+		entry := jsonVerifyEntry{jsonPlanEntry: p, Health: verifyHealth(entries[i]), Passed: entries[i].Passed()}
+		if entries[i].ReadyPods >= 0 {
+			entry.ReadyPods = &entries[i].ReadyPods
+		}
+		results = append(results, entry)
+	}
+	if outputFormat == outputNDJSON {
+		for _, e := range results {
+			writeJSON(struct {
+				Type string `json:"type"`
+				jsonVerifyEntry
+			}{"verify", e})
+		}
+		return
+	}
+	writeJSON(struct {
+		Passed    bool              `json:"passed"`
+		Resources []jsonVerifyEntry `json:"resources"`
+	}{passed, results})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"parallel-scale-down/pkg/webhook"
+)
+
+var (
+	webhookAddr     string
+	webhookCertFile string
+	webhookKeyFile  string
+	webhookCAFile   string
+	webhookService  string
+	webhookUsers    []string
+	webhookGroups   []string
+	webhookCmd      = &cobra.Command{
+		Use:          "webhook",
+		Short:        "Serve an admission webhook denying replica increases on the resources scaled down for a maintenance, until interrupted",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWebhook(cmd)
+		},
+	}
+)
+
+func init() {
+	webhookCmd.Flags().StringVar(&webhookAddr, "addr", ":8443", "Address to serve the webhook on")
+	webhookCmd.Flags().StringVar(&webhookCertFile, "tls-cert-file", "", "File holding the TLS certificate of the webhook")
+	webhookCmd.Flags().StringVar(&webhookKeyFile, "tls-key-file", "", "File holding the TLS private key of the webhook")
+	webhookCmd.Flags().StringVar(&webhookService, "register", "", "Register the webhook with a ValidatingWebhookConfiguration calling the Service namespace/name on port 443")
+	webhookCmd.Flags().StringSliceVar(&webhookUsers, "allow-user", nil, "User whose replica changes are always allowed, e.g. system:serviceaccount:scale-down:scale-down-operator for the restores of the operator (repeatable)")
+	webhookCmd.Flags().StringSliceVar(&webhookGroups, "allow-group", nil, "Group whose replica changes are always allowed, e.g. system:serviceaccounts:scale-down (repeatable)")
+	webhookCmd.Flags().StringVar(&webhookCAFile, "tls-ca-file", "", "File holding the CA the API server verifies the webhook with, for --register (defaults to --tls-cert-file)")
+	rootCmd.AddCommand(webhookCmd)
+}
+
+// runWebhook serves the webhook until interrupted, after registering it with
+// --register.
+func runWebhook(cmd *cobra.Command) error {
+	if err := setupLogging(os.Stdout); err != nil {
+		return err
+	}
+	if webhookCertFile == "" || webhookKeyFile == "" {
+		return withExitCode(exitConfig, fmt.Errorf("--tls-cert-file and --tls-key-file are required, the API server only calls webhooks over TLS"))
+	}
+	if len(webhookUsers) == 0 && len(webhookGroups) == 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--allow-user or --allow-group is required, so that the restores are allowed"))
+	}
+
+	kubeConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	if webhookService != "" {
+		namespace, name, ok := strings.Cut(webhookService, "/")
+		if !ok || namespace == "" || name == "" {
+			return withExitCode(exitConfig, fmt.Errorf("invalid --register %q, must be namespace/name", webhookService))
+		}
+		caFile := webhookCAFile
+		if caFile == "" {
+			caFile = webhookCertFile
+		}
+		caBundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("error reading CA file: %v", err)
+		}
+		clientset, err := kubernetes.NewForConfig(kubeConfig)
+		if err != nil {
+			return fmt.Errorf("error creating clientset: %v", err)
+		}
+		service := admissionregistrationv1.ServiceReference{Namespace: namespace, Name: name}
+		if err := webhook.Register(cmd.Context(), clientset, webhook.Configuration(service, caBundle)); err != nil {
+			return err
+		}
+		logger.Info("Webhook registered", "configuration", webhook.ConfigurationName, "service", webhookService)
+	}
+
+	return webhook.New(webhook.Options{
+		Addr:          webhookAddr,
+		CertFile:      webhookCertFile,
+		KeyFile:       webhookKeyFile,
+		Dynamic:       dynamicClient,
+		AllowedUsers:  webhookUsers,
+		AllowedGroups: webhookGroups,
+		Logger:        logger,
+	}).Run(cmd.Context())
+}